@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getMeExport       = "/users/me/export"
+	deleteMe          = "/users/me"
+	deleteMeDeletion  = "/users/me/deletion"
+	postPasswordReset = "/users/password_reset"
+)
+
+type ExportMeHandler func(ctx context.Context, token string) (AccountExport, error)
+
+func (h *Handler) RouteExportMe(handler ExportMeHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="export.json"`)
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getMeExport, wrapH)
+}
+
+type DeleteMeRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type DeleteMeHandler func(ctx context.Context, token string, req DeleteMeRequest) (User, error)
+
+func (h *Handler) RouteDeleteMe(handler DeleteMeHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req DeleteMeRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusAccepted)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMe, wrapH)
+}
+
+type CancelDeleteMeHandler func(ctx context.Context, token string) (User, error)
+
+func (h *Handler) RouteCancelDeleteMe(handler CancelDeleteMeHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMeDeletion, wrapH)
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+type ResetPasswordHandler func(ctx context.Context, origin Origin, req ResetPasswordRequest) error
+
+// RouteResetPassword isn't authorized: it's how a user whose password reset was forced gets back in.
+func (h *Handler) RouteResetPassword(handler ResetPasswordHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req ResetPasswordRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		if err := validatePassword(req.NewPassword); err != nil {
+			return fmt.Errorf("validating request: %w", err)
+		}
+
+		if err := handler(r.Context(), originFromContext(r.Context()), req); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodPost, postPasswordReset, wrapH)
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteExportMe(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteExportMe(func(_ context.Context, token string) (AccountExport, error) {
+		require.Equal(t, "token", token)
+		return AccountExport{Profile: User{ID: "id"}}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/me/export", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r AccountExport
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, `attachment; filename="export.json"`, resp.Header.Get("Content-Disposition"))
+	require.Equal(t, "id", r.Profile.ID)
+}
+
+func TestHandler_RouteDeleteMe(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+	deleteAfter := time.Now().Add(accountDeletionGracePeriod)
+
+	h.RouteDeleteMe(func(_ context.Context, token string, req DeleteMeRequest) (User, error) {
+		require.Equal(t, "password", req.Password)
+		return User{ID: "id", DeleteAfter: &deleteAfter}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/me", ts.URL), bytes.NewReader([]byte(`{"password": "password"}`)))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestHandler_RouteDeleteMe_MissingPasswordError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteDeleteMe(func(_ context.Context, token string, req DeleteMeRequest) (User, error) {
+		return User{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/me", ts.URL), bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandler_RouteResetPassword(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteResetPassword(func(_ context.Context, _ Origin, req ResetPasswordRequest) error {
+		require.Equal(t, ResetPasswordRequest{Email: "mateo.ferrari97@gmail.com", Password: "KeepImproving1!", NewPassword: "KeepImproving2!"}, req)
+		return nil
+	})
+
+	b := []byte(`{"email": "mateo.ferrari97@gmail.com", "password": "KeepImproving1!", "new_password": "KeepImproving2!"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.Post(fmt.Sprintf("%s/users/password_reset", ts.URL), "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestHandler_RouteResetPassword_WeakPasswordError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteResetPassword(func(_ context.Context, _ Origin, _ ResetPasswordRequest) error {
+		t.Fatal("handler shouldn't be called")
+		return nil
+	})
+
+	b := []byte(`{"email": "mateo.ferrari97@gmail.com", "password": "KeepImproving1!", "new_password": "weakpassword"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.Post(fmt.Sprintf("%s/users/password_reset", ts.URL), "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
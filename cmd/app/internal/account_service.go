@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+type AccountExport struct {
+	Profile              User                  `json:"profile"`
+	Identities           []Identity            `json:"identities"`
+	Roles                []string              `json:"roles"`
+	Organizations        []UserOrganization    `json:"organizations"`
+	PersonalAccessTokens []PersonalAccessToken `json:"personal_access_tokens"`
+	Sessions             []Session             `json:"sessions"`
+	AuditEvents          []AuditEvent          `json:"audit_events"`
+	ExportedAt           time.Time             `json:"exported_at"`
+}
+
+type Identity struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+}
+
+func (s *Service) ExportMe(ctx context.Context, token string) (AccountExport, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	user, err = s.withRoles(ctx, user)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	organizations, err := s.OrganizationRepository.GetUserOrganizations(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	tokens, err := s.PersonalAccessTokenRepository.GetPersonalAccessTokens(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	sessions, err := s.activeSessions(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	events, err := s.accountAuditEvents(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	identities, err := s.identities(ctx, user, events)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	return AccountExport{
+		Profile:              user,
+		Identities:           identities,
+		Roles:                user.Roles,
+		Organizations:        organizations,
+		PersonalAccessTokens: tokens,
+		Sessions:             sessions,
+		AuditEvents:          events,
+		ExportedAt:           time.Now(),
+	}, nil
+}
+
+// accountAuditEvents returns the events the user performed or was the target of, newest first.
+func (s *Service) accountAuditEvents(ctx context.Context, userID string) ([]AuditEvent, error) {
+	targeted, _, err := s.AuditRepository.GetAuditEvents(ctx, AuditQuery{Target: userID, Limit: auditExportLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	performed, _, err := s.AuditRepository.GetAuditEvents(ctx, AuditQuery{Actor: userID, Limit: auditExportLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool, len(targeted))
+	events := make([]AuditEvent, 0, len(targeted)+len(performed))
+	for _, e := range append(targeted, performed...) {
+		if seen[e.Seq] {
+			continue
+		}
+
+		seen[e.Seq] = true
+		events = append(events, e)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq > events[j].Seq })
+
+	return events, nil
+}
+
+// identities lists the ways the user can prove who they are: the password stored on their login,
+// and Google once they've signed in with it.
+func (s *Service) identities(ctx context.Context, user User, events []AuditEvent) ([]Identity, error) {
+	identities := []Identity{}
+
+	password, err := s.UserRepository.GetUserPassword(ctx, user.ID)
+	if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+		return nil, err
+	}
+
+	if password != "" {
+		identities = append(identities, Identity{Provider: "password", Email: user.Email})
+	}
+
+	for _, e := range events {
+		if e.Action == AuditActionLoginGoogle && e.Outcome == AuditOutcomeSuccess && e.Target == user.ID {
+			identities = append(identities, Identity{Provider: LoginProviderGoogle, Email: user.Email})
+			break
+		}
+	}
+
+	return identities, nil
+}
+
+func (s *Service) DeleteMe(ctx context.Context, token string, req DeleteMeRequest) (User, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return User{}, err
+	}
+
+	if user.scoped {
+		return User{}, fmt.Errorf("%w: personal access tokens can't delete the account", internal.ErrForbidden)
+	}
+
+	password, err := s.UserRepository.GetUserPassword(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password)); err != nil {
+		return User{}, fmt.Errorf("%w: password doesn't match", internal.ErrForbidden)
+	}
+
+	if err := s.ensureNoSoleOwnership(ctx, user.ID); err != nil {
+		return User{}, err
+	}
+
+	deleteAfter := time.Now().Add(accountDeletionGracePeriod)
+	if err := s.UserRepository.ScheduleUserDeletion(ctx, user.ID, &deleteAfter); err != nil {
+		return User{}, err
+	}
+
+	user.DeleteAfter = &deleteAfter
+
+	return user, nil
+}
+
+func (s *Service) CancelDeleteMe(ctx context.Context, token string) (User, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return User{}, err
+	}
+
+	if user.DeleteAfter == nil {
+		return User{}, fmt.Errorf("%w: account deletion is not scheduled", internal.ErrResourceNotFound)
+	}
+
+	if err := s.UserRepository.ScheduleUserDeletion(ctx, user.ID, nil); err != nil {
+		return User{}, err
+	}
+
+	user.DeleteAfter = nil
+
+	return user, nil
+}
+
+func (s *Service) PurgeDeletedUsers(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.UserRepository.GetUsersScheduledForDeletion(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int
+	for _, id := range ids {
+		err := s.DeleteUser(ctx, id)
+		if errors.Is(err, internal.ErrBadRequest) {
+			log.Printf("skipping deletion of user %s: %v", id, err)
+			continue
+		}
+
+		if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+			return purged, fmt.Errorf("deleting user %s: %w", id, err)
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// ResetPassword lets a user whose password reset was forced by an admin choose a new password,
+// proving they hold the current one. Unknown emails, wrong passwords and accounts that aren't
+// flagged fail the same way, so the endpoint doesn't tell which accounts exist.
+func (s *Service) ResetPassword(ctx context.Context, origin Origin, req ResetPasswordRequest) error {
+	user, err := s.resetPassword(ctx, req)
+	return s.auditCompleted(ctx, origin, AuditEvent{Actor: user.ID, Action: AuditActionResetPassword, Target: user.ID}, err)
+}
+
+func (s *Service) resetPassword(ctx context.Context, req ResetPasswordRequest) (User, error) {
+	errInvalidCredentials := fmt.Errorf("%w: invalid credentials", internal.ErrForbidden)
+
+	user, err := s.UserRepository.GetUserByEmail(ctx, req.Email)
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return User{}, errInvalidCredentials
+	}
+
+	if err != nil {
+		return User{}, err
+	}
+
+	if !user.PasswordResetRequired {
+		return User{}, errInvalidCredentials
+	}
+
+	password, err := s.UserRepository.GetUserPassword(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password)); err != nil {
+		return User{}, errInvalidCredentials
+	}
+
+	if req.NewPassword == req.Password {
+		return user, fmt.Errorf("%w: new password must be different", internal.ErrUnprocessableEntity)
+	}
+
+	b, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), s.bcryptCost)
+	if err != nil {
+		return user, fmt.Errorf("generating password: %v", err)
+	}
+
+	if err := s.UserRepository.ResetPassword(ctx, user.ID, string(b)); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
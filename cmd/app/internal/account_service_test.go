@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestExportMe(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("GetUserPassword", u.ID).Return("hash", nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{{Name: "admin"}}, nil)
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", u.ID).Return([]UserOrganization{{Organization: Organization{ID: "org"}, Role: OrganizationRoleOwner}}, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("GetPersonalAccessTokens", u.ID).Return([]PersonalAccessToken{{ID: "pat"}}, nil)
+
+	sr := &sessionRepository{}
+	sr.On("GetActiveSessions", u.ID, mock.AnythingOfType("time.Time")).Return([]Session{{ID: "session", UserAgent: "curl/7.64.1"}}, nil)
+
+	ar := &auditRepository{}
+	ar.On("GetAuditEvents", AuditQuery{Target: u.ID, Limit: auditExportLimit}).Return([]AuditEvent{
+		{Seq: 3, Actor: u.ID, Action: AuditActionLoginGoogle, Outcome: AuditOutcomeSuccess, Target: u.ID},
+		{Seq: 1, Target: u.ID},
+	}, 2, nil)
+	ar.On("GetAuditEvents", AuditQuery{Actor: u.ID, Limit: auditExportLimit}).Return([]AuditEvent{
+		{Seq: 3, Actor: u.ID, Action: AuditActionLoginGoogle, Outcome: AuditOutcomeSuccess, Target: u.ID},
+		{Seq: 2, Actor: u.ID, Action: AuditActionImpersonationStart, Target: "other"},
+	}, 2, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = rr
+	s.OrganizationRepository = or
+	s.PersonalAccessTokenRepository = p
+	s.AuditRepository = ar
+	s.SessionRepository = sr
+
+	// When
+	resp, err := s.ExportMe(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "id", resp.Profile.ID)
+	require.Equal(t, []Identity{{Provider: "password", Email: u.Email}, {Provider: LoginProviderGoogle, Email: u.Email}}, resp.Identities)
+	require.Equal(t, []string{"admin"}, resp.Roles)
+	require.Equal(t, "org", resp.Organizations[0].ID)
+	require.Equal(t, "pat", resp.PersonalAccessTokens[0].ID)
+	require.Equal(t, "session", resp.Sessions[0].ID)
+	require.Equal(t, "curl", resp.Sessions[0].Browser)
+	require.Len(t, resp.AuditEvents, 3)
+	require.Equal(t, []int64{3, 2, 1}, []int64{resp.AuditEvents[0].Seq, resp.AuditEvents[1].Seq, resp.AuditEvents[2].Seq})
+	require.Equal(t, "other", resp.AuditEvents[1].Target)
+}
+
+func TestDeleteMe(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+	password, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("GetUserPassword", u.ID).Return(string(password), nil)
+	r.On("ScheduleUserDeletion", u.ID, mock.AnythingOfType("*time.Time")).Return(nil)
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", u.ID).Return([]UserOrganization{{Organization: Organization{ID: "org"}, Role: OrganizationRoleOwner}}, nil)
+	or.On("GetMembers", "org").Return([]Member{{UserID: u.ID, Role: OrganizationRoleOwner}, {UserID: "other", Role: OrganizationRoleOwner}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+
+	// When
+	resp, err := s.DeleteMe(context.Background(), token, DeleteMeRequest{Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.WithinDuration(t, time.Now().Add(accountDeletionGracePeriod), *resp.DeleteAfter, time.Minute)
+}
+
+func TestDeleteMe_SoleOwnerError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+	password, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("GetUserPassword", u.ID).Return(string(password), nil)
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", u.ID).Return([]UserOrganization{{Organization: Organization{ID: "org"}, Role: OrganizationRoleOwner}}, nil)
+	or.On("GetMembers", "org").Return([]Member{{UserID: u.ID, Role: OrganizationRoleOwner}, {UserID: "other", Role: OrganizationRoleAdmin}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+
+	// When
+	_, err := s.DeleteMe(context.Background(), token, DeleteMeRequest{Password: "password"})
+
+	// Then
+	require.EqualError(t, err, "bad request: transfer ownership of organization org first")
+	r.AssertNotCalled(t, "ScheduleUserDeletion", mock.Anything, mock.Anything)
+}
+
+func TestDeleteMe_WrongPasswordError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+	password, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("GetUserPassword", u.ID).Return(string(password), nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	_, err := s.DeleteMe(context.Background(), token, DeleteMeRequest{Password: "another"})
+
+	// Then
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: password doesn't match")
+	r.AssertNotCalled(t, "ScheduleUserDeletion", mock.Anything, mock.Anything)
+}
+
+func TestCancelDeleteMe(t *testing.T) {
+	// Given
+	deleteAfter := time.Now().Add(time.Hour)
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com", DeleteAfter: &deleteAfter}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("ScheduleUserDeletion", u.ID, (*time.Time)(nil)).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	resp, err := s.CancelDeleteMe(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Nil(t, resp.DeleteAfter)
+	r.AssertExpectations(t)
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	// Given
+	now := time.Now()
+
+	r := &repository{}
+	r.On("GetUsersScheduledForDeletion", now).Return([]string{"a", "b", "c"}, nil)
+	r.On("DeleteUser", "a").Return(nil)
+	r.On("DeleteUser", "b").Return(internal.ErrResourceNotFound)
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", "a").Return([]UserOrganization{}, nil)
+	or.On("GetUserOrganizations", "b").Return([]UserOrganization{}, nil)
+	or.On("GetUserOrganizations", "c").Return([]UserOrganization{{Organization: Organization{ID: "org"}, Role: OrganizationRoleOwner}}, nil)
+	or.On("GetMembers", "org").Return([]Member{{UserID: "c", Role: OrganizationRoleOwner}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+
+	// When
+	resp, err := s.PurgeDeletedUsers(context.Background(), now)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, 2, resp)
+	r.AssertNotCalled(t, "DeleteUser", "c")
+}
+
+func TestPurgeDeletedUsers_Error(t *testing.T) {
+	// Given
+	now := time.Now()
+
+	r := &repository{}
+	r.On("GetUsersScheduledForDeletion", now).Return([]string{"a", "b"}, nil)
+	r.On("DeleteUser", "a").Return(errors.New("db error"))
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", "a").Return([]UserOrganization{}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+
+	// When
+	resp, err := s.PurgeDeletedUsers(context.Background(), now)
+
+	// Then
+	require.EqualError(t, err, "deleting user a: db error")
+	require.Equal(t, 0, resp)
+}
+
+func TestResetPassword_ForcedReset(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+	password, _ := bcrypt.GenerateFromPassword([]byte("KeepImproving1!"), bcrypt.MinCost)
+
+	r := NewMemoryUserRepository(nil)
+	require.NoError(t, r.SaveUser(ctx, NewUser{ID: u.ID, Email: u.Email, Password: string(password)}))
+
+	sessions := &sessionRepository{}
+	sessions.On("RevokeOtherSessions", u.ID, "", mock.AnythingOfType("time.Time")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.SessionRepository = sessions
+
+	require.NoError(t, s.ForcePasswordReset(ctx, u.ID))
+
+	_, err := s.Authorize(ctx, token)
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: password reset required")
+
+	// When
+	err = s.ResetPassword(ctx, Origin{}, ResetPasswordRequest{Email: u.Email, Password: "KeepImproving1!", NewPassword: "KeepImproving2!"})
+
+	// Then
+	require.NoError(t, err)
+
+	resp, err := s.Authorize(ctx, token)
+	require.NoError(t, err)
+	require.False(t, resp.PasswordResetRequired)
+
+	hash, err := r.GetUserPassword(ctx, u.ID)
+	require.NoError(t, err)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("KeepImproving2!")))
+}
+
+func TestResetPassword_Errors(t *testing.T) {
+	password, _ := bcrypt.GenerateFromPassword([]byte("KeepImproving1!"), bcrypt.MinCost)
+
+	tt := []struct {
+		name          string
+		user          NewUser
+		resetRequired bool
+		req           ResetPasswordRequest
+		expectedError string
+	}{
+		{
+			name:          "unknown email",
+			req:           ResetPasswordRequest{Email: "juan@gmail.com", Password: "KeepImproving1!", NewPassword: "KeepImproving2!"},
+			expectedError: "can't access to the resource. insufficient permissions: invalid credentials",
+		},
+		{
+			name:          "reset not required",
+			user:          NewUser{ID: "id", Email: "mateo.ferrari97@gmail.com", Password: string(password)},
+			req:           ResetPasswordRequest{Email: "mateo.ferrari97@gmail.com", Password: "KeepImproving1!", NewPassword: "KeepImproving2!"},
+			expectedError: "can't access to the resource. insufficient permissions: invalid credentials",
+		},
+		{
+			name:          "wrong password",
+			user:          NewUser{ID: "id", Email: "mateo.ferrari97@gmail.com", Password: string(password)},
+			resetRequired: true,
+			req:           ResetPasswordRequest{Email: "mateo.ferrari97@gmail.com", Password: "Guessing1!", NewPassword: "KeepImproving2!"},
+			expectedError: "can't access to the resource. insufficient permissions: invalid credentials",
+		},
+		{
+			name:          "same password",
+			user:          NewUser{ID: "id", Email: "mateo.ferrari97@gmail.com", Password: string(password)},
+			resetRequired: true,
+			req:           ResetPasswordRequest{Email: "mateo.ferrari97@gmail.com", Password: "KeepImproving1!", NewPassword: "KeepImproving1!"},
+			expectedError: "unprocessable entity: new password must be different",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			ctx := context.Background()
+			r := NewMemoryUserRepository(nil)
+			if tc.user.ID != "" {
+				require.NoError(t, r.SaveUser(ctx, tc.user))
+			}
+
+			if tc.resetRequired {
+				require.NoError(t, r.RequirePasswordReset(ctx, tc.user.ID))
+			}
+
+			s := NewService(r, nil, testConfig)
+
+			// When
+			err := s.ResetPassword(ctx, Origin{}, tc.req)
+
+			// Then
+			require.EqualError(t, err, tc.expectedError)
+
+			if tc.user.ID != "" {
+				hash, err := r.GetUserPassword(ctx, tc.user.ID)
+				require.NoError(t, err)
+				require.Equal(t, tc.user.Password, hash)
+			}
+		})
+	}
+}
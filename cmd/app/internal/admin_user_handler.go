@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getAdminUsers              = "/admin/users"
+	getAdminUser               = "/admin/users/{id}"
+	postAdminUserDisable       = "/admin/users/{id}/disable"
+	postAdminUserEnable        = "/admin/users/{id}/enable"
+	postAdminUserPasswordReset = "/admin/users/{id}/password_reset"
+	deleteAdminUser            = "/admin/users/{id}"
+)
+
+const defaultUsersPerPage = 20
+
+type ListUsersRequest struct {
+	Page          int    `validate:"min=1"`
+	PerPage       int    `validate:"min=1,max=100"`
+	Search        string `validate:"max=128"`
+	Status        string `validate:"omitempty,oneof=active disabled"`
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+type ListUsersHandler func(ctx context.Context, req ListUsersRequest) (UserPage, error)
+
+func (h *Handler) RouteListUsers(handler ListUsersHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		req, err := listUsersRequest(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminUsers, []string{PermissionUsersRead}, wrapH)
+}
+
+type GetUserHandler func(ctx context.Context, id string) (User, error)
+
+func (h *Handler) RouteGetUser(handler GetUserHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminUser, []string{PermissionUsersRead}, wrapH)
+}
+
+type UserActionHandler func(ctx context.Context, id string) error
+
+func (h *Handler) RouteDisableUser(handler UserActionHandler) {
+	h.routeUserAction(http.MethodPost, postAdminUserDisable, handler)
+}
+
+func (h *Handler) RouteEnableUser(handler UserActionHandler) {
+	h.routeUserAction(http.MethodPost, postAdminUserEnable, handler)
+}
+
+func (h *Handler) RouteForcePasswordReset(handler UserActionHandler) {
+	h.routeUserAction(http.MethodPost, postAdminUserPasswordReset, handler)
+}
+
+func (h *Handler) RouteDeleteUser(handler UserActionHandler) {
+	h.routeUserAction(http.MethodDelete, deleteAdminUser, handler)
+}
+
+func (h *Handler) routeUserAction(method string, pattern string, handler UserActionHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		if err := handler(r.Context(), mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(method, pattern, []string{PermissionUsersWrite}, wrapH)
+}
+
+func listUsersRequest(r *http.Request) (ListUsersRequest, error) {
+	q := r.URL.Query()
+	req := ListUsersRequest{
+		Search: q.Get("q"),
+		Status: q.Get("status"),
+	}
+
+	var err error
+	if req.Page, err = queryInt(q.Get("page"), 1); err != nil {
+		return ListUsersRequest{}, fmt.Errorf("%w: page must be a number", internal.ErrUnprocessableEntity)
+	}
+
+	if req.PerPage, err = queryInt(q.Get("per_page"), defaultUsersPerPage); err != nil {
+		return ListUsersRequest{}, fmt.Errorf("%w: per_page must be a number", internal.ErrUnprocessableEntity)
+	}
+
+	if req.CreatedAfter, err = queryTime(q.Get("created_after")); err != nil {
+		return ListUsersRequest{}, fmt.Errorf("%w: created_after must be a RFC3339 date", internal.ErrUnprocessableEntity)
+	}
+
+	if req.CreatedBefore, err = queryTime(q.Get("created_before")); err != nil {
+		return ListUsersRequest{}, fmt.Errorf("%w: created_before must be a RFC3339 date", internal.ErrUnprocessableEntity)
+	}
+
+	if err := _v.Struct(req); err != nil {
+		return ListUsersRequest{}, fmt.Errorf("validating request: %w: %v", internal.ErrUnprocessableEntity, err)
+	}
+
+	return req, nil
+}
+
+func queryInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func queryTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
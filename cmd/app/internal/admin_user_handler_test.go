@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteListUsers(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersRead)
+	h := NewHandler(w)
+
+	h.RouteListUsers(func(_ context.Context, req ListUsersRequest) (UserPage, error) {
+		require.Equal(t, 2, req.Page)
+		require.Equal(t, 5, req.PerPage)
+		require.Equal(t, "mateo", req.Search)
+		require.Equal(t, UserStatusDisabled, req.Status)
+		require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), req.CreatedAfter.UTC())
+		require.Nil(t, req.CreatedBefore)
+
+		return UserPage{Users: []User{{ID: "id"}}, Page: req.Page, PerPage: req.PerPage, Total: 6}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	url := fmt.Sprintf("%s/admin/users?page=2&per_page=5&q=mateo&status=disabled&created_after=2020-01-01T00:00:00Z", ts.URL)
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r UserPage
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 6, r.Total)
+	require.Equal(t, "id", r.Users[0].ID)
+}
+
+func TestHandler_RouteListUsers_UnprocessableEntityError(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "page is not a number", query: "page=first"},
+		{name: "page out of range", query: "page=0"},
+		{name: "per page too big", query: "per_page=500"},
+		{name: "unknown status", query: "status=banned"},
+		{name: "invalid date", query: "created_before=yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			w := newAuthorizedServer(PermissionUsersRead)
+			h := NewHandler(w)
+
+			h.RouteListUsers(func(_ context.Context, req ListUsersRequest) (UserPage, error) {
+				return UserPage{}, nil
+			})
+
+			// When
+			ts := httptest.NewServer(w.Router)
+			defer ts.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/users?%s", ts.URL, tt.query), nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer resp.Body.Close()
+
+			// Then
+			require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+	}
+}
+
+func TestHandler_RouteDisableUser_ForbiddenError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersRead)
+	h := NewHandler(w)
+
+	h.RouteDisableUser(func(_ context.Context, id string) error {
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/users/id/disable", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, "can't access to the resource. insufficient permissions: users:write is required", m)
+}
+
+func TestHandler_RouteDeleteUser(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersWrite)
+	h := NewHandler(w)
+
+	h.RouteDeleteUser(func(_ context.Context, id string) error {
+		require.Equal(t, "id", id)
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/admin/users/id", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
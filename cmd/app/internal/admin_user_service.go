@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+)
+
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+)
+
+type UserQuery struct {
+	Search        string
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+	Offset        int
+}
+
+type UserPage struct {
+	Users   []User `json:"users"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Total   int    `json:"total"`
+}
+
+func (s *Service) ListUsers(ctx context.Context, req ListUsersRequest) (UserPage, error) {
+	users, total, err := s.UserRepository.GetUsers(ctx, UserQuery{
+		Search:        req.Search,
+		Status:        req.Status,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Limit:         req.PerPage,
+		Offset:        (req.Page - 1) * req.PerPage,
+	})
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	return UserPage{
+		Users:   users,
+		Page:    req.Page,
+		PerPage: req.PerPage,
+		Total:   total,
+	}, nil
+}
+
+func (s *Service) GetUser(ctx context.Context, id string) (User, error) {
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	return s.withRoles(ctx, user)
+}
+
+func (s *Service) DisableUser(ctx context.Context, id string) error {
+	return s.UserRepository.UpdateUserStatus(ctx, id, UserStatusDisabled)
+}
+
+func (s *Service) EnableUser(ctx context.Context, id string) error {
+	return s.UserRepository.UpdateUserStatus(ctx, id, UserStatusActive)
+}
+
+// ForcePasswordReset flags the user and revokes every session they have, so the
+// tokens already issued stop working along with new logins.
+func (s *Service) ForcePasswordReset(ctx context.Context, id string) error {
+	if err := s.UserRepository.RequirePasswordReset(ctx, id); err != nil {
+		return err
+	}
+
+	return s.SessionRepository.RevokeOtherSessions(ctx, id, "", time.Now())
+}
+
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	if err := s.ensureNoSoleOwnership(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.UserRepository.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	s.notifyWebhooks(ctx, WebhookEventUserDeleted, deletedUser{ID: id})
+
+	return nil
+}
+
+type deletedUser struct {
+	ID string `json:"id"`
+}
+
+// ensureActive is checked by every login and authorize path.
+func ensureActive(user User) error {
+	if user.Status == UserStatusDisabled {
+		return fmt.Errorf("%w: user is disabled", internal.ErrForbidden)
+	}
+
+	if user.PasswordResetRequired {
+		return fmt.Errorf("%w: password reset required", internal.ErrForbidden)
+	}
+
+	return nil
+}
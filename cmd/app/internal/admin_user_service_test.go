@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUsers(t *testing.T) {
+	// Given
+	after := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := ListUsersRequest{Page: 3, PerPage: 10, Search: "mateo", Status: UserStatusActive, CreatedAfter: &after}
+
+	r := &repository{}
+	r.On("GetUsers", UserQuery{
+		Search:       "mateo",
+		Status:       UserStatusActive,
+		CreatedAfter: &after,
+		Limit:        10,
+		Offset:       20,
+	}).Return([]User{{ID: "id"}}, 21, nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	resp, err := s.ListUsers(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, UserPage{Users: []User{{ID: "id"}}, Page: 3, PerPage: 10, Total: 21}, resp)
+}
+
+func TestGetUser(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("GetUserByID", "id").Return(User{ID: "id", Status: UserStatusActive}, nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", "id").Return([]Role{{Name: "admin", Permissions: []string{PermissionUsersRead}}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.GetUser(context.Background(), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []string{"admin"}, resp.Roles)
+	require.Equal(t, []string{PermissionUsersRead}, resp.Permissions)
+}
+
+func TestDisableUser(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("UpdateUserStatus", "id", UserStatusDisabled).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	err := s.DisableUser(context.Background(), "id")
+
+	// Then
+	require.NoError(t, err)
+	r.AssertExpectations(t)
+}
+
+func TestAuthorize_DisabledUserError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(User{ID: "id", Email: u.Email, Status: UserStatusDisabled}, nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	_, err := s.Authorize(context.Background(), token)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrForbidden))
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: user is disabled")
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("RequirePasswordReset", "id").Return(nil)
+
+	sr := &sessionRepository{}
+	sr.On("RevokeOtherSessions", "id", "", mock.AnythingOfType("time.Time")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.SessionRepository = sr
+
+	// When
+	err := s.ForcePasswordReset(context.Background(), "id")
+
+	// Then
+	require.NoError(t, err)
+	r.AssertExpectations(t)
+	sr.AssertExpectations(t)
+}
+
+func TestAuthorize_PasswordResetRequiredError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(User{ID: "id", Email: u.Email, PasswordResetRequired: true}, nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	_, err := s.Authorize(context.Background(), token)
+
+	// Then
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: password reset required")
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const getAdminAuditEvents = "/admin/audit/events"
+
+const defaultAuditEventsPerPage = 50
+
+type ListAuditEventsRequest struct {
+	Page    int    `validate:"min=1"`
+	PerPage int    `validate:"min=1,max=500"`
+	Actor   string `validate:"max=256"`
+	Target  string `validate:"max=256"`
+	Action  string `validate:"max=64"`
+	Outcome string `validate:"omitempty,oneof=success failure"`
+	From    *time.Time
+	To      *time.Time
+}
+
+type ListAuditEventsHandler func(ctx context.Context, req ListAuditEventsRequest) (AuditEventPage, error)
+
+func (h *Handler) RouteListAuditEvents(handler ListAuditEventsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		req, err := listAuditEventsRequest(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminAuditEvents, []string{PermissionAuditRead}, wrapH)
+}
+
+func listAuditEventsRequest(r *http.Request) (ListAuditEventsRequest, error) {
+	q := r.URL.Query()
+	req := ListAuditEventsRequest{
+		Actor:   q.Get("actor"),
+		Target:  q.Get("target"),
+		Action:  q.Get("action"),
+		Outcome: q.Get("outcome"),
+	}
+
+	var err error
+	if req.Page, err = queryInt(q.Get("page"), 1); err != nil {
+		return ListAuditEventsRequest{}, fmt.Errorf("%w: page must be a number", internal.ErrUnprocessableEntity)
+	}
+
+	if req.PerPage, err = queryInt(q.Get("per_page"), defaultAuditEventsPerPage); err != nil {
+		return ListAuditEventsRequest{}, fmt.Errorf("%w: per_page must be a number", internal.ErrUnprocessableEntity)
+	}
+
+	if req.From, err = queryTime(q.Get("from")); err != nil {
+		return ListAuditEventsRequest{}, fmt.Errorf("%w: from must be a RFC3339 date", internal.ErrUnprocessableEntity)
+	}
+
+	if req.To, err = queryTime(q.Get("to")); err != nil {
+		return ListAuditEventsRequest{}, fmt.Errorf("%w: to must be a RFC3339 date", internal.ErrUnprocessableEntity)
+	}
+
+	if err := _v.Struct(req); err != nil {
+		return ListAuditEventsRequest{}, fmt.Errorf("validating request: %w: %v", internal.ErrUnprocessableEntity, err)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteListAuditEvents(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionAuditRead)
+	h := NewHandler(w)
+
+	h.RouteListAuditEvents(func(_ context.Context, req ListAuditEventsRequest) (AuditEventPage, error) {
+		require.Equal(t, 1, req.Page)
+		require.Equal(t, defaultAuditEventsPerPage, req.PerPage)
+		require.Equal(t, "id", req.Actor)
+		require.Equal(t, AuditOutcomeFailure, req.Outcome)
+
+		return AuditEventPage{Events: []AuditEvent{{Seq: 1}}, Total: 1}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/audit/events?actor=id&outcome=failure", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r AuditEventPage
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(1), r.Events[0].Seq)
+}
+
+func TestHandler_RouteListAuditEvents_ForbiddenError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersRead)
+	h := NewHandler(w)
+
+	h.RouteListAuditEvents(func(_ context.Context, req ListAuditEventsRequest) (AuditEventPage, error) {
+		return AuditEventPage{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/audit/events", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestRequestOrigin(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tt := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   string
+		trustedProxies []*net.IPNet
+		expectedIP     string
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "203.0.113.7:1234",
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:         "forwarded for from an untrusted client",
+			remoteAddr:   "203.0.113.7:1234",
+			forwardedFor: "198.51.100.1",
+			expectedIP:   "203.0.113.7",
+		},
+		{
+			name:           "forwarded for from a trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   "203.0.113.7",
+			trustedProxies: []*net.IPNet{proxies},
+			expectedIP:     "203.0.113.7",
+		},
+		{
+			name:           "spoofed hop before the trusted chain",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   "198.51.100.1, 203.0.113.7, 10.0.0.2",
+			trustedProxies: []*net.IPNet{proxies},
+			expectedIP:     "203.0.113.7",
+		},
+		{
+			name:           "trusted proxy without forwarded for",
+			remoteAddr:     "10.0.0.1:1234",
+			trustedProxies: []*net.IPNet{proxies},
+			expectedIP:     "10.0.0.1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("User-Agent", "curl")
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			// When
+			resp := requestOrigin(req, tc.trustedProxies)
+
+			// Then
+			require.Equal(t, Origin{IP: tc.expectedIP, UserAgent: "curl"}, resp)
+		})
+	}
+}
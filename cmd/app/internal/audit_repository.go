@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AuditSQLRepository struct {
+	db *DB
+}
+
+func NewAuditRepository(db *DB) AuditRepository {
+	return &AuditSQLRepository{
+		db: db,
+	}
+}
+
+type auditEvent struct {
+	Seq          int64     `db:"seq"`
+	ID           string    `db:"id"`
+	Actor        string    `db:"actor"`
+	Action       string    `db:"action"`
+	Target       string    `db:"target"`
+	IP           string    `db:"ip"`
+	UserAgent    string    `db:"user_agent"`
+	Outcome      string    `db:"outcome"`
+	CreatedAt    time.Time `db:"created_at"`
+	PreviousHash string    `db:"previous_hash"`
+	Hash         string    `db:"hash"`
+}
+
+func (e auditEvent) toAuditEvent() AuditEvent {
+	return AuditEvent{
+		Seq:          e.Seq,
+		ID:           e.ID,
+		Actor:        e.Actor,
+		Action:       e.Action,
+		Target:       e.Target,
+		IP:           e.IP,
+		UserAgent:    e.UserAgent,
+		Outcome:      e.Outcome,
+		CreatedAt:    e.CreatedAt.UTC(),
+		PreviousHash: e.PreviousHash,
+		Hash:         e.Hash,
+	}
+}
+
+const (
+	// audit_chain_head holds the hash of the last event in a single row. Locking it serializes
+	// appends, so two events can't chain to the same hash. SQLite has no row locks and already
+	// allows a single writer at a time.
+	getAuditChainHead    = `SELECT hash FROM audit_chain_head WHERE id = 1`
+	lockAuditChainHead   = ` FOR UPDATE`
+	updateAuditChainHead = `UPDATE audit_chain_head SET hash = :hash WHERE id = 1`
+	insertAuditEvent     = `INSERT INTO audit_event (id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash)
+						VALUES (:id, :actor, :action, :target, :ip, :user_agent, :outcome, :created_at, :previous_hash, :hash)`
+)
+
+func (r *AuditSQLRepository) AppendAuditEvent(ctx context.Context, event AuditEvent) (err error) {
+	ctx, end := r.db.start(ctx, "AppendAuditEvent")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	query := getAuditChainHead
+	if r.db.DriverName() != "sqlite" {
+		query += lockAuditChainHead
+	}
+
+	var previousHash string
+	if err = tx.GetContext(ctx, &previousHash, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errors.New("audit chain head is missing")
+		}
+
+		return err
+	}
+
+	event.PreviousHash = previousHash
+	event.Hash = event.ComputeHash()
+
+	_, err = tx.NamedExecContext(ctx, insertAuditEvent, map[string]interface{}{
+		"id":            event.ID,
+		"actor":         event.Actor,
+		"action":        event.Action,
+		"target":        event.Target,
+		"ip":            event.IP,
+		"user_agent":    event.UserAgent,
+		"outcome":       event.Outcome,
+		"created_at":    event.CreatedAt,
+		"previous_hash": event.PreviousHash,
+		"hash":          event.Hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.NamedExecContext(ctx, updateAuditChainHead, map[string]interface{}{"hash": event.Hash}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const (
+	countAuditEvents = `SELECT COUNT(1) FROM audit_event`
+	getAuditEvents   = `SELECT seq, id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash
+						FROM audit_event`
+	orderAndPaginateAuditEvents = ` ORDER BY seq DESC LIMIT :limit OFFSET :offset`
+)
+
+func (r *AuditSQLRepository) GetAuditEvents(ctx context.Context, query AuditQuery) (_ []AuditEvent, _ int, err error) {
+	ctx, end := r.db.start(ctx, "GetAuditEvents")
+	defer end(&err)
+
+	where, queryParams := auditQueryConditions(query)
+
+	countStmt, err := r.db.PrepareNamedContext(ctx, countAuditEvents+where)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer countStmt.Close()
+
+	var total int
+	if err := countStmt.GetContext(ctx, &total, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getAuditEvents+where+orderAndPaginateAuditEvents)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer stmt.Close()
+
+	queryParams["limit"] = query.Limit
+	queryParams["offset"] = query.Offset
+
+	var events []auditEvent
+	if err := stmt.SelectContext(ctx, &events, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	return toAuditEvents(events), total, nil
+}
+
+func auditQueryConditions(query AuditQuery) (string, map[string]interface{}) {
+	var conditions []string
+	queryParams := make(map[string]interface{})
+
+	for _, c := range []struct{ column, value string }{
+		{column: "actor", value: query.Actor},
+		{column: "target", value: query.Target},
+		{column: "action", value: query.Action},
+		{column: "outcome", value: query.Outcome},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, fmt.Sprintf("%s = :%s", c.column, c.column))
+			queryParams[c.column] = c.value
+		}
+	}
+
+	if query.From != nil {
+		conditions = append(conditions, "created_at >= :from")
+		queryParams["from"] = *query.From
+	}
+
+	if query.To != nil {
+		conditions = append(conditions, "created_at < :to")
+		queryParams["to"] = *query.To
+	}
+
+	if len(conditions) == 0 {
+		return "", queryParams
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), queryParams
+}
+
+const getAuditChain = `SELECT seq, id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash
+						FROM audit_event
+						WHERE seq > :after_seq
+						ORDER BY seq
+						LIMIT :limit`
+
+func (r *AuditSQLRepository) GetAuditChain(ctx context.Context, afterSeq int64, limit int) (_ []AuditEvent, err error) {
+	ctx, end := r.db.start(ctx, "GetAuditChain")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getAuditChain)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var events []auditEvent
+	if err := stmt.SelectContext(ctx, &events, map[string]interface{}{"after_seq": afterSeq, "limit": limit}); err != nil {
+		return nil, err
+	}
+
+	return toAuditEvents(events), nil
+}
+
+func toAuditEvents(events []auditEvent) []AuditEvent {
+	resp := make([]AuditEvent, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, e.toAuditEvent())
+	}
+
+	return resp
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAuditEvent(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewAuditRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	event := AuditEvent{
+		ID:        "id",
+		Actor:     "actor",
+		Action:    AuditActionLogout,
+		Outcome:   AuditOutcomeSuccess,
+		CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	chained := event
+	chained.PreviousHash = "previous"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT hash FROM audit_chain_head WHERE id = 1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("previous"))
+	mock.ExpectExec(`INSERT INTO audit_event (id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`).
+		WithArgs("id", "actor", AuditActionLogout, "", "", "", AuditOutcomeSuccess, event.CreatedAt, "previous", chained.ComputeHash()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE audit_chain_head SET hash = ? WHERE id = 1`).
+		WithArgs(chained.ComputeHash()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// When
+	err = r.AppendAuditEvent(context.Background(), event)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendAuditEvent_FirstEvent(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewAuditRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	event := AuditEvent{ID: "id", CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT hash FROM audit_chain_head WHERE id = 1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(""))
+	mock.ExpectExec(`INSERT INTO audit_event (id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`).
+		WithArgs("id", "", "", "", "", "", "", event.CreatedAt, "", event.ComputeHash()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE audit_chain_head SET hash = ? WHERE id = 1`).
+		WithArgs(event.ComputeHash()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// When
+	err = r.AppendAuditEvent(context.Background(), event)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendAuditEvent_Concurrent(t *testing.T) {
+	// Given
+	r := NewAuditRepository(newSQLiteTestDB(t))
+	s := NewService(&repository{}, nil, testConfig)
+	s.AuditRepository = r
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	// When
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- r.AppendAuditEvent(context.Background(), AuditEvent{
+				ID:        fmt.Sprintf("event-%d", i),
+				Action:    AuditActionLogout,
+				Outcome:   AuditOutcomeSuccess,
+				CreatedAt: time.Now().UTC(),
+			})
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// Then
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	resp, err := s.VerifyAuditLog(context.Background())
+	require.NoError(t, err)
+	require.True(t, resp.Valid, resp.Reason)
+	require.Equal(t, 20, resp.Verified)
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewAuditRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	where := ` WHERE actor = ? AND outcome = ?`
+	q := `SELECT seq, id, actor, action, target, ip, user_agent, outcome, created_at, previous_hash, hash
+			FROM audit_event` + where + ` ORDER BY seq DESC LIMIT ? OFFSET ?`
+
+	mock.ExpectPrepare(`SELECT COUNT(1) FROM audit_event` + where)
+	mock.ExpectQuery(`SELECT COUNT(1) FROM audit_event`+where).
+		WithArgs("id", AuditOutcomeFailure).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(1))
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("id", AuditOutcomeFailure, 10, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "id", "actor", "outcome"}).AddRow(7, "event", "id", AuditOutcomeFailure))
+
+	// When
+	resp, total, err := r.GetAuditEvents(context.Background(), AuditQuery{Actor: "id", Outcome: AuditOutcomeFailure, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 1, total)
+	require.Equal(t, []AuditEvent{{Seq: 7, ID: "event", Actor: "id", Outcome: AuditOutcomeFailure, CreatedAt: time.Time{}.UTC()}}, resp)
+}
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	PermissionAuditRead = "audit:read"
+)
+
+const (
+	AuditActionRegister      = "user.register"
+	AuditActionLoginGoogle   = "user.login.google"
+	AuditActionAuthorize     = "user.authorize"
+	AuditActionLogout        = "user.logout"
+	AuditActionResetPassword = "user.password.reset"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+const (
+	auditUserAgentMaxLength = 512
+	auditVerificationBatch  = 500
+	auditExportLimit        = 10000
+)
+
+type AuditRepository interface {
+	AppendAuditEvent(ctx context.Context, event AuditEvent) error
+	GetAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, int, error)
+	GetAuditChain(ctx context.Context, afterSeq int64, limit int) ([]AuditEvent, error)
+}
+
+type Origin struct {
+	IP        string
+	UserAgent string
+}
+
+type originKey struct{}
+
+// withOrigin stores the origin of a request for audited calls that don't take one, such as Authorize.
+func withOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+func originFromContext(ctx context.Context) Origin {
+	origin, _ := ctx.Value(originKey{}).(Origin)
+	return origin
+}
+
+type AuditEvent struct {
+	Seq          int64     `json:"seq"`
+	ID           string    `json:"id"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	Target       string    `json:"target"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	Outcome      string    `json:"outcome"`
+	CreatedAt    time.Time `json:"created_at"`
+	PreviousHash string    `json:"previous_hash"`
+	Hash         string    `json:"hash"`
+}
+
+func (e AuditEvent) ComputeHash() string {
+	b, _ := json.Marshal([]string{
+		e.PreviousHash,
+		e.ID,
+		e.Actor,
+		e.Action,
+		e.Target,
+		e.IP,
+		e.UserAgent,
+		e.Outcome,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+type AuditQuery struct {
+	Actor   string
+	Target  string
+	Action  string
+	Outcome string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+type AuditEventPage struct {
+	Events  []AuditEvent `json:"events"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	Total   int          `json:"total"`
+}
+
+type AuditVerification struct {
+	Verified  int    `json:"verified"`
+	Valid     bool   `json:"valid"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (s *Service) ListAuditEvents(ctx context.Context, req ListAuditEventsRequest) (AuditEventPage, error) {
+	events, total, err := s.AuditRepository.GetAuditEvents(ctx, AuditQuery{
+		Actor:   req.Actor,
+		Target:  req.Target,
+		Action:  req.Action,
+		Outcome: req.Outcome,
+		From:    req.From,
+		To:      req.To,
+		Limit:   req.PerPage,
+		Offset:  (req.Page - 1) * req.PerPage,
+	})
+	if err != nil {
+		return AuditEventPage{}, err
+	}
+
+	return AuditEventPage{
+		Events:  events,
+		Page:    req.Page,
+		PerPage: req.PerPage,
+		Total:   total,
+	}, nil
+}
+
+func (s *Service) VerifyAuditLog(ctx context.Context) (AuditVerification, error) {
+	var (
+		verification AuditVerification
+		previousHash string
+		afterSeq     int64
+	)
+
+	for {
+		events, err := s.AuditRepository.GetAuditChain(ctx, afterSeq, auditVerificationBatch)
+		if err != nil {
+			return AuditVerification{}, err
+		}
+
+		for _, e := range events {
+			switch {
+			case e.PreviousHash != previousHash:
+				verification.BrokenSeq = e.Seq
+				verification.Reason = "previous hash doesn't match the preceding event"
+				return verification, nil
+			case e.Hash != e.ComputeHash():
+				verification.BrokenSeq = e.Seq
+				verification.Reason = "hash doesn't match the event content"
+				return verification, nil
+			}
+
+			previousHash = e.Hash
+			afterSeq = e.Seq
+			verification.Verified++
+		}
+
+		if len(events) < auditVerificationBatch {
+			verification.Valid = true
+			return verification, nil
+		}
+	}
+}
+
+func (s *Service) audit(ctx context.Context, origin Origin, event AuditEvent, err error) error {
+	if s.AuditRepository == nil {
+		return err
+	}
+
+	id, uuidErr := uuid.NewV4()
+	if uuidErr != nil {
+		if err != nil {
+			return err
+		}
+
+		return fmt.Errorf("creating audit event: %v", uuidErr)
+	}
+
+	event.ID = id.String()
+	event.IP = origin.IP
+	event.UserAgent = origin.UserAgent
+	if len(event.UserAgent) > auditUserAgentMaxLength {
+		event.UserAgent = event.UserAgent[:auditUserAgentMaxLength]
+	}
+
+	event.Outcome = AuditOutcomeSuccess
+	if err != nil {
+		event.Outcome = AuditOutcomeFailure
+	}
+
+	event.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	if auditErr := s.AuditRepository.AppendAuditEvent(ctx, event); auditErr != nil && err == nil {
+		return fmt.Errorf("appending audit event: %v", auditErr)
+	}
+
+	return err
+}
+
+// auditCompleted is audit for operations that have already taken effect when they succeed, such
+// as a saved user or an issued token. Failing the request then would only make the caller retry
+// something that already happened, so the append error is logged instead.
+func (s *Service) auditCompleted(ctx context.Context, origin Origin, event AuditEvent, err error) error {
+	if auditErr := s.audit(ctx, origin, event, err); auditErr != nil && err == nil {
+		log.Printf("%s: %v", event.Action, auditErr)
+	}
+
+	return err
+}
@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type auditRepository struct {
+	mock.Mock
+}
+
+func (r *auditRepository) AppendAuditEvent(ctx context.Context, event AuditEvent) error {
+	return r.Called(event).Error(0)
+}
+
+func (r *auditRepository) GetAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, int, error) {
+	args := r.Called(query)
+	return args.Get(0).([]AuditEvent), args.Int(1), args.Error(2)
+}
+
+func (r *auditRepository) GetAuditChain(ctx context.Context, afterSeq int64, limit int) ([]AuditEvent, error) {
+	args := r.Called(afterSeq, limit)
+	return args.Get(0).([]AuditEvent), args.Error(1)
+}
+
+func newAuditChain(size int) []AuditEvent {
+	events := make([]AuditEvent, 0, size)
+
+	var previousHash string
+	for i := 1; i <= size; i++ {
+		e := AuditEvent{
+			Seq:          int64(i),
+			ID:           "id",
+			Action:       AuditActionLogout,
+			Outcome:      AuditOutcomeSuccess,
+			CreatedAt:    time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC),
+			PreviousHash: previousHash,
+		}
+
+		e.Hash = e.ComputeHash()
+		previousHash = e.Hash
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func TestRegister_Audit(t *testing.T) {
+	// Given
+	u := RegisterRequest{Firstname: "mateo", Lastname: "ferrari", Email: "mateo.ferrari97@gmail.com", Password: "Password1!"}
+	origin := Origin{IP: "127.0.0.1", UserAgent: "curl"}
+
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.AuditRepository = ar
+
+	// When
+	err := s.Register(context.Background(), origin, u)
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.NoError(t, err)
+	require.NotEmpty(t, event.Target)
+	require.Equal(t, event.Target, event.Actor)
+	require.Equal(t, AuditActionRegister, event.Action)
+	require.Equal(t, "127.0.0.1", event.IP)
+	require.Equal(t, "curl", event.UserAgent)
+	require.Equal(t, AuditOutcomeSuccess, event.Outcome)
+}
+
+func TestRegister_AuditError(t *testing.T) {
+	// Given
+	u := RegisterRequest{Firstname: "mateo", Lastname: "ferrari", Email: "mateo.ferrari97@gmail.com", Password: "Password1!"}
+
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(errors.New("db error"))
+
+	s := NewService(r, nil, testConfig)
+	s.AuditRepository = ar
+
+	// When
+	err := s.Register(context.Background(), Origin{}, u)
+
+	// Then
+	require.NoError(t, err)
+	r.AssertExpectations(t)
+}
+
+type googleClient struct {
+	email string
+}
+
+func (c googleClient) GetUserEmailFromAccessToken(_ context.Context, _ string) (string, error) {
+	return c.email, nil
+}
+
+func TestLoginWithGoogleCallback_AuditError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer"}`)
+	}))
+	defer google.Close()
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(errors.New("db error"))
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{}, nil)
+
+	s := NewService(r, googleClient{email: u.Email}, testConfig)
+	s.HTTPClient = google.Client()
+	s.oauthConfig.Endpoint.TokenURL = google.URL
+	s.AuditRepository = ar
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.LoginWithGoogleCallback(context.Background(), Origin{}, "code")
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp)
+	require.Equal(t, u.ID, event.Actor)
+	require.Equal(t, AuditActionLoginGoogle, event.Action)
+}
+
+func TestAuthorizeWithRoles_AuditFailure(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(User{}, internal.ErrResourceNotFound)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(errors.New("db error"))
+
+	s := NewService(r, nil, testConfig)
+	s.AuditRepository = ar
+
+	// When
+	_, err := s.AuthorizeWithRoles(context.Background(), Origin{IP: "127.0.0.1"}, token)
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+	require.Equal(t, AuditActionAuthorize, event.Action)
+	require.Equal(t, AuditOutcomeFailure, event.Outcome)
+}
+
+func TestAuthorize_AuditOrigin(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(User{}, internal.ErrResourceNotFound)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.AuditRepository = ar
+
+	ctx := withOrigin(context.Background(), Origin{IP: "127.0.0.1", UserAgent: "curl"})
+
+	// When
+	_, err := s.Authorize(ctx, token)
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.Error(t, err)
+	require.Equal(t, AuditActionAuthorize, event.Action)
+	require.Equal(t, "127.0.0.1", event.IP)
+	require.Equal(t, "curl", event.UserAgent)
+}
+
+func TestAuthorize_MalformedTokenIsNotAudited(t *testing.T) {
+	// Given
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.StandardClaims{Subject: `{"email":"mateo.ferrari97@gmail.com"}`})
+	unsignedToken, _ := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	ar := &auditRepository{}
+	s := NewService(&repository{}, nil, testConfig)
+	s.AuditRepository = ar
+	s.Metrics = NewMetrics(prometheus.NewRegistry())
+
+	for _, token := range []string{"invalid", unsignedToken} {
+		// When
+		_, err := s.Authorize(context.Background(), token)
+
+		// Then
+		require.True(t, isMalformedToken(err), "got %v", err)
+	}
+
+	ar.AssertNotCalled(t, "AppendAuditEvent", mock.Anything)
+	require.Equal(t, float64(2), testutil.ToFloat64(s.Metrics.authorizeFailures.WithLabelValues("malformed_token")))
+}
+
+func TestLogout_AuditError(t *testing.T) {
+	// Given
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(errors.New("db error"))
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.AuditRepository = ar
+
+	// When
+	err := s.Logout(context.Background(), Origin{}, "")
+
+	// Then
+	require.EqualError(t, err, "appending audit event: db error")
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	// Given
+	ar := &auditRepository{}
+	ar.On("GetAuditChain", int64(0), auditVerificationBatch).Return(newAuditChain(3), nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.AuditRepository = ar
+
+	// When
+	resp, err := s.VerifyAuditLog(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, AuditVerification{Verified: 3, Valid: true}, resp)
+}
+
+func TestVerifyAuditLog_Tampered(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(events []AuditEvent) []AuditEvent
+		expected AuditVerification
+	}{
+		{
+			name: "modified event",
+			tamper: func(events []AuditEvent) []AuditEvent {
+				events[1].Actor = "someone else"
+				return events
+			},
+			expected: AuditVerification{Verified: 1, BrokenSeq: 2, Reason: "hash doesn't match the event content"},
+		},
+		{
+			name: "deleted event",
+			tamper: func(events []AuditEvent) []AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			expected: AuditVerification{Verified: 1, BrokenSeq: 3, Reason: "previous hash doesn't match the preceding event"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			ar := &auditRepository{}
+			ar.On("GetAuditChain", int64(0), auditVerificationBatch).Return(tt.tamper(newAuditChain(3)), nil)
+
+			s := NewService(&repository{}, nil, testConfig)
+			s.AuditRepository = ar
+
+			// When
+			resp, err := s.VerifyAuditLog(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Then
+			require.Equal(t, tt.expected, resp)
+		})
+	}
+}
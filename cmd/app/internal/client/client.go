@@ -1,33 +1,39 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 )
 
-type Getter interface {
-	Get(path string) (*http.Response, error)
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type Client struct {
-	cli Getter
+	cli Doer
 }
 
-func NewClient(client Getter) *Client {
+func NewClient(client Doer) *Client {
 	return &Client{
 		cli: client,
 	}
 }
 
-func (c *Client) GetUserEmailFromAccessToken(accessToken string) (string, error) {
+func (c *Client) GetUserEmailFromAccessToken(ctx context.Context, accessToken string) (string, error) {
 	f, err := url.Parse(fmt.Sprintf("https://www.googleapis.com/oauth2/v2/userinfo?access_token=%s", accessToken))
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := c.cli.Get(f.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.cli.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("getting user information: %v", err)
 	}
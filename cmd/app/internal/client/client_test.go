@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -15,8 +16,8 @@ type client struct {
 	mock.Mock
 }
 
-func (c *client) Get(path string) (*http.Response, error) {
-	args := c.Called(path)
+func (c *client) Do(req *http.Request) (*http.Response, error) {
+	args := c.Called(req.URL.String())
 	return args.Get(0).(*http.Response), args.Error(1)
 }
 
@@ -27,13 +28,13 @@ func TestClient_GetUserEmailFromAccessToken(t *testing.T) {
 	w.Body = bytes.NewBuffer([]byte(`{"email": "luken@gmail.com"}`))
 
 	c := &client{}
-	c.On("Get", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").Return(w.Result(), nil)
+	c.On("Do", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").Return(w.Result(), nil)
 
 	token := "ble"
 	nc := NewClient(c)
 
 	// When
-	resp, err := nc.GetUserEmailFromAccessToken(token)
+	resp, err := nc.GetUserEmailFromAccessToken(context.Background(), token)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,14 +48,14 @@ func TestClient_GetUserEmailFromAccessToken_GetError(t *testing.T) {
 	var r *http.Response
 
 	c := &client{}
-	c.On("Get", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").
+	c.On("Do", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").
 		Return(r, errors.New("internal server error"))
 
 	token := "ble"
 	nc := NewClient(c)
 
 	// When
-	_, err := nc.GetUserEmailFromAccessToken(token)
+	_, err := nc.GetUserEmailFromAccessToken(context.Background(), token)
 
 	// Then
 	require.EqualError(t, err, "getting user information: internal server error")
@@ -67,13 +68,13 @@ func TestClient_GetUserEmailFromAccessToken_DecodeError(t *testing.T) {
 	w.Body = bytes.NewBuffer([]byte(`{"email": error}`))
 
 	c := &client{}
-	c.On("Get", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").Return(w.Result(), nil)
+	c.On("Do", "https://www.googleapis.com/oauth2/v2/userinfo?access_token=ble").Return(w.Result(), nil)
 
 	token := "ble"
 	nc := NewClient(c)
 
 	// When
-	_, err := nc.GetUserEmailFromAccessToken(token)
+	_, err := nc.GetUserEmailFromAccessToken(context.Background(), token)
 
 	// Then
 	require.EqualError(t, err, "decoding user information from google: invalid character 'e' looking for beginning of value")
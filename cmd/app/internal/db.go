@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// DB is the database handle shared by the SQL repositories.
+type DB struct {
+	*sqlx.DB
+	// QueryTimeout cancels a repository call that takes longer, on top of whatever deadline the
+	// caller's context already has. Zero disables it.
+	QueryTimeout time.Duration
+	// Metrics records the latency of every repository call. Nil disables it.
+	Metrics *Metrics
+}
+
+func NewDB(db *sqlx.DB, queryTimeout time.Duration) *DB {
+	return &DB{DB: db, QueryTimeout: queryTimeout}
+}
+
+// start begins a repository call: it bounds ctx by the query timeout and wraps the call in a child
+// span of the caller's trace. Exported repository methods call it before anything else and defer
+// the returned func with their error, so transactions and prepared statements are measured and
+// bounded as a whole.
+func (db *DB) start(ctx context.Context, method string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "Repository."+method)
+	ctx, cancel := db.withTimeout(ctx)
+
+	return ctx, func(err *error) {
+		cancel()
+		if *err != nil && !errors.Is(*err, internal.ErrResourceNotFound) {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+
+		span.End()
+		db.Metrics.observeQuery(method, start)
+	}
+}
+
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDB_QueryTimeout(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql"), QueryTimeout: 10 * time.Millisecond})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE user_session SET last_seen_at = ? WHERE id = ?`).
+		WithArgs(now, "session").
+		WillDelayFor(time.Second).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	start := time.Now()
+	err = r.TouchSession(context.Background(), "session", now)
+
+	// Then
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestDB_QueryTimeoutDisabled(t *testing.T) {
+	// Given
+	db := &DB{}
+
+	// When
+	ctx, cancel := db.withTimeout(context.Background())
+	defer cancel()
+
+	// Then
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+}
+
+func TestDB_Metrics(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql"), Metrics: m})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE user_session SET last_seen_at = ? WHERE id = ?`).
+		WithArgs(now, "session").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.TouchSession(context.Background(), "session", now)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, 1, testutil.CollectAndCount(m.queryDuration))
+	require.Equal(t, 1, testutil.CollectAndCount(m.queryDuration.WithLabelValues("TouchSession").(prometheus.Histogram)))
+}
+
+func TestDB_Span(t *testing.T) {
+	// Given
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE user_session SET last_seen_at = ? WHERE id = ?`).
+		WithArgs(now, "session").
+		WillReturnError(errors.New("connection refused"))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "GET /users/me")
+
+	// When
+	err = r.TouchSession(ctx, "session", now)
+	parent.End()
+
+	// Then
+	require.EqualError(t, err, "connection refused")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "Repository.TouchSession", spans[0].Name)
+	require.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	require.Equal(t, codes.Error, spans[0].StatusCode)
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type DeviceCodeSQLRepository struct {
+	db *DB
+}
+
+func NewDeviceCodeRepository(db *DB) DeviceCodeRepository {
+	return &DeviceCodeSQLRepository{
+		db: db,
+	}
+}
+
+type deviceCode struct {
+	DeviceCode   string       `db:"device_code"`
+	UserCode     string       `db:"user_code"`
+	ClientID     string       `db:"client_id"`
+	Scope        string       `db:"scope"`
+	Status       string       `db:"status"`
+	UserID       string       `db:"user_id"`
+	PollInterval int          `db:"poll_interval"`
+	ExpiresAt    time.Time    `db:"expires_at"`
+	LastPolledAt sql.NullTime `db:"last_polled_at"`
+}
+
+func (d deviceCode) toDeviceCode() DeviceCode {
+	return DeviceCode{
+		DeviceCode:   d.DeviceCode,
+		UserCode:     d.UserCode,
+		ClientID:     d.ClientID,
+		Scope:        d.Scope,
+		Status:       d.Status,
+		UserID:       d.UserID,
+		Interval:     time.Duration(d.PollInterval) * time.Second,
+		ExpiresAt:    d.ExpiresAt,
+		LastPolledAt: d.LastPolledAt.Time,
+	}
+}
+
+func deviceCodeParams(d DeviceCode) map[string]interface{} {
+	return map[string]interface{}{
+		"device_code":    d.DeviceCode,
+		"user_code":      d.UserCode,
+		"client_id":      d.ClientID,
+		"scope":          d.Scope,
+		"status":         d.Status,
+		"user_id":        d.UserID,
+		"poll_interval":  int(d.Interval / time.Second),
+		"expires_at":     d.ExpiresAt,
+		"last_polled_at": sql.NullTime{Time: d.LastPolledAt, Valid: !d.LastPolledAt.IsZero()},
+	}
+}
+
+const insertDeviceCode = `INSERT INTO device_code (device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at)
+								VALUES (:device_code, :user_code, :client_id, :scope, :status, :user_id, :poll_interval, :expires_at, :last_polled_at)`
+
+func (r *DeviceCodeSQLRepository) SaveDeviceCode(ctx context.Context, d DeviceCode) (err error) {
+	ctx, end := r.db.start(ctx, "SaveDeviceCode")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertDeviceCode, deviceCodeParams(d))
+	return err
+}
+
+const getDeviceCode = `SELECT device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at
+								FROM device_code
+								WHERE device_code = :device_code`
+
+func (r *DeviceCodeSQLRepository) GetDeviceCode(ctx context.Context, code string) (_ DeviceCode, err error) {
+	ctx, end := r.db.start(ctx, "GetDeviceCode")
+	defer end(&err)
+
+	return r.getDeviceCode(ctx, getDeviceCode, map[string]interface{}{"device_code": code})
+}
+
+const getDeviceCodeByUserCode = `SELECT device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at
+								FROM device_code
+								WHERE user_code = :user_code`
+
+func (r *DeviceCodeSQLRepository) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (_ DeviceCode, err error) {
+	ctx, end := r.db.start(ctx, "GetDeviceCodeByUserCode")
+	defer end(&err)
+
+	return r.getDeviceCode(ctx, getDeviceCodeByUserCode, map[string]interface{}{"user_code": userCode})
+}
+
+func (r *DeviceCodeSQLRepository) getDeviceCode(ctx context.Context, query string, queryParams map[string]interface{}) (DeviceCode, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return DeviceCode{}, err
+	}
+
+	defer stmt.Close()
+
+	var d deviceCode
+	err = stmt.GetContext(ctx, &d, queryParams)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return DeviceCode{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeviceCode{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return d.toDeviceCode(), nil
+}
+
+const pollDeviceCode = `UPDATE device_code
+								SET poll_interval = :poll_interval, last_polled_at = :last_polled_at
+								WHERE device_code = :device_code AND status = :status`
+
+// PollDeviceCode records a poll of a pending code. A code that's no longer pending is reported as
+// not found, so a poll racing an approval never writes over it.
+func (r *DeviceCodeSQLRepository) PollDeviceCode(ctx context.Context, code string, interval time.Duration, polledAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "PollDeviceCode")
+	defer end(&err)
+
+	return r.updatePendingDeviceCode(ctx, pollDeviceCode, map[string]interface{}{
+		"device_code":    code,
+		"poll_interval":  int(interval / time.Second),
+		"last_polled_at": polledAt,
+		"status":         DeviceCodeStatusPending,
+	})
+}
+
+const approveDeviceCode = `UPDATE device_code
+								SET status = :approved, user_id = :user_id
+								WHERE device_code = :device_code AND status = :status`
+
+// ApproveDeviceCode grants a pending code to userID. A code that's no longer pending is reported as
+// not found.
+func (r *DeviceCodeSQLRepository) ApproveDeviceCode(ctx context.Context, code string, userID string) (err error) {
+	ctx, end := r.db.start(ctx, "ApproveDeviceCode")
+	defer end(&err)
+
+	return r.updatePendingDeviceCode(ctx, approveDeviceCode, map[string]interface{}{
+		"device_code": code,
+		"user_id":     userID,
+		"approved":    DeviceCodeStatusApproved,
+		"status":      DeviceCodeStatusPending,
+	})
+}
+
+const denyDeviceCode = `UPDATE device_code
+								SET status = :denied
+								WHERE device_code = :device_code AND status = :status`
+
+// DenyDeviceCode denies a pending code. A code that's no longer pending is reported as not found.
+func (r *DeviceCodeSQLRepository) DenyDeviceCode(ctx context.Context, code string) (err error) {
+	ctx, end := r.db.start(ctx, "DenyDeviceCode")
+	defer end(&err)
+
+	return r.updatePendingDeviceCode(ctx, denyDeviceCode, map[string]interface{}{
+		"device_code": code,
+		"denied":      DeviceCodeStatusDenied,
+		"status":      DeviceCodeStatusPending,
+	})
+}
+
+func (r *DeviceCodeSQLRepository) updatePendingDeviceCode(ctx context.Context, query string, queryParams map[string]interface{}) error {
+	result, err := r.db.NamedExecContext(ctx, query, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+const deleteDeviceCode = `DELETE FROM device_code WHERE device_code = :device_code`
+
+func (r *DeviceCodeSQLRepository) DeleteDeviceCode(ctx context.Context, code string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteDeviceCode")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, deleteDeviceCode, map[string]interface{}{"device_code": code})
+	return err
+}
+
+const consumeDeviceCode = `DELETE FROM device_code WHERE device_code = :device_code AND status = :status`
+
+func (r *DeviceCodeSQLRepository) ConsumeDeviceCode(ctx context.Context, code string) (err error) {
+	ctx, end := r.db.start(ctx, "ConsumeDeviceCode")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, consumeDeviceCode, map[string]interface{}{
+		"device_code": code,
+		"status":      DeviceCodeStatusApproved,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	d := DeviceCode{
+		DeviceCode: "device",
+		UserCode:   "BCDFGHJK",
+		ClientID:   "cli",
+		Scope:      "openid",
+		Status:     DeviceCodeStatusPending,
+		Interval:   5 * time.Second,
+		ExpiresAt:  time.Now(),
+	}
+
+	mock.ExpectExec(`INSERT INTO device_code (device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`).
+		WithArgs(d.DeviceCode, d.UserCode, d.ClientID, d.Scope, d.Status, "", 5, d.ExpiresAt, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.SaveDeviceCode(context.Background(), d)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	expiresAt := time.Now()
+	q := `SELECT device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at
+			FROM device_code
+			WHERE device_code = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("device").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"device_code", "user_code", "client_id", "scope", "status", "user_id", "poll_interval", "expires_at", "last_polled_at"}).
+				AddRow("device", "BCDFGHJK", "cli", "openid", DeviceCodeStatusApproved, "id", 5, expiresAt, nil),
+		)
+
+	// When
+	resp, err := r.GetDeviceCode(context.Background(), "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "BCDFGHJK", resp.UserCode)
+	require.Equal(t, DeviceCodeStatusApproved, resp.Status)
+	require.Equal(t, "id", resp.UserID)
+	require.Equal(t, 5*time.Second, resp.Interval)
+	require.True(t, resp.LastPolledAt.IsZero())
+}
+
+func TestGetDeviceCodeByUserCode_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT device_code, user_code, client_id, scope, status, user_id, poll_interval, expires_at, last_polled_at
+			FROM device_code
+			WHERE user_code = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("BCDFGHJK").
+		WillReturnError(sql.ErrNoRows)
+
+	// When
+	_, err = r.GetDeviceCodeByUserCode(context.Background(), "BCDFGHJK")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestPollDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	polledAt := time.Now()
+
+	mock.ExpectExec(`UPDATE device_code
+			SET poll_interval = ?, last_polled_at = ?
+			WHERE device_code = ? AND status = ?`).
+		WithArgs(10, polledAt, "device", DeviceCodeStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.PollDeviceCode(context.Background(), "device", 10*time.Second, polledAt)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`UPDATE device_code
+			SET status = ?, user_id = ?
+			WHERE device_code = ? AND status = ?`).
+		WithArgs(DeviceCodeStatusApproved, "id", "device", DeviceCodeStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.ApproveDeviceCode(context.Background(), "device", "id")
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDenyDeviceCode_NotPendingError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`UPDATE device_code
+			SET status = ?
+			WHERE device_code = ? AND status = ?`).
+		WithArgs(DeviceCodeStatusDenied, "device", DeviceCodeStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.DenyDeviceCode(context.Background(), "device")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestDeleteDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`DELETE FROM device_code WHERE device_code = ?`).
+		WithArgs("device").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.DeleteDeviceCode(context.Background(), "device")
+
+	// Then
+	require.NoError(t, err)
+}
+
+func TestConsumeDeviceCode(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`DELETE FROM device_code WHERE device_code = ? AND status = ?`).
+		WithArgs("device", DeviceCodeStatusApproved).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.ConsumeDeviceCode(context.Background(), "device")
+
+	// Then
+	require.NoError(t, err)
+}
+
+func TestConsumeDeviceCode_AlreadyConsumedError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewDeviceCodeRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`DELETE FROM device_code WHERE device_code = ? AND status = ?`).
+		WithArgs("device", DeviceCodeStatusApproved).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.ConsumeDeviceCode(context.Background(), "device")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	postDeviceAuthorization = "/oauth/device_authorization"
+	postToken               = "/oauth/token"
+	getDevice               = "/device"
+	postDevice              = "/device"
+	deviceCSRFCookie        = "device_csrf"
+)
+
+var deviceTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Device login</title></head>
+<body>
+{{if .Done}}
+<p>{{.Done}}</p>
+{{else}}
+<form method="POST" action="/device">
+	<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+	<label for="user_code">Enter the code shown on your device</label>
+	<input id="user_code" name="user_code" value="{{.UserCode}}" autocomplete="off" required>
+	<button type="submit" name="action" value="approve">Approve</button>
+	<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type devicePage struct {
+	UserCode  string
+	CSRFToken string
+	Done      string
+}
+
+type DeviceAuthorizationHandler func(ctx context.Context, clientID string, scope string) (DeviceAuthorization, error)
+
+func (h *Handler) RouteDeviceAuthorization(handler DeviceAuthorizationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context(), r.FormValue("client_id"), r.FormValue("scope"))
+		if err != nil {
+			return respondOAuthError(w, err)
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPost, postDeviceAuthorization, wrapH)
+}
+
+type TokenHandler func(ctx context.Context, origin Origin, req TokenRequest) (AccessToken, error)
+
+func (h *Handler) RouteToken(handler TokenHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		req := TokenRequest{
+			GrantType:  r.FormValue("grant_type"),
+			DeviceCode: r.FormValue("device_code"),
+			ClientID:   r.FormValue("client_id"),
+		}
+
+		resp, err := handler(r.Context(), originFromContext(r.Context()), req)
+		if err != nil {
+			return respondOAuthError(w, err)
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPost, postToken, wrapH)
+}
+
+func (h *Handler) RouteDevice() {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		csrfToken, err := randomToken(32)
+		if err != nil {
+			return fmt.Errorf("generating csrf token: %v", err)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     deviceCSRFCookie,
+			Value:    csrfToken,
+			Path:     getDevice,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		return deviceTemplate.Execute(w, devicePage{UserCode: r.FormValue("user_code"), CSRFToken: csrfToken})
+	}
+
+	h.Wrap(http.MethodGet, getDevice, wrapH)
+}
+
+type VerifyDeviceHandler func(ctx context.Context, token string, userCode string, approve bool) error
+
+func (h *Handler) RouteVerifyDevice(handler VerifyDeviceHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		if err := verifyDeviceCSRFToken(r); err != nil {
+			return err
+		}
+
+		userCode := r.FormValue("user_code")
+		if userCode == "" {
+			return fmt.Errorf("%w: user_code is required", internal.ErrBadRequest)
+		}
+
+		approve := r.FormValue("action") == "approve"
+		if err := handler(r.Context(), token, userCode, approve); err != nil {
+			return err
+		}
+
+		done := "Device denied. You can close this window."
+		if approve {
+			done = "Device approved. You can return to your device."
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		return deviceTemplate.Execute(w, devicePage{Done: done})
+	}
+
+	h.Wrap(http.MethodPost, postDevice, wrapH)
+}
+
+// verifyDeviceCSRFToken checks the form token against the cookie set when the
+// device page was rendered, so another site can't submit the form on behalf of
+// a logged in user.
+func verifyDeviceCSRFToken(r *http.Request) error {
+	c, err := r.Cookie(deviceCSRFCookie)
+	if err != nil || c.Value == "" {
+		return fmt.Errorf("%w: csrf token is required", internal.ErrForbidden)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.FormValue("csrf_token"))) != 1 {
+		return fmt.Errorf("%w: csrf token doesn't match", internal.ErrForbidden)
+	}
+
+	return nil
+}
+
+func respondOAuthError(w http.ResponseWriter, err error) error {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		return err
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	return internal.RespondJSON(w, oauthErr, oauthErr.StatusCode())
+}
@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteDeviceAuthorization(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteDeviceAuthorization(func(_ context.Context, clientID string, scope string) (DeviceAuthorization, error) {
+		require.Equal(t, "cli", clientID)
+		require.Equal(t, "openid", scope)
+
+		return DeviceAuthorization{DeviceCode: "device", UserCode: "BCDF-GHJK", Interval: 5}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.PostForm(fmt.Sprintf("%s/oauth/device_authorization", ts.URL), url.Values{
+		"client_id": {"cli"},
+		"scope":     {"openid"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r DeviceAuthorization
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "device", r.DeviceCode)
+	require.Equal(t, "BCDF-GHJK", r.UserCode)
+}
+
+func TestHandler_RouteToken(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteToken(func(_ context.Context, origin Origin, req TokenRequest) (AccessToken, error) {
+		require.Equal(t, deviceCodeGrantType, req.GrantType)
+		require.Equal(t, "device", req.DeviceCode)
+		require.Equal(t, "cli", req.ClientID)
+
+		return AccessToken{AccessToken: "token", TokenType: "Bearer", ExpiresIn: 900}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.PostForm(fmt.Sprintf("%s/oauth/token", ts.URL), url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {"device"},
+		"client_id":   {"cli"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r AccessToken
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "token", r.AccessToken)
+	require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+}
+
+func TestHandler_RouteToken_OAuthError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteToken(func(_ context.Context, origin Origin, req TokenRequest) (AccessToken, error) {
+		return AccessToken{}, ErrAuthorizationPending
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.PostForm(fmt.Sprintf("%s/oauth/token", ts.URL), url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r struct {
+		Error string `json:"error"`
+	}
+
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "authorization_pending", r.Error)
+}
+
+func TestHandler_RouteToken_HandlerError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteToken(func(_ context.Context, origin Origin, req TokenRequest) (AccessToken, error) {
+		return AccessToken{}, errors.New("internal server error")
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.PostForm(fmt.Sprintf("%s/oauth/token", ts.URL), url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, "internal server error", m)
+}
+
+func TestHandler_RouteDevice(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteDevice()
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s/device?user_code=BCDF-GHJK", ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+
+	var csrf *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == deviceCSRFCookie {
+			csrf = c
+		}
+	}
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(b), `value="BCDF-GHJK"`)
+	require.NotNil(t, csrf)
+	require.Equal(t, http.SameSiteStrictMode, csrf.SameSite)
+	require.Contains(t, string(b), fmt.Sprintf(`name="csrf_token" value="%s"`, csrf.Value))
+}
+
+func TestHandler_RouteVerifyDevice(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteVerifyDevice(func(_ context.Context, token string, userCode string, approve bool) error {
+		require.Equal(t, "token", token)
+		require.Equal(t, "BCDF-GHJK", userCode)
+		require.True(t, approve)
+
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	body := url.Values{"user_code": {"BCDF-GHJK"}, "action": {"approve"}, "csrf_token": {"csrf"}}.Encode()
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/device", ts.URL), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{
+		Name:  "authorization",
+		Value: "token",
+	})
+	req.AddCookie(&http.Cookie{
+		Name:  deviceCSRFCookie,
+		Value: "csrf",
+	})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(b), "Device approved")
+}
+
+func TestHandler_RouteVerifyDevice_MissingTokenError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteVerifyDevice(func(_ context.Context, token string, userCode string, approve bool) error {
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	resp, err := http.PostForm(fmt.Sprintf("%s/device", ts.URL), url.Values{"user_code": {"BCDF-GHJK"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, "can't access to the resource. invalid token: authorization cookie is required", m)
+}
+
+func TestHandler_RouteVerifyDevice_CSRFTokenError(t *testing.T) {
+	tt := []struct {
+		name      string
+		cookie    string
+		formToken string
+		expected  string
+	}{
+		{
+			name:     "missing cookie",
+			expected: "can't access to the resource. insufficient permissions: csrf token is required",
+		},
+		{
+			name:      "mismatch",
+			cookie:    "csrf",
+			formToken: "other",
+			expected:  "can't access to the resource. insufficient permissions: csrf token doesn't match",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			w := server.NewServer(config.Server{})
+			h := NewHandler(w)
+
+			h.RouteVerifyDevice(func(_ context.Context, token string, userCode string, approve bool) error {
+				t.Fatal("handler must not be called")
+				return nil
+			})
+
+			// When
+			ts := httptest.NewServer(w.Router)
+			defer ts.Close()
+
+			body := url.Values{"user_code": {"BCDF-GHJK"}, "action": {"approve"}, "csrf_token": {tc.formToken}}.Encode()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/device", ts.URL), strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(&http.Cookie{Name: "authorization", Value: "token"})
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: deviceCSRFCookie, Value: tc.cookie})
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer resp.Body.Close()
+
+			m := decodeErrorMessageFromBody(resp.Body)
+
+			// Then
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+			require.Equal(t, tc.expected, m)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	deviceCodeGrantType      = "urn:ietf:params:oauth:grant-type:device_code"
+	deviceCodeExpiration     = 10 * time.Minute
+	deviceCodeInterval       = 5 * time.Second
+	deviceCodeSlowDownPeriod = 5 * time.Second
+	userCodeCharset          = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength           = 8
+)
+
+const (
+	DeviceCodeStatusPending  = "pending"
+	DeviceCodeStatusApproved = "approved"
+	DeviceCodeStatusDenied   = "denied"
+)
+
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func (e *OAuthError) StatusCode() int {
+	if e.Code == ErrInvalidClient.Code {
+		return http.StatusUnauthorized
+	}
+
+	return http.StatusBadRequest
+}
+
+var (
+	ErrInvalidRequest       = &OAuthError{Code: "invalid_request"}
+	ErrInvalidClient        = &OAuthError{Code: "invalid_client"}
+	ErrInvalidGrant         = &OAuthError{Code: "invalid_grant"}
+	ErrInvalidScope         = &OAuthError{Code: "invalid_scope"}
+	ErrUnsupportedGrantType = &OAuthError{Code: "unsupported_grant_type"}
+	ErrAuthorizationPending = &OAuthError{Code: "authorization_pending"}
+	ErrSlowDown             = &OAuthError{Code: "slow_down"}
+	ErrAccessDenied         = &OAuthError{Code: "access_denied"}
+	ErrExpiredToken         = &OAuthError{Code: "expired_token"}
+)
+
+type DeviceCodeRepository interface {
+	SaveDeviceCode(ctx context.Context, deviceCode DeviceCode) error
+	GetDeviceCode(ctx context.Context, deviceCode string) (DeviceCode, error)
+	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (DeviceCode, error)
+	PollDeviceCode(ctx context.Context, deviceCode string, interval time.Duration, polledAt time.Time) error
+	ApproveDeviceCode(ctx context.Context, deviceCode string, userID string) error
+	DenyDeviceCode(ctx context.Context, deviceCode string) error
+	DeleteDeviceCode(ctx context.Context, deviceCode string) error
+	ConsumeDeviceCode(ctx context.Context, deviceCode string) error
+}
+
+type DeviceCode struct {
+	DeviceCode string
+	UserCode   string
+	ClientID   string
+	// Scope is the space separated permissions the issued token is limited to. Empty means the
+	// token carries every permission of the user.
+	Scope        string
+	Status       string
+	UserID       string
+	Interval     time.Duration
+	ExpiresAt    time.Time
+	LastPolledAt time.Time
+}
+
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type TokenRequest struct {
+	GrantType  string
+	DeviceCode string
+	ClientID   string
+}
+
+type AccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+func (s *Service) AuthorizeDevice(ctx context.Context, clientID string, scope string) (DeviceAuthorization, error) {
+	if clientID == "" {
+		return DeviceAuthorization{}, &OAuthError{Code: ErrInvalidClient.Code, Description: "client_id is required"}
+	}
+
+	if strings.TrimSpace(scope) != "" {
+		unknown, err := s.unknownPermission(ctx, strings.Fields(scope))
+		if err != nil {
+			return DeviceAuthorization{}, err
+		}
+
+		if unknown != "" {
+			return DeviceAuthorization{}, &OAuthError{Code: ErrInvalidScope.Code, Description: fmt.Sprintf("unknown scope %s", unknown)}
+		}
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("generating device code: %v", err)
+	}
+
+	userCode, err := randomUserCode()
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("generating user code: %v", err)
+	}
+
+	d := DeviceCode{
+		DeviceCode: code,
+		UserCode:   userCode,
+		ClientID:   clientID,
+		Scope:      strings.Join(strings.Fields(scope), " "),
+		Status:     DeviceCodeStatusPending,
+		Interval:   deviceCodeInterval,
+		ExpiresAt:  time.Now().Add(deviceCodeExpiration),
+	}
+
+	if err := s.DeviceCodeRepository.SaveDeviceCode(ctx, d); err != nil {
+		return DeviceAuthorization{}, err
+	}
+
+	formatted := formatUserCode(userCode)
+
+	return DeviceAuthorization{
+		DeviceCode:              code,
+		UserCode:                formatted,
+		VerificationURI:         s.deviceVerificationURL,
+		VerificationURIComplete: fmt.Sprintf("%s?user_code=%s", s.deviceVerificationURL, url.QueryEscape(formatted)),
+		ExpiresIn:               int(deviceCodeExpiration.Seconds()),
+		Interval:                int(deviceCodeInterval.Seconds()),
+	}, nil
+}
+
+func (s *Service) VerifyDevice(ctx context.Context, token string, userCode string, approve bool) error {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return err
+	}
+
+	d, err := s.DeviceCodeRepository.GetDeviceCodeByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return err
+	}
+
+	if time.Now().After(d.ExpiresAt) {
+		return fmt.Errorf("%w: user code has expired", internal.ErrBadRequest)
+	}
+
+	errUsed := fmt.Errorf("%w: user code has already been used", internal.ErrBadRequest)
+	if d.Status != DeviceCodeStatusPending {
+		return errUsed
+	}
+
+	// The code can be decided between the read above and the update, so the update only applies to
+	// a code that's still pending.
+	if approve {
+		err = s.DeviceCodeRepository.ApproveDeviceCode(ctx, d.DeviceCode, user.ID)
+	} else {
+		err = s.DeviceCodeRepository.DenyDeviceCode(ctx, d.DeviceCode)
+	}
+
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return errUsed
+	}
+
+	return err
+}
+
+func (s *Service) Token(ctx context.Context, origin Origin, req TokenRequest) (AccessToken, error) {
+	switch req.GrantType {
+	case deviceCodeGrantType:
+		resp, err := s.exchangeDeviceCode(ctx, origin, req)
+		s.Metrics.login(LoginProviderDevice, err)
+
+		return resp, err
+	case "":
+		return AccessToken{}, &OAuthError{Code: ErrInvalidRequest.Code, Description: "grant_type is required"}
+	default:
+		return AccessToken{}, ErrUnsupportedGrantType
+	}
+}
+
+func (s *Service) exchangeDeviceCode(ctx context.Context, origin Origin, req TokenRequest) (AccessToken, error) {
+	if req.DeviceCode == "" {
+		return AccessToken{}, &OAuthError{Code: ErrInvalidRequest.Code, Description: "device_code is required"}
+	}
+
+	d, err := s.DeviceCodeRepository.GetDeviceCode(ctx, req.DeviceCode)
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return AccessToken{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if d.ClientID != req.ClientID {
+		return AccessToken{}, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if now.After(d.ExpiresAt) {
+		return AccessToken{}, ErrExpiredToken
+	}
+
+	switch d.Status {
+	case DeviceCodeStatusDenied:
+		if err := s.DeviceCodeRepository.DeleteDeviceCode(ctx, d.DeviceCode); err != nil {
+			return AccessToken{}, err
+		}
+
+		return AccessToken{}, ErrAccessDenied
+	case DeviceCodeStatusPending:
+		tooFast := !d.LastPolledAt.IsZero() && now.Sub(d.LastPolledAt) < d.Interval
+		if tooFast {
+			d.Interval += deviceCodeSlowDownPeriod
+		}
+
+		// A code decided since it was read is reported as pending; the next poll sees the decision.
+		err := s.DeviceCodeRepository.PollDeviceCode(ctx, d.DeviceCode, d.Interval, now)
+		if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+			return AccessToken{}, err
+		}
+
+		if tooFast {
+			return AccessToken{}, ErrSlowDown
+		}
+
+		return AccessToken{}, ErrAuthorizationPending
+	}
+
+	user, err := s.UserRepository.GetUserByID(ctx, d.UserID)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if ensureActive(user) != nil {
+		return AccessToken{}, ErrAccessDenied
+	}
+
+	// Two polls can both read the approved code; only the one that deletes it
+	// gets a token.
+	err = s.DeviceCodeRepository.ConsumeDeviceCode(ctx, d.DeviceCode)
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return AccessToken{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if d.Scope != "" {
+		user.scopes = strings.Fields(d.Scope)
+		user.scoped = true
+	}
+
+	user.sessionID, err = s.startSession(ctx, origin, user, tokenExpiration)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	t, err := s.issueToken(ctx, user)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("authorizing user: %v", err)
+	}
+
+	return AccessToken{
+		AccessToken: t,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(tokenExpiration.Seconds()),
+		Scope:       d.Scope,
+	}, nil
+}
+
+func randomUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeCharset)))
+
+	var sb strings.Builder
+	for i := 0; i < userCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteByte(userCodeCharset[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+func formatUserCode(userCode string) string {
+	half := len(userCode) / 2
+	return fmt.Sprintf("%s-%s", userCode[:half], userCode[half:])
+}
+
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(userCode)
+	return strings.NewReplacer("-", "", " ", "").Replace(userCode)
+}
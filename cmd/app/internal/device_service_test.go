@@ -0,0 +1,454 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type deviceCodeRepository struct {
+	mock.Mock
+}
+
+func (r *deviceCodeRepository) SaveDeviceCode(ctx context.Context, deviceCode DeviceCode) error {
+	return r.Called(deviceCode).Error(0)
+}
+
+func (r *deviceCodeRepository) GetDeviceCode(ctx context.Context, deviceCode string) (DeviceCode, error) {
+	args := r.Called(deviceCode)
+	return args.Get(0).(DeviceCode), args.Error(1)
+}
+
+func (r *deviceCodeRepository) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (DeviceCode, error) {
+	args := r.Called(userCode)
+	return args.Get(0).(DeviceCode), args.Error(1)
+}
+
+func (r *deviceCodeRepository) PollDeviceCode(ctx context.Context, deviceCode string, interval time.Duration, polledAt time.Time) error {
+	return r.Called(deviceCode, interval, polledAt).Error(0)
+}
+
+func (r *deviceCodeRepository) ApproveDeviceCode(ctx context.Context, deviceCode string, userID string) error {
+	return r.Called(deviceCode, userID).Error(0)
+}
+
+func (r *deviceCodeRepository) DenyDeviceCode(ctx context.Context, deviceCode string) error {
+	return r.Called(deviceCode).Error(0)
+}
+
+func (r *deviceCodeRepository) DeleteDeviceCode(ctx context.Context, deviceCode string) error {
+	return r.Called(deviceCode).Error(0)
+}
+
+func (r *deviceCodeRepository) ConsumeDeviceCode(ctx context.Context, deviceCode string) error {
+	return r.Called(deviceCode).Error(0)
+}
+
+func TestAuthorizeDevice(t *testing.T) {
+	// Given
+	d := &deviceCodeRepository{}
+	d.On("SaveDeviceCode", mock.AnythingOfType("DeviceCode")).Return(nil)
+
+	rr := &roleRepository{}
+	rr.On("GetPermissions").Return([]Permission{{Name: PermissionUsersRead}, {Name: PermissionRolesRead}}, nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.DeviceCodeRepository = d
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.AuthorizeDevice(context.Background(), "cli", " users:read  roles:read ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	saved := d.Calls[0].Arguments.Get(0).(DeviceCode)
+	require.Equal(t, saved.DeviceCode, resp.DeviceCode)
+	require.Equal(t, formatUserCode(saved.UserCode), resp.UserCode)
+	require.Equal(t, DeviceCodeStatusPending, saved.Status)
+	require.Equal(t, "cli", saved.ClientID)
+	require.Equal(t, "users:read roles:read", saved.Scope)
+	require.Equal(t, "http://localhost:8081/device", resp.VerificationURI)
+	require.Equal(t, 600, resp.ExpiresIn)
+	require.Equal(t, 5, resp.Interval)
+}
+
+func TestAuthorizeDevice_MissingClientError(t *testing.T) {
+	// Given
+	s := NewService(&repository{}, nil, testConfig)
+
+	// When
+	_, err := s.AuthorizeDevice(context.Background(), "", "")
+
+	// Then
+	require.EqualError(t, err, "invalid_client: client_id is required")
+}
+
+func TestAuthorizeDevice_InvalidScopeError(t *testing.T) {
+	// Given
+	d := &deviceCodeRepository{}
+
+	rr := &roleRepository{}
+	rr.On("GetPermissions").Return([]Permission{{Name: PermissionUsersRead}}, nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.DeviceCodeRepository = d
+	s.RoleRepository = rr
+
+	// When
+	_, err := s.AuthorizeDevice(context.Background(), "cli", "users:read openid")
+
+	// Then
+	require.EqualError(t, err, "invalid_scope: unknown scope openid")
+	d.AssertNotCalled(t, "SaveDeviceCode", mock.Anything)
+}
+
+func TestVerifyDevice(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	pending := DeviceCode{DeviceCode: "code", UserCode: "BCDFGHJK", Status: DeviceCodeStatusPending, ExpiresAt: time.Now().Add(time.Minute)}
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCodeByUserCode", "BCDFGHJK").Return(pending, nil)
+	d.On("ApproveDeviceCode", "code", u.ID).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	err := s.VerifyDevice(context.Background(), token, "bcdf-ghjk", true)
+
+	// Then
+	require.NoError(t, err)
+	d.AssertExpectations(t)
+}
+
+func TestVerifyDevice_Deny(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCodeByUserCode", "BCDFGHJK").Return(DeviceCode{DeviceCode: "code", Status: DeviceCodeStatusPending, ExpiresAt: time.Now().Add(time.Minute)}, nil)
+	d.On("DenyDeviceCode", "code").Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	err := s.VerifyDevice(context.Background(), token, "BCDF-GHJK", false)
+
+	// Then
+	require.NoError(t, err)
+	d.AssertExpectations(t)
+	d.AssertNotCalled(t, "ApproveDeviceCode", mock.Anything, mock.Anything)
+}
+
+func TestVerifyDevice_AlreadyDecidedError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCodeByUserCode", "BCDFGHJK").Return(DeviceCode{DeviceCode: "code", Status: DeviceCodeStatusPending, ExpiresAt: time.Now().Add(time.Minute)}, nil)
+	d.On("ApproveDeviceCode", "code", u.ID).Return(fmt.Errorf("%w: db not found", internal.ErrResourceNotFound))
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	err := s.VerifyDevice(context.Background(), token, "BCDF-GHJK", true)
+
+	// Then
+	require.EqualError(t, err, "bad request: user code has already been used")
+}
+
+func TestVerifyDevice_ExpiredError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCodeByUserCode", "BCDFGHJK").Return(DeviceCode{Status: DeviceCodeStatusPending, ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	err := s.VerifyDevice(context.Background(), token, "BCDF-GHJK", true)
+
+	// Then
+	require.EqualError(t, err, "bad request: user code has expired")
+}
+
+func TestToken_DeviceCode(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode: "code",
+		ClientID:   "cli",
+		Status:     DeviceCodeStatusApproved,
+		UserID:     u.ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}, nil)
+	d.On("ConsumeDeviceCode", "code").Return(nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.NotEmpty(t, resp.AccessToken)
+	require.Equal(t, "Bearer", resp.TokenType)
+	require.Equal(t, 900, resp.ExpiresIn)
+	d.AssertExpectations(t)
+}
+
+func TestToken_DeviceCodeErrors(t *testing.T) {
+	tt := []struct {
+		name        string
+		deviceCode  DeviceCode
+		err         error
+		expectedErr error
+	}{
+		{
+			name:        "not found",
+			err:         internal.ErrResourceNotFound,
+			expectedErr: ErrInvalidGrant,
+		},
+		{
+			name:        "client mismatch",
+			deviceCode:  DeviceCode{ClientID: "other", ExpiresAt: time.Now().Add(time.Minute)},
+			expectedErr: ErrInvalidGrant,
+		},
+		{
+			name:        "expired",
+			deviceCode:  DeviceCode{ClientID: "cli", Status: DeviceCodeStatusPending, ExpiresAt: time.Now().Add(-time.Minute)},
+			expectedErr: ErrExpiredToken,
+		},
+		{
+			name:        "pending",
+			deviceCode:  DeviceCode{ClientID: "cli", Status: DeviceCodeStatusPending, Interval: 5 * time.Second, ExpiresAt: time.Now().Add(time.Minute)},
+			expectedErr: ErrAuthorizationPending,
+		},
+		{
+			name:        "polling too fast",
+			deviceCode:  DeviceCode{ClientID: "cli", Status: DeviceCodeStatusPending, Interval: 5 * time.Second, ExpiresAt: time.Now().Add(time.Minute), LastPolledAt: time.Now()},
+			expectedErr: ErrSlowDown,
+		},
+		{
+			name:        "denied",
+			deviceCode:  DeviceCode{ClientID: "cli", Status: DeviceCodeStatusDenied, ExpiresAt: time.Now().Add(time.Minute)},
+			expectedErr: ErrAccessDenied,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			d := &deviceCodeRepository{}
+			d.On("GetDeviceCode", "code").Return(tc.deviceCode, tc.err)
+			d.On("PollDeviceCode", "", mock.AnythingOfType("time.Duration"), mock.AnythingOfType("time.Time")).Return(nil)
+			d.On("DeleteDeviceCode", mock.Anything).Return(nil)
+
+			s := NewService(&repository{}, nil, testConfig)
+			s.DeviceCodeRepository = d
+
+			// When
+			_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+
+			// Then
+			require.True(t, errors.Is(err, tc.expectedErr))
+		})
+	}
+}
+
+func TestToken_DeviceCodeAlreadyConsumedError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode: "code",
+		ClientID:   "cli",
+		Status:     DeviceCodeStatusApproved,
+		UserID:     u.ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}, nil)
+	d.On("ConsumeDeviceCode", "code").Return(fmt.Errorf("%w: db not found", internal.ErrResourceNotFound))
+
+	sr := &sessionRepository{}
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+	s.SessionRepository = sr
+
+	// When
+	_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+
+	// Then
+	require.Equal(t, ErrInvalidGrant, err)
+	sr.AssertNotCalled(t, "SaveSession", mock.Anything)
+}
+
+func TestToken_DeviceCodePasswordResetRequiredError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com", PasswordResetRequired: true}
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode: "code",
+		ClientID:   "cli",
+		Status:     DeviceCodeStatusApproved,
+		UserID:     u.ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+
+	// Then
+	require.Equal(t, ErrAccessDenied, err)
+	d.AssertNotCalled(t, "ConsumeDeviceCode", mock.Anything)
+}
+
+func TestToken_SlowDownIncreasesInterval(t *testing.T) {
+	// Given
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode:   "code",
+		ClientID:     "cli",
+		Status:       DeviceCodeStatusPending,
+		Interval:     5 * time.Second,
+		ExpiresAt:    time.Now().Add(time.Minute),
+		LastPolledAt: time.Now(),
+	}, nil)
+	d.On("PollDeviceCode", "code", 10*time.Second, mock.AnythingOfType("time.Time")).Return(nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+
+	// Then
+	require.Equal(t, ErrSlowDown, err)
+	d.AssertExpectations(t)
+}
+
+func TestToken_DeviceCodeDecidedWhilePolling(t *testing.T) {
+	// Given
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode: "code",
+		ClientID:   "cli",
+		Status:     DeviceCodeStatusPending,
+		Interval:   5 * time.Second,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}, nil)
+	d.On("PollDeviceCode", "code", 5*time.Second, mock.AnythingOfType("time.Time")).Return(fmt.Errorf("%w: db not found", internal.ErrResourceNotFound))
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.DeviceCodeRepository = d
+
+	// When
+	_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+
+	// Then
+	require.Equal(t, ErrAuthorizationPending, err)
+}
+
+func TestToken_DeviceCodeScope(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	d := &deviceCodeRepository{}
+	d.On("GetDeviceCode", "code").Return(DeviceCode{
+		DeviceCode: "code",
+		ClientID:   "cli",
+		Scope:      PermissionUsersRead,
+		Status:     DeviceCodeStatusApproved,
+		UserID:     u.ID,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}, nil)
+	d.On("ConsumeDeviceCode", "code").Return(nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{{Name: "admin", Permissions: []string{PermissionUsersRead, PermissionUsersWrite}}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.DeviceCodeRepository = d
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: deviceCodeGrantType, DeviceCode: "code", ClientID: "cli"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, PermissionUsersRead, resp.Scope)
+
+	user, err := s.AuthorizeWithRoles(context.Background(), Origin{}, resp.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []string{PermissionUsersRead}, user.Permissions)
+}
+
+func TestToken_UnsupportedGrantType(t *testing.T) {
+	// Given
+	s := NewService(&repository{}, nil, testConfig)
+
+	// When
+	_, err := s.Token(context.Background(), Origin{}, TokenRequest{GrantType: "password"})
+
+	// Then
+	require.Equal(t, ErrUnsupportedGrantType, err)
+}
@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -16,6 +18,7 @@ const (
 	getHome                    = "/"
 	getPing                    = "/ping"
 	getMe                      = "/users/me"
+	patchMe                    = "/users/me"
 	postUsers                  = "/users"
 	getLogout                  = "/logout"
 	getLoginWithGoogle         = "/login/google"
@@ -26,14 +29,33 @@ var _v = validator.New()
 
 type Wrapper interface {
 	Wrap(method string, pattern string, handler server.HandlerFunc)
+	WrapWithPermissions(method string, pattern string, permissions []string, handler server.HandlerFunc)
 }
 
 type Handler struct {
 	Wrapper
+	// TrustedProxies are the networks whose X-Forwarded-For header is believed.
+	TrustedProxies []*net.IPNet
 }
 
 func NewHandler(wrapper Wrapper) *Handler {
-	return &Handler{wrapper}
+	return &Handler{Wrapper: wrapper}
+}
+
+// Wrap registers handler with the request origin in its context, so every audited call
+// made while serving it records where the request came from.
+func (h *Handler) Wrap(method string, pattern string, handler server.HandlerFunc) {
+	h.Wrapper.Wrap(method, pattern, h.withRequestOrigin(handler))
+}
+
+func (h *Handler) WrapWithPermissions(method string, pattern string, permissions []string, handler server.HandlerFunc) {
+	h.Wrapper.WrapWithPermissions(method, pattern, permissions, h.withRequestOrigin(handler))
+}
+
+func (h *Handler) withRequestOrigin(handler server.HandlerFunc) server.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return handler(w, r.WithContext(withOrigin(r.Context(), requestOrigin(r, h.TrustedProxies))))
+	}
 }
 
 func (h *Handler) Ping() {
@@ -51,9 +73,11 @@ type RegisterRequest struct {
 	Lastname  string `json:"lastname" validate:"required"`
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required,min=8"`
+
+	InviteToken string `json:"invite_token" validate:"max=128"`
 }
 
-type RegisterHandler func(req RegisterRequest) error
+type RegisterHandler func(ctx context.Context, origin Origin, req RegisterRequest) error
 
 func (h *Handler) RouteRegister(handler RegisterHandler) {
 	wrapH := func(w http.ResponseWriter, r *http.Request) error {
@@ -66,7 +90,7 @@ func (h *Handler) RouteRegister(handler RegisterHandler) {
 			return fmt.Errorf("validating request: %w", err)
 		}
 
-		if err := handler(req); err != nil {
+		if err := handler(r.Context(), originFromContext(r.Context()), req); err != nil {
 			return err
 		}
 
@@ -126,7 +150,7 @@ func (h *Handler) RouteLoginWithGoogle(handler LoginWithGoogleHandler) {
 	h.Wrap(http.MethodGet, getLoginWithGoogle, wrapH)
 }
 
-type LoginWithGoogleCallbackHandler func(code string) (string, error)
+type LoginWithGoogleCallbackHandler func(ctx context.Context, origin Origin, code string) (string, error)
 
 func (h *Handler) RouteLoginWithGoogleCallback(handler LoginWithGoogleCallbackHandler) {
 	wrapH := func(w http.ResponseWriter, r *http.Request) error {
@@ -135,19 +159,12 @@ func (h *Handler) RouteLoginWithGoogleCallback(handler LoginWithGoogleCallbackHa
 			return fmt.Errorf("%w: code is required", internal.ErrBadRequest)
 		}
 
-		token, err := handler(code)
+		token, err := handler(r.Context(), originFromContext(r.Context()), code)
 		if err != nil {
 			return err
 		}
 
-		c := &http.Cookie{
-			Name:     "authorization",
-			Value:    token,
-			Path:     getHome,
-			HttpOnly: true,
-		}
-
-		http.SetCookie(w, c)
+		http.SetCookie(w, authorizationCookie(token))
 
 		return internal.RespondJSON(w, nil, http.StatusOK)
 	}
@@ -155,8 +172,15 @@ func (h *Handler) RouteLoginWithGoogleCallback(handler LoginWithGoogleCallbackHa
 	h.Wrap(http.MethodGet, getLoginWithGoogleCallback, wrapH)
 }
 
-func (h *Handler) RouteLogout() {
+type LogoutHandler func(ctx context.Context, origin Origin, token string) error
+
+func (h *Handler) RouteLogout(handler LogoutHandler) {
 	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, _ := authorizationToken(r)
+		if err := handler(r.Context(), originFromContext(r.Context()), token); err != nil {
+			return err
+		}
+
 		c := &http.Cookie{
 			Name:    "authorization",
 			Expires: time.Now().Add(-1 * time.Hour),
@@ -171,22 +195,158 @@ func (h *Handler) RouteLogout() {
 	h.Wrap(http.MethodGet, getLogout, wrapH)
 }
 
-type AuthorizeMeHandler func(token string) (User, error)
+type AuthorizeMeHandler func(ctx context.Context, origin Origin, token string) (User, error)
+
+func NewAuthorizer(handler AuthorizeMeHandler, trustedProxies []*net.IPNet) server.Authorizer {
+	return func(r *http.Request) (server.Principal, error) {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(r.Context(), requestOrigin(r, trustedProxies), token)
+	}
+}
 
 func (h *Handler) RouteMe(handler AuthorizeMeHandler) {
 	wrapH := func(w http.ResponseWriter, r *http.Request) error {
-		c, err := r.Cookie("authorization")
+		token, err := authorizationToken(r)
 		if err != nil {
-			return fmt.Errorf("%w: authorization cookie is required", internal.ErrInvalidToken)
+			return err
 		}
 
-		user, err := handler(c.Value)
+		user, err := handler(r.Context(), originFromContext(r.Context()), token)
 		if err != nil {
 			return err
 		}
 
+		if etag := UserETag(user); etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+
 		return internal.RespondJSON(w, user, http.StatusOK)
 	}
 
 	h.Wrap(http.MethodGet, getMe, wrapH)
 }
+
+type UpdateMeRequest struct {
+	Firstname *string `json:"firstname" validate:"omitempty,min=1,max=128"`
+	Lastname  *string `json:"lastname" validate:"omitempty,min=1,max=128"`
+}
+
+type UpdateMeHandler func(ctx context.Context, token string, etag string, req UpdateMeRequest) (User, error)
+
+func (h *Handler) RouteUpdateMe(handler UpdateMeHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		etag := r.Header.Get("If-Match")
+		if etag == "" {
+			return fmt.Errorf("%w: If-Match header is required", internal.ErrPreconditionRequired)
+		}
+
+		var req UpdateMeRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		user, err := handler(r.Context(), token, etag, req)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", UserETag(user))
+
+		return internal.RespondJSON(w, user, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPatch, patchMe, wrapH)
+}
+
+// requestOrigin attributes r to its remote address. X-Forwarded-For can be sent by any client,
+// so it's only followed while the hops that appended to it are trusted proxies.
+func requestOrigin(r *http.Request, trustedProxies []*net.IPNet) Origin {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	if trustedProxy(ip, trustedProxies) {
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				break
+			}
+
+			ip = hop
+			if !trustedProxy(hop, trustedProxies) {
+				break
+			}
+		}
+	}
+
+	return Origin{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+func trustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func authorizationCookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     "authorization",
+		Value:    token,
+		Path:     getHome,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func authorizationToken(r *http.Request) (string, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token := strings.TrimPrefix(h, "Bearer ")
+		if token == h || token == "" {
+			return "", fmt.Errorf("%w: authorization header must use the bearer scheme", internal.ErrInvalidToken)
+		}
+
+		return token, nil
+	}
+
+	c, err := r.Cookie("authorization")
+	if err != nil {
+		return "", fmt.Errorf("%w: authorization cookie is required", internal.ErrInvalidToken)
+	}
+
+	return c.Value, nil
+}
+
+func decodeAndValidate(r *http.Request, req interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return fmt.Errorf("decoding request: %w: %v", internal.ErrUnprocessableEntity, err)
+	}
+
+	if err := _v.Struct(req); err != nil {
+		return fmt.Errorf("validating request: %w: %v", internal.ErrUnprocessableEntity, err)
+	}
+
+	return nil
+}
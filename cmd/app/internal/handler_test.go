@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,15 +13,16 @@ import (
 	"time"
 
 	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
 func TestHandler_RouteRegister(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteRegister(func(_ RegisterRequest) error {
+	h.RouteRegister(func(_ context.Context, _ Origin, _ RegisterRequest) error {
 		return nil
 	})
 
@@ -48,10 +50,10 @@ func TestHandler_RouteRegister(t *testing.T) {
 
 func TestHandler_RouteRegister_UnprocessableEntityError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteRegister(func(_ RegisterRequest) error {
+	h.RouteRegister(func(_ context.Context, _ Origin, _ RegisterRequest) error {
 		return nil
 	})
 
@@ -78,10 +80,10 @@ func TestHandler_RouteRegister_UnprocessableEntityError(t *testing.T) {
 
 func TestHandler_RouteRegister_PasswordMinLengthError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteRegister(func(_ RegisterRequest) error {
+	h.RouteRegister(func(_ context.Context, _ Origin, _ RegisterRequest) error {
 		return nil
 	})
 
@@ -111,10 +113,10 @@ func TestHandler_RouteRegister_PasswordMinLengthError(t *testing.T) {
 
 func TestHandler_RouteRegister_WeakPasswordError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteRegister(func(_ RegisterRequest) error {
+	h.RouteRegister(func(_ context.Context, _ Origin, _ RegisterRequest) error {
 		return nil
 	})
 
@@ -144,10 +146,10 @@ func TestHandler_RouteRegister_WeakPasswordError(t *testing.T) {
 
 func TestHandler_RouteRegister_HandlerError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteRegister(func(_ RegisterRequest) error {
+	h.RouteRegister(func(_ context.Context, _ Origin, _ RegisterRequest) error {
 		return errors.New("internal server error")
 	})
 
@@ -177,7 +179,7 @@ func TestHandler_RouteRegister_HandlerError(t *testing.T) {
 
 func TestHandler_RouteLoginWithGoogle(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
 	h.RouteLoginWithGoogle(func() (string, error) {
@@ -201,7 +203,7 @@ func TestHandler_RouteLoginWithGoogle(t *testing.T) {
 
 func TestHandler_RouteLoginWithGoogle_HandlerError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
 	h.RouteLoginWithGoogle(func() (string, error) {
@@ -227,10 +229,10 @@ func TestHandler_RouteLoginWithGoogle_HandlerError(t *testing.T) {
 
 func TestHandler_RouteLoginWithGoogleCallback(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteLoginWithGoogleCallback(func(code string) (string, error) {
+	h.RouteLoginWithGoogleCallback(func(_ context.Context, _ Origin, code string) (string, error) {
 		return "token", nil
 	})
 
@@ -262,10 +264,10 @@ func TestHandler_RouteLoginWithGoogleCallback(t *testing.T) {
 
 func TestHandler_RouteLoginWithGoogleCallback_MissingCodeError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteLoginWithGoogleCallback(func(code string) (string, error) {
+	h.RouteLoginWithGoogleCallback(func(_ context.Context, _ Origin, code string) (string, error) {
 		return "token", nil
 	})
 
@@ -288,10 +290,10 @@ func TestHandler_RouteLoginWithGoogleCallback_MissingCodeError(t *testing.T) {
 
 func TestHandler_RouteMe(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteMe(func(token string) (User, error) {
+	h.RouteMe(func(_ context.Context, _ Origin, token string) (User, error) {
 		return User{
 			ID:        "id",
 			Firstname: "luken",
@@ -336,10 +338,10 @@ func TestHandler_RouteMe(t *testing.T) {
 
 func TestHandler_RouteMe_MissingTokenError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteMe(func(token string) (User, error) {
+	h.RouteMe(func(_ context.Context, _ Origin, token string) (User, error) {
 		return User{
 			ID:        "id",
 			Firstname: "luken",
@@ -369,10 +371,10 @@ func TestHandler_RouteMe_MissingTokenError(t *testing.T) {
 
 func TestHandler_RouteMe_HandlerError(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteMe(func(token string) (User, error) {
+	h.RouteMe(func(_ context.Context, _ Origin, token string) (User, error) {
 		return User{}, errors.New("internal server error")
 	})
 
@@ -401,10 +403,13 @@ func TestHandler_RouteMe_HandlerError(t *testing.T) {
 
 func TestHandler_RouteLogout(t *testing.T) {
 	// Given
-	w := server.NewServer()
+	w := server.NewServer(config.Server{})
 	h := NewHandler(w)
 
-	h.RouteLogout()
+	h.RouteLogout(func(_ context.Context, origin Origin, token string) error {
+		require.Equal(t, "", token)
+		return nil
+	})
 
 	// When
 	ts := httptest.NewServer(w.Router)
@@ -431,6 +436,131 @@ func TestHandler_RouteLogout(t *testing.T) {
 	require.Less(t, cookie.MaxAge, 0)
 }
 
+func TestHandler_Wrap_RequestOrigin(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	var origin Origin
+	h.RouteListSessions(func(ctx context.Context, token string) ([]Session, error) {
+		origin = originFromContext(ctx)
+		return nil, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/me/sessions", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("User-Agent", "curl")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "127.0.0.1", origin.IP)
+	require.Equal(t, "curl", origin.UserAgent)
+}
+
+func TestHandler_RouteUpdateMe(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+	updatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h.RouteUpdateMe(func(_ context.Context, token string, etag string, req UpdateMeRequest) (User, error) {
+		require.Equal(t, "token", token)
+		require.Equal(t, `"1"`, etag)
+		require.Equal(t, "mateo", *req.Firstname)
+		require.Nil(t, req.Lastname)
+
+		return User{ID: "id", Firstname: *req.Firstname, UpdatedAt: &updatedAt}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/users/me", ts.URL), bytes.NewReader([]byte(`{"firstname": "mateo"}`)))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("If-Match", `"1"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, `"1577836800000"`, resp.Header.Get("ETag"))
+}
+
+func TestHandler_RouteUpdateMe_MissingIfMatchError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteUpdateMe(func(_ context.Context, token string, etag string, req UpdateMeRequest) (User, error) {
+		return User{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/users/me", ts.URL), bytes.NewReader([]byte(`{"firstname": "mateo"}`)))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusPreconditionRequired, resp.StatusCode)
+	require.Equal(t, "precondition required: If-Match header is required", m)
+}
+
+func TestHandler_RouteUpdateMe_UnprocessableEntityError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteUpdateMe(func(_ context.Context, token string, etag string, req UpdateMeRequest) (User, error) {
+		return User{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/users/me", ts.URL), bytes.NewReader([]byte(`{"firstname": ""}`)))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("If-Match", `"1"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
 func decodeErrorMessageFromBody(body io.ReadCloser) string {
 	var r struct {
 		Message string `json:"message"`
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	postAdminUserImpersonate = "/admin/users/{id}/impersonate"
+	deleteMeImpersonation    = "/users/me/impersonation"
+)
+
+type ImpersonateUserHandler func(ctx context.Context, origin Origin, token string, id string) (AccessToken, error)
+
+func (h *Handler) RouteImpersonateUser(handler ImpersonateUserHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), originFromContext(r.Context()), token, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminUserImpersonate, []string{PermissionUsersImpersonate}, wrapH)
+}
+
+type StopImpersonationHandler func(ctx context.Context, origin Origin, token string) error
+
+func (h *Handler) RouteStopImpersonation(handler StopImpersonationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		if err := handler(r.Context(), originFromContext(r.Context()), token); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMeImpersonation, wrapH)
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteImpersonateUser(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersImpersonate)
+	h := NewHandler(w)
+
+	h.RouteImpersonateUser(func(_ context.Context, origin Origin, token string, id string) (AccessToken, error) {
+		require.Equal(t, "token", token)
+		require.Equal(t, "id", id)
+
+		return AccessToken{AccessToken: "impersonated", TokenType: "Bearer", ExpiresIn: 300}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/users/id/impersonate", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r AccessToken
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "impersonated", r.AccessToken)
+}
+
+func TestHandler_RouteImpersonateUser_ForbiddenError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersWrite)
+	h := NewHandler(w)
+
+	h.RouteImpersonateUser(func(_ context.Context, origin Origin, token string, id string) (AccessToken, error) {
+		return AccessToken{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/users/id/impersonate", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestHandler_RouteStopImpersonation_RevokesToken(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, _ := newImpersonationService(admin, target, nil)
+	s.SessionRepository = NewSessionRepository(newSQLiteTestDB(t))
+
+	impersonation, err := s.ImpersonateUser(context.Background(), Origin{}, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+	h.RouteMe(s.AuthorizeWithRoles)
+	h.RouteStopImpersonation(s.StopImpersonation)
+
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	do := func(method string, path string) *http.Response {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s%s", ts.URL, path), nil)
+		req.Header.Set("Authorization", "Bearer "+impersonation.AccessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+		return resp
+	}
+
+	// When
+	before := do(http.MethodGet, "/users/me")
+	stop := do(http.MethodDelete, "/users/me/impersonation")
+	after := do(http.MethodGet, "/users/me")
+
+	// Then
+	require.Equal(t, http.StatusOK, before.StatusCode)
+	require.Equal(t, http.StatusNoContent, stop.StatusCode)
+	require.Equal(t, http.StatusUnauthorized, after.StatusCode)
+}
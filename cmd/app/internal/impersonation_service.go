@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	PermissionUsersImpersonate = "users:impersonate"
+)
+
+const (
+	AuditActionImpersonationStart = "user.impersonation.start"
+	AuditActionImpersonationStop  = "user.impersonation.stop"
+)
+
+const impersonationTokenExpiration = 5 * time.Minute
+
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+func (s *Service) ImpersonateUser(ctx context.Context, origin Origin, token string, id string) (AccessToken, error) {
+	admin, t, err := s.impersonateUser(ctx, origin, token, id)
+	event := AuditEvent{Actor: admin.ID, Action: AuditActionImpersonationStart, Target: id}
+	if err := s.audit(ctx, origin, event, err); err != nil {
+		return AccessToken{}, err
+	}
+
+	return AccessToken{
+		AccessToken: t,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(impersonationTokenExpiration.Seconds()),
+	}, nil
+}
+
+func (s *Service) impersonateUser(ctx context.Context, origin Origin, token string, id string) (User, string, error) {
+	admin, err := s.Authorize(ctx, token)
+	if err != nil {
+		return User{}, "", err
+	}
+
+	if admin.scoped {
+		return admin, "", fmt.Errorf("%w: personal access tokens can't impersonate users", internal.ErrForbidden)
+	}
+
+	if err := ensureNotImpersonated(admin); err != nil {
+		return admin, "", err
+	}
+
+	if admin.ID == id {
+		return admin, "", fmt.Errorf("%w: can't impersonate yourself", internal.ErrBadRequest)
+	}
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return admin, "", err
+	}
+
+	if err := ensureActive(user); err != nil {
+		return admin, "", err
+	}
+
+	user, err = s.withRoles(ctx, user)
+	if err != nil {
+		return admin, "", err
+	}
+
+	if user.HasPermission(PermissionUsersImpersonate) {
+		return admin, "", fmt.Errorf("%w: can't impersonate another administrator", internal.ErrForbidden)
+	}
+
+	user.Actor = &Actor{Subject: admin.ID}
+
+	user.sessionID, err = s.startSession(ctx, origin, user, impersonationTokenExpiration)
+	if err != nil {
+		return admin, "", err
+	}
+
+	t, err := s.issueToken(ctx, user)
+	if err != nil {
+		return admin, "", fmt.Errorf("authorizing user: %v", err)
+	}
+
+	return admin, t, nil
+}
+
+// StopImpersonation ends the impersonation session, so its token is rejected from now on.
+func (s *Service) StopImpersonation(ctx context.Context, origin Origin, token string) error {
+	user, err := s.authorize(ctx, origin, token)
+	if err != nil {
+		return err
+	}
+
+	if user.Actor == nil {
+		return fmt.Errorf("%w: session is not impersonating a user", internal.ErrBadRequest)
+	}
+
+	// The impersonation token stays valid until it expires unless its session is revoked.
+	if err := s.revokeCurrentSession(ctx, user); err != nil {
+		return err
+	}
+
+	return s.audit(ctx, origin, AuditEvent{Actor: user.Actor.Subject, Action: AuditActionImpersonationStop, Target: user.ID}, nil)
+}
+
+func (s *Service) impersonationActor(ctx context.Context, act map[string]interface{}) (Actor, error) {
+	subject, _ := act["sub"].(string)
+	if subject == "" {
+		return Actor{}, internal.ErrAlteredTokenClaims
+	}
+
+	admin, err := s.UserRepository.GetUserByID(ctx, subject)
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return Actor{}, fmt.Errorf("%w: impersonating user no longer exists", internal.ErrInvalidToken)
+	}
+
+	if err != nil {
+		return Actor{}, err
+	}
+
+	if err := ensureActive(admin); err != nil {
+		return Actor{}, err
+	}
+
+	return Actor{Subject: admin.ID}, nil
+}
+
+func ensureNotImpersonated(user User) error {
+	if user.Actor != nil {
+		return fmt.Errorf("%w: not allowed while impersonating a user", internal.ErrForbidden)
+	}
+
+	return nil
+}
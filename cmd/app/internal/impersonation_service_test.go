@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newImpersonationService(admin User, target User, targetPermissions []string) (*Service, *auditRepository) {
+	r := &repository{}
+	r.On("GetUserByEmail", admin.Email).Return(admin, nil)
+	r.On("GetUserByEmail", target.Email).Return(target, nil)
+	r.On("GetUserByID", admin.ID).Return(admin, nil)
+	r.On("GetUserByID", target.ID).Return(target, nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", target.ID).Return([]Role{{Name: "member", Permissions: targetPermissions}}, nil)
+
+	ar := &auditRepository{}
+	ar.On("AppendAuditEvent", mock.AnythingOfType("AuditEvent")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = rr
+	s.AuditRepository = ar
+
+	return s, ar
+}
+
+func TestImpersonateUser(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, ar := newImpersonationService(admin, target, nil)
+
+	// When
+	resp, err := s.ImpersonateUser(context.Background(), Origin{IP: "127.0.0.1"}, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.Authorize(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.Equal(t, int(impersonationTokenExpiration.Seconds()), resp.ExpiresIn)
+	require.Equal(t, target.ID, user.ID)
+	require.Equal(t, &Actor{Subject: admin.ID}, user.Actor)
+	require.Equal(t, AuditActionImpersonationStart, event.Action)
+	require.Equal(t, admin.ID, event.Actor)
+	require.Equal(t, target.ID, event.Target)
+	require.Equal(t, AuditOutcomeSuccess, event.Outcome)
+}
+
+func TestImpersonateUser_AdministratorError(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, ar := newImpersonationService(admin, target, []string{PermissionUsersImpersonate})
+
+	// When
+	_, err := s.ImpersonateUser(context.Background(), Origin{}, token, target.ID)
+
+	// Then
+	event := ar.Calls[0].Arguments.Get(0).(AuditEvent)
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: can't impersonate another administrator")
+	require.Equal(t, AuditOutcomeFailure, event.Outcome)
+}
+
+func TestImpersonateUser_YourselfError(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, _ := newImpersonationService(admin, User{ID: "id"}, nil)
+
+	// When
+	_, err := s.ImpersonateUser(context.Background(), Origin{}, token, admin.ID)
+
+	// Then
+	require.EqualError(t, err, "bad request: can't impersonate yourself")
+}
+
+func TestImpersonatedSession_SensitiveEndpointsError(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, _ := newImpersonationService(admin, target, nil)
+
+	resp, err := s.ImpersonateUser(context.Background(), Origin{}, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	_, deleteErr := s.DeleteMe(context.Background(), resp.AccessToken, DeleteMeRequest{Password: "Password1!"})
+	_, patErr := s.CreatePersonalAccessToken(context.Background(), resp.AccessToken, CreatePersonalAccessTokenRequest{Name: "ci"})
+	_, impersonateErr := s.ImpersonateUser(context.Background(), Origin{}, resp.AccessToken, "another")
+	_, updateErr := s.UpdateMe(context.Background(), resp.AccessToken, "*", UpdateMeRequest{Firstname: &target.Firstname})
+
+	// Then
+	for _, err := range []error{deleteErr, patErr, impersonateErr, updateErr} {
+		require.EqualError(t, err, "can't access to the resource. insufficient permissions: not allowed while impersonating a user")
+	}
+}
+
+func TestImpersonatedSession_DisabledAdminError(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, _ := newImpersonationService(admin, target, nil)
+
+	resp, err := s.ImpersonateUser(context.Background(), Origin{}, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &repository{}
+	r.On("GetUserByEmail", target.Email).Return(target, nil)
+	r.On("GetUserByID", admin.ID).Return(User{ID: admin.ID, Status: UserStatusDisabled}, nil)
+	s.UserRepository = r
+
+	// When
+	_, err = s.Authorize(context.Background(), resp.AccessToken)
+
+	// Then
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: user is disabled")
+}
+
+func TestStopImpersonation(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	target := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, ar := newImpersonationService(admin, target, nil)
+
+	resp, err := s.ImpersonateUser(context.Background(), Origin{}, token, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	err = s.StopImpersonation(context.Background(), Origin{}, resp.AccessToken)
+
+	// Then
+	event := ar.Calls[1].Arguments.Get(0).(AuditEvent)
+	require.NoError(t, err)
+	require.Equal(t, AuditActionImpersonationStop, event.Action)
+	require.Equal(t, admin.ID, event.Actor)
+	require.Equal(t, target.ID, event.Target)
+}
+
+func TestStopImpersonation_NotImpersonatingError(t *testing.T) {
+	// Given
+	admin := User{ID: "admin", Email: "admin@gmail.com"}
+	token, _ := _newJWT(admin)
+
+	s, _ := newImpersonationService(admin, User{ID: "id"}, nil)
+
+	// When
+	err := s.StopImpersonation(context.Background(), Origin{}, token)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrBadRequest))
+}
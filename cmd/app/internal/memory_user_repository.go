@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+// MemoryUserRepository keeps users in process memory. It's safe for concurrent use and meant
+// for local development and tests: nothing survives a restart.
+type MemoryUserRepository struct {
+	// db holds the tokens, sessions, roles and memberships DeleteUser removes along with the user.
+	// It's nil when users are the only data, as in tests.
+	db *DB
+
+	mu      sync.RWMutex
+	users   map[string]*memoryUser
+	byEmail map[string]string
+	seq     int64
+}
+
+type memoryUser struct {
+	user
+	seq int64
+}
+
+func NewMemoryUserRepository(db *DB) Repository {
+	return &MemoryUserRepository{
+		db:      db,
+		users:   make(map[string]*memoryUser),
+		byEmail: make(map[string]string),
+	}
+}
+
+func (r *MemoryUserRepository) SaveUser(_ context.Context, newUser NewUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byEmail[newUser.Email]; ok {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	if _, ok := r.users[newUser.ID]; ok {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	now := time.Now()
+	r.seq++
+	r.users[newUser.ID] = &memoryUser{
+		user: user{
+			ID:        newUser.ID,
+			Firstname: newUser.Firstname,
+			Lastname:  newUser.Lastname,
+			Status:    UserStatusActive,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Email:     newUser.Email,
+			Password:  newUser.Password,
+		},
+		seq: r.seq,
+	}
+	r.byEmail[newUser.Email] = newUser.ID
+
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByEmail(_ context.Context, email string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[r.byEmail[email]]
+	if !ok {
+		return User{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return u.toUser(), nil
+}
+
+func (r *MemoryUserRepository) GetUserByID(_ context.Context, id string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return User{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return u.toUser(), nil
+}
+
+func (r *MemoryUserRepository) FindUserByEmail(_ context.Context, email string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.byEmail[email]; !ok {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUsers(_ context.Context, query UserQuery) ([]User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []*memoryUser
+	for _, u := range r.users {
+		if matchesUserQuery(u.user, query) {
+			matches = append(matches, u)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.After(matches[j].CreatedAt)
+		}
+
+		return matches[i].seq > matches[j].seq
+	})
+
+	total := len(matches)
+	if query.Offset > len(matches) {
+		matches = nil
+	} else {
+		matches = matches[query.Offset:]
+	}
+
+	if query.Limit < len(matches) {
+		matches = matches[:query.Limit]
+	}
+
+	resp := make([]User, 0, len(matches))
+	for _, u := range matches {
+		resp = append(resp, u.toUser())
+	}
+
+	return resp, total, nil
+}
+
+func matchesUserQuery(u user, query UserQuery) bool {
+	if query.Search != "" {
+		search := strings.ToLower(query.Search)
+		if !strings.Contains(strings.ToLower(u.Email), search) &&
+			!strings.Contains(strings.ToLower(u.Firstname), search) &&
+			!strings.Contains(strings.ToLower(u.Lastname), search) {
+			return false
+		}
+	}
+
+	if query.Status != "" && u.Status != query.Status {
+		return false
+	}
+
+	if query.CreatedAfter != nil && u.CreatedAt.Before(*query.CreatedAfter) {
+		return false
+	}
+
+	if query.CreatedBefore != nil && !u.CreatedAt.Before(*query.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func (r *MemoryUserRepository) UpdateUserStatus(_ context.Context, id string, status string) error {
+	return r.update(id, func(u *memoryUser) {
+		u.Status = status
+	})
+}
+
+func (r *MemoryUserRepository) RequirePasswordReset(_ context.Context, id string) error {
+	return r.update(id, func(u *memoryUser) {
+		u.PasswordResetRequired = true
+	})
+}
+
+func (r *MemoryUserRepository) ResetPassword(_ context.Context, id string, password string) error {
+	return r.update(id, func(u *memoryUser) {
+		u.Password = password
+		u.PasswordResetRequired = false
+	})
+}
+
+func (r *MemoryUserRepository) ScheduleUserDeletion(_ context.Context, id string, deleteAfter *time.Time) error {
+	return r.update(id, func(u *memoryUser) {
+		u.DeleteAfter = nullTimeFromTime(deleteAfter)
+	})
+}
+
+func (r *MemoryUserRepository) update(id string, apply func(u *memoryUser)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	apply(u)
+	u.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(_ context.Context, updated User, previousUpdatedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[updated.ID]
+	if !ok || !u.UpdatedAt.Equal(previousUpdatedAt) {
+		return fmt.Errorf("%w: user has been modified", internal.ErrPreconditionFailed)
+	}
+
+	u.Firstname = updated.Firstname
+	u.Lastname = updated.Lastname
+	if updated.UpdatedAt != nil {
+		u.UpdatedAt = *updated.UpdatedAt
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserPassword(_ context.Context, id string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return "", fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return u.Password, nil
+}
+
+func (r *MemoryUserRepository) GetUsersScheduledForDeletion(_ context.Context, now time.Time) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []string
+	for id, u := range r.users {
+		if u.DeleteAfter.Valid && !u.DeleteAfter.Time.After(now) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+var memoryDeleteUserData = []string{
+	`DELETE FROM personal_access_token WHERE user_id = :id`,
+	`DELETE FROM user_session WHERE user_id = :id`,
+	`DELETE FROM user_role WHERE user_id = :id`,
+	`DELETE FROM organization_member WHERE user_id = :id`,
+}
+
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	if err := r.deleteUserData(ctx, id); err != nil {
+		return err
+	}
+
+	delete(r.byEmail, u.Email)
+	delete(r.users, id)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) deleteUserData(ctx context.Context, id string) (err error) {
+	if r.db == nil {
+		return nil
+	}
+
+	ctx, end := r.db.start(ctx, "DeleteUser")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{"id": id}
+	for _, query := range memoryDeleteUserData {
+		if _, err = tx.NamedExecContext(ctx, query, queryParams); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
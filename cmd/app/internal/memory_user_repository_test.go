@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUserRepository_SaveUser(t *testing.T) {
+	// Given
+	r := NewMemoryUserRepository(nil)
+	newUser := NewUser{ID: "id", Firstname: "mateo", Lastname: "ferrari coronel", Email: "mateo.ferrari97@gmail.com", Password: "hash"}
+
+	// When
+	err := r.SaveUser(context.Background(), newUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.GetUserByEmail(context.Background(), newUser.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "id", resp.ID)
+	require.Equal(t, UserStatusActive, resp.Status)
+	require.NotNil(t, resp.CreatedAt)
+	require.True(t, errors.Is(r.SaveUser(context.Background(), newUser), internal.ErrResourceAlreadyExists))
+}
+
+func TestMemoryUserRepository_GetUsers(t *testing.T) {
+	// Given
+	r := NewMemoryUserRepository(nil)
+	for _, u := range []NewUser{
+		{ID: "1", Firstname: "mateo", Lastname: "ferrari", Email: "mateo@gmail.com"},
+		{ID: "2", Firstname: "juan", Lastname: "perez", Email: "juan@gmail.com"},
+		{ID: "3", Firstname: "Mateo", Lastname: "gomez", Email: "mgomez@gmail.com"},
+	} {
+		if err := r.SaveUser(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// When
+	resp, total, err := r.GetUsers(context.Background(), UserQuery{Search: "mateo", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 2, total)
+	require.Len(t, resp, 1)
+	require.Equal(t, "3", resp[0].ID)
+}
+
+func TestMemoryUserRepository_UpdateUser_PreconditionFailed(t *testing.T) {
+	// Given
+	r := NewMemoryUserRepository(nil)
+	if err := r.SaveUser(context.Background(), NewUser{ID: "id", Email: "mateo@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	// When
+	err := r.UpdateUser(context.Background(), User{ID: "id", Firstname: "mateo", UpdatedAt: &now}, now.Add(-time.Hour))
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrPreconditionFailed))
+}
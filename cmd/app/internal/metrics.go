@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const tracerName = "github.com/mateoferrari97/auth/cmd/app/internal"
+
+const (
+	LoginProviderGoogle = "google"
+	LoginProviderDevice = "device"
+)
+
+const (
+	metricsOutcomeSuccess = "success"
+	metricsOutcomeFailure = "failure"
+	metricsOutcomePending = "pending"
+)
+
+type Metrics struct {
+	registrations     *prometheus.CounterVec
+	logins            *prometheus.CounterVec
+	authorizeFailures *prometheus.CounterVec
+	queryDuration     *prometheus.HistogramVec
+}
+
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_registrations_total",
+			Help: "Registrations by outcome.",
+		}, []string{"outcome"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_logins_total",
+			Help: "Logins by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		authorizeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_authorize_failures_total",
+			Help: "Rejected tokens by error kind.",
+		}, []string{"kind"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "auth_repository_query_duration_seconds",
+			Help:    "Latency of repository calls by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+
+	registerer.MustRegister(m.registrations, m.logins, m.authorizeFailures, m.queryDuration)
+
+	return m
+}
+
+func (m *Metrics) registration(err error) {
+	if m == nil {
+		return
+	}
+
+	m.registrations.WithLabelValues(outcome(err)).Inc()
+}
+
+func (m *Metrics) login(provider string, err error) {
+	if m == nil {
+		return
+	}
+
+	o := outcome(err)
+	if errors.Is(err, ErrAuthorizationPending) || errors.Is(err, ErrSlowDown) {
+		o = metricsOutcomePending
+	}
+
+	m.logins.WithLabelValues(provider, o).Inc()
+}
+
+func (m *Metrics) authorizeFailure(err error) {
+	if m == nil || err == nil {
+		return
+	}
+
+	m.authorizeFailures.WithLabelValues(errorKind(err)).Inc()
+}
+
+func (m *Metrics) observeQuery(method string, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.queryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return metricsOutcomeFailure
+	}
+
+	return metricsOutcomeSuccess
+}
+
+func errorKind(err error) string {
+	switch {
+	case isMalformedToken(err):
+		return "malformed_token"
+	case errors.Is(err, internal.ErrInvalidToken), errors.Is(err, internal.ErrRevokedSession):
+		return "invalid_token"
+	case errors.Is(err, internal.ErrAlteredTokenClaims):
+		return "altered_claims"
+	case errors.Is(err, internal.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, internal.ErrResourceNotFound):
+		return "not_found"
+	default:
+		return "other"
+	}
+}
+
+type dbStatsCollector struct {
+	db *sql.DB
+
+	openConnections *prometheus.Desc
+	inUse           *prometheus.Desc
+	idle            *prometheus.Desc
+	waitCount       *prometheus.Desc
+	waitDuration    *prometheus.Desc
+}
+
+// NewDBStatsCollector exposes the connection pool stats of db.
+func NewDBStatsCollector(db *sql.DB) prometheus.Collector {
+	return &dbStatsCollector{
+		db:              db,
+		openConnections: prometheus.NewDesc("auth_db_open_connections", "Established connections, in use and idle.", nil, nil),
+		inUse:           prometheus.NewDesc("auth_db_in_use_connections", "Connections currently in use.", nil, nil),
+		idle:            prometheus.NewDesc("auth_db_idle_connections", "Idle connections.", nil, nil),
+		waitCount:       prometheus.NewDesc("auth_db_wait_count_total", "Connections waited for.", nil, nil),
+		waitDuration:    prometheus.NewDesc("auth_db_wait_duration_seconds_total", "Time blocked waiting for a connection.", nil, nil),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.openConnections
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
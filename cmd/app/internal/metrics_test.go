@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Register(t *testing.T) {
+	// Given
+	m := NewMetrics(prometheus.NewRegistry())
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(internal.ErrResourceAlreadyExists)
+
+	s := NewService(r, nil, testConfig)
+	s.Metrics = m
+
+	// When
+	_ = s.Register(context.Background(), Origin{}, RegisterRequest{Email: "mateo.ferrari97@gmail.com"})
+
+	// Then
+	require.Equal(t, float64(1), testutil.ToFloat64(m.registrations.WithLabelValues(metricsOutcomeFailure)))
+	require.Equal(t, float64(0), testutil.ToFloat64(m.registrations.WithLabelValues(metricsOutcomeSuccess)))
+}
+
+func TestMetrics_Login(t *testing.T) {
+	// Given
+	m := NewMetrics(prometheus.NewRegistry())
+
+	// When
+	m.login(LoginProviderDevice, ErrAuthorizationPending)
+	m.login(LoginProviderDevice, ErrSlowDown)
+	m.login(LoginProviderDevice, ErrExpiredToken)
+	m.login(LoginProviderGoogle, nil)
+
+	// Then
+	require.Equal(t, float64(2), testutil.ToFloat64(m.logins.WithLabelValues(LoginProviderDevice, metricsOutcomePending)))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.logins.WithLabelValues(LoginProviderDevice, metricsOutcomeFailure)))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.logins.WithLabelValues(LoginProviderGoogle, metricsOutcomeSuccess)))
+}
+
+func TestMetrics_AuthorizeFailure(t *testing.T) {
+	// Given
+	m := NewMetrics(prometheus.NewRegistry())
+	errs := map[error]string{
+		internal.ErrInvalidToken:                                     "invalid_token",
+		fmt.Errorf("%w: claims", internal.ErrAlteredTokenClaims):     "altered_claims",
+		fmt.Errorf("%w: user is disabled", internal.ErrForbidden):    "forbidden",
+		malformedTokenError{err: errors.New("signature is invalid")}: "malformed_token",
+		errors.New("db error"):                                       "other",
+	}
+
+	for err, kind := range errs {
+		// When
+		m.authorizeFailure(err)
+
+		// Then
+		require.Equal(t, float64(1), testutil.ToFloat64(m.authorizeFailures.WithLabelValues(kind)))
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	// Given
+	var m *Metrics
+
+	// When
+	m.registration(nil)
+	m.login(LoginProviderGoogle, nil)
+	m.authorizeFailure(internal.ErrInvalidToken)
+	m.observeQuery("GetUserByID", time.Now())
+}
+
+func TestNewDBStatsCollector(t *testing.T) {
+	// Given
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// When
+	count := testutil.CollectAndCount(NewDBStatsCollector(db))
+
+	// Then
+	require.Equal(t, 5, count)
+}
@@ -0,0 +1,229 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	postOrganizations                = "/organizations"
+	getOrganizations                 = "/organizations"
+	getOrganizationMembers           = "/organizations/{id}/members"
+	patchOrganizationMember          = "/organizations/{id}/members/{user_id}"
+	deleteOrganizationMember         = "/organizations/{id}/members/{user_id}"
+	postOrganizationInvitations      = "/organizations/{id}/invitations"
+	postOrganizationInvitationAccept = "/organizations/invitations/accept"
+	putMeOrganization                = "/users/me/organization"
+)
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+type CreateOrganizationHandler func(ctx context.Context, token string, req CreateOrganizationRequest) (Organization, error)
+
+func (h *Handler) RouteCreateOrganization(handler CreateOrganizationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req CreateOrganizationRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.Wrap(http.MethodPost, postOrganizations, wrapH)
+}
+
+type ListOrganizationsHandler func(ctx context.Context, token string) ([]UserOrganization, error)
+
+func (h *Handler) RouteListOrganizations(handler ListOrganizationsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getOrganizations, wrapH)
+}
+
+type ListMembersHandler func(ctx context.Context, token string, organizationID string) ([]Member, error)
+
+func (h *Handler) RouteListMembers(handler ListMembersHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getOrganizationMembers, wrapH)
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type UpdateMemberHandler func(ctx context.Context, token string, organizationID string, userID string, req UpdateMemberRequest) (Member, error)
+
+func (h *Handler) RouteUpdateMember(handler UpdateMemberHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req UpdateMemberRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		vars := mux.Vars(r)
+		resp, err := handler(r.Context(), token, vars["id"], vars["user_id"], req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPatch, patchOrganizationMember, wrapH)
+}
+
+type RemoveMemberHandler func(ctx context.Context, token string, organizationID string, userID string) error
+
+func (h *Handler) RouteRemoveMember(handler RemoveMemberHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		vars := mux.Vars(r)
+		if err := handler(r.Context(), token, vars["id"], vars["user_id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodDelete, deleteOrganizationMember, wrapH)
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type InviteMemberHandler func(ctx context.Context, token string, organizationID string, req InviteMemberRequest) (NewOrganizationInvitation, error)
+
+func (h *Handler) RouteInviteMember(handler InviteMemberHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req InviteMemberRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, mux.Vars(r)["id"], req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.Wrap(http.MethodPost, postOrganizationInvitations, wrapH)
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type AcceptInvitationHandler func(ctx context.Context, token string, invitationToken string) (Member, error)
+
+func (h *Handler) RouteAcceptInvitation(handler AcceptInvitationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req AcceptInvitationRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, req.Token)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPost, postOrganizationInvitationAccept, wrapH)
+}
+
+type SwitchOrganizationRequest struct {
+	OrganizationID string `json:"organization_id"`
+}
+
+type SwitchOrganizationHandler func(ctx context.Context, token string, organizationID string) (AccessToken, error)
+
+func (h *Handler) RouteSwitchOrganization(handler SwitchOrganizationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req SwitchOrganizationRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, req.OrganizationID)
+		if err != nil {
+			return err
+		}
+
+		http.SetCookie(w, authorizationCookie(resp.AccessToken))
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPut, putMeOrganization, wrapH)
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteCreateOrganization(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteCreateOrganization(func(_ context.Context, token string, req CreateOrganizationRequest) (Organization, error) {
+		require.Equal(t, "token", token)
+		require.Equal(t, "acme", req.Name)
+
+		return Organization{ID: "org", Name: req.Name}, nil
+	})
+
+	b := []byte(`{"name": "acme"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/organizations", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r Organization
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "org", r.ID)
+}
+
+func TestHandler_RouteUpdateMember_UnprocessableEntityError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteUpdateMember(func(_ context.Context, token string, organizationID string, userID string, req UpdateMemberRequest) (Member, error) {
+		return Member{}, nil
+	})
+
+	b := []byte(`{"role": "superuser"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/organizations/org/members/id", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandler_RouteRemoveMember_ForbiddenError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteRemoveMember(func(_ context.Context, token string, organizationID string, userID string) error {
+		require.Equal(t, "org", organizationID)
+		require.Equal(t, "id", userID)
+
+		return fmt.Errorf("%w: organization admin role is required", internal.ErrForbidden)
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/organizations/org/members/id", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestHandler_RouteAcceptInvitation(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteAcceptInvitation(func(_ context.Context, token string, invitationToken string) (Member, error) {
+		require.Equal(t, "secret", invitationToken)
+
+		return Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleMember}, nil
+	})
+
+	b := []byte(`{"token": "secret"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/organizations/invitations/accept", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandler_RouteSwitchOrganization(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteSwitchOrganization(func(_ context.Context, token string, organizationID string) (AccessToken, error) {
+		require.Equal(t, "org", organizationID)
+
+		return AccessToken{AccessToken: "new-token", TokenType: "Bearer"}, nil
+	})
+
+	b := []byte(`{"organization_id": "org"}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/users/me/organization", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "new-token", resp.Cookies()[0].Value)
+}
@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type OrganizationSQLRepository struct {
+	db *DB
+}
+
+func NewOrganizationRepository(db *DB) OrganizationRepository {
+	return &OrganizationSQLRepository{
+		db: db,
+	}
+}
+
+type userOrganization struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+	Role      string    `db:"role"`
+}
+
+type member struct {
+	OrganizationID string    `db:"organization_id"`
+	UserID         string    `db:"user_id"`
+	Role           string    `db:"role"`
+	JoinedAt       time.Time `db:"joined_at"`
+}
+
+func (m member) toMember() Member {
+	return Member{
+		OrganizationID: m.OrganizationID,
+		UserID:         m.UserID,
+		Role:           m.Role,
+		JoinedAt:       m.JoinedAt,
+	}
+}
+
+func memberParams(m Member) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": m.OrganizationID,
+		"user_id":         m.UserID,
+		"role":            m.Role,
+		"joined_at":       m.JoinedAt,
+	}
+}
+
+type organizationInvitation struct {
+	ID             string       `db:"id"`
+	OrganizationID string       `db:"organization_id"`
+	Email          string       `db:"email"`
+	Role           string       `db:"role"`
+	Hash           string       `db:"token_hash"`
+	InvitedBy      string       `db:"invited_by"`
+	ExpiresAt      time.Time    `db:"expires_at"`
+	AcceptedAt     sql.NullTime `db:"accepted_at"`
+	CreatedAt      time.Time    `db:"created_at"`
+}
+
+func (i organizationInvitation) toOrganizationInvitation() OrganizationInvitation {
+	return OrganizationInvitation{
+		ID:             i.ID,
+		OrganizationID: i.OrganizationID,
+		Email:          i.Email,
+		Role:           i.Role,
+		Hash:           i.Hash,
+		InvitedBy:      i.InvitedBy,
+		ExpiresAt:      i.ExpiresAt,
+		AcceptedAt:     timeFromNullTime(i.AcceptedAt),
+		CreatedAt:      i.CreatedAt,
+	}
+}
+
+const (
+	insertOrganization = `INSERT INTO organization (id, name, created_by, created_at)
+							VALUES (:id, :name, :created_by, :created_at)`
+	insertMember = `INSERT INTO organization_member (organization_id, user_id, role, joined_at)
+							VALUES (:organization_id, :user_id, :role, :joined_at)`
+)
+
+func (r *OrganizationSQLRepository) SaveOrganization(ctx context.Context, organization Organization, owner Member) (err error) {
+	ctx, end := r.db.start(ctx, "SaveOrganization")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	_, err = tx.NamedExecContext(ctx, insertOrganization, map[string]interface{}{
+		"id":         organization.ID,
+		"name":       organization.Name,
+		"created_by": organization.CreatedBy,
+		"created_at": organization.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.NamedExecContext(ctx, insertMember, memberParams(owner)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const getUserOrganizations = `SELECT organization.id, organization.name, organization.created_by, organization.created_at, organization_member.role
+							FROM organization_member
+							INNER JOIN organization
+							ON organization.id = organization_member.organization_id
+							WHERE organization_member.user_id = :user_id
+							ORDER BY organization.name`
+
+func (r *OrganizationSQLRepository) GetUserOrganizations(ctx context.Context, userID string) (_ []UserOrganization, err error) {
+	ctx, end := r.db.start(ctx, "GetUserOrganizations")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUserOrganizations)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var organizations []userOrganization
+	if err := stmt.SelectContext(ctx, &organizations, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+
+	resp := make([]UserOrganization, 0, len(organizations))
+	for _, o := range organizations {
+		resp = append(resp, UserOrganization{
+			Organization: Organization{
+				ID:        o.ID,
+				Name:      o.Name,
+				CreatedBy: o.CreatedBy,
+				CreatedAt: o.CreatedAt,
+			},
+			Role: o.Role,
+		})
+	}
+
+	return resp, nil
+}
+
+const getMembers = `SELECT organization_id, user_id, role, joined_at
+					FROM organization_member
+					WHERE organization_id = :organization_id
+					ORDER BY joined_at`
+
+func (r *OrganizationSQLRepository) GetMembers(ctx context.Context, organizationID string) (_ []Member, err error) {
+	ctx, end := r.db.start(ctx, "GetMembers")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var members []member
+	if err := stmt.SelectContext(ctx, &members, map[string]interface{}{"organization_id": organizationID}); err != nil {
+		return nil, err
+	}
+
+	resp := make([]Member, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, m.toMember())
+	}
+
+	return resp, nil
+}
+
+const getMember = `SELECT organization_id, user_id, role, joined_at
+					FROM organization_member
+					WHERE organization_id = :organization_id AND user_id = :user_id`
+
+func (r *OrganizationSQLRepository) GetMember(ctx context.Context, organizationID string, userID string) (_ Member, err error) {
+	ctx, end := r.db.start(ctx, "GetMember")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getMember)
+	if err != nil {
+		return Member{}, err
+	}
+
+	defer stmt.Close()
+
+	var m member
+	err = stmt.GetContext(ctx, &m, map[string]interface{}{"organization_id": organizationID, "user_id": userID})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Member{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Member{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return m.toMember(), nil
+}
+
+const updateMember = `UPDATE organization_member
+					SET role = :role
+					WHERE organization_id = :organization_id AND user_id = :user_id`
+
+func (r *OrganizationSQLRepository) UpdateMember(ctx context.Context, m Member) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateMember")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, updateMember, memberParams(m))
+	return err
+}
+
+const deleteMember = `DELETE FROM organization_member WHERE organization_id = :organization_id AND user_id = :user_id`
+
+func (r *OrganizationSQLRepository) DeleteMember(ctx context.Context, organizationID string, userID string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteMember")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, deleteMember, map[string]interface{}{"organization_id": organizationID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+const insertInvitation = `INSERT INTO organization_invitation (id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at)
+						VALUES (:id, :organization_id, :email, :role, :token_hash, :invited_by, :expires_at, :accepted_at, :created_at)`
+
+func (r *OrganizationSQLRepository) SaveInvitation(ctx context.Context, i OrganizationInvitation) (err error) {
+	ctx, end := r.db.start(ctx, "SaveInvitation")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertInvitation, map[string]interface{}{
+		"id":              i.ID,
+		"organization_id": i.OrganizationID,
+		"email":           i.Email,
+		"role":            i.Role,
+		"token_hash":      i.Hash,
+		"invited_by":      i.InvitedBy,
+		"expires_at":      i.ExpiresAt,
+		"accepted_at":     nullTimeFromTime(i.AcceptedAt),
+		"created_at":      i.CreatedAt,
+	})
+
+	return err
+}
+
+const getInvitationByHash = `SELECT id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at
+							FROM organization_invitation
+							WHERE token_hash = :token_hash`
+
+func (r *OrganizationSQLRepository) GetInvitationByHash(ctx context.Context, hash string) (_ OrganizationInvitation, err error) {
+	ctx, end := r.db.start(ctx, "GetInvitationByHash")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getInvitationByHash)
+	if err != nil {
+		return OrganizationInvitation{}, err
+	}
+
+	defer stmt.Close()
+
+	var i organizationInvitation
+	err = stmt.GetContext(ctx, &i, map[string]interface{}{"token_hash": hash})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return OrganizationInvitation{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrganizationInvitation{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return i.toOrganizationInvitation(), nil
+}
+
+const acceptInvitation = `UPDATE organization_invitation
+						SET accepted_at = :accepted_at
+						WHERE id = :id AND accepted_at IS NULL`
+
+func (r *OrganizationSQLRepository) AcceptInvitation(ctx context.Context, invitation OrganizationInvitation, m Member) (err error) {
+	ctx, end := r.db.start(ctx, "AcceptInvitation")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	result, err := tx.NamedExecContext(ctx, acceptInvitation, map[string]interface{}{
+		"id":          invitation.ID,
+		"accepted_at": nullTimeFromTime(invitation.AcceptedAt),
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: invitation has already been accepted", internal.ErrBadRequest)
+		return err
+	}
+
+	if _, err = tx.NamedExecContext(ctx, insertMember, memberParams(m)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveOrganization(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewOrganizationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+	organization := Organization{ID: "org", Name: "acme", CreatedBy: "id", CreatedAt: now}
+	owner := Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleOwner, JoinedAt: now}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO organization (id, name, created_by, created_at)
+							VALUES (?, ?, ?, ?)`).
+		WithArgs("org", "acme", "id", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO organization_member (organization_id, user_id, role, joined_at)
+							VALUES (?, ?, ?, ?)`).
+		WithArgs("org", "id", OrganizationRoleOwner, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// When
+	err = r.SaveOrganization(context.Background(), organization, owner)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMember_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewOrganizationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT organization_id, user_id, role, joined_at
+					FROM organization_member
+					WHERE organization_id = ? AND user_id = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("org", "id").
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id", "user_id", "role", "joined_at"}))
+
+	// When
+	_, err = r.GetMember(context.Background(), "org", "id")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestAcceptInvitation_AlreadyAccepted(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewOrganizationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+	invitation := OrganizationInvitation{ID: "invitation", AcceptedAt: &now}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE organization_invitation
+						SET accepted_at = ?
+						WHERE id = ? AND accepted_at IS NULL`).
+		WithArgs(now, "invitation").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// When
+	err = r.AcceptInvitation(context.Background(), invitation, Member{})
+
+	// Then
+	require.EqualError(t, err, "bad request: invitation has already been accepted")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,401 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+const organizationInvitationExpiration = 7 * 24 * time.Hour
+
+type OrganizationRepository interface {
+	SaveOrganization(ctx context.Context, organization Organization, owner Member) error
+	GetUserOrganizations(ctx context.Context, userID string) ([]UserOrganization, error)
+	GetMembers(ctx context.Context, organizationID string) ([]Member, error)
+	GetMember(ctx context.Context, organizationID string, userID string) (Member, error)
+	UpdateMember(ctx context.Context, member Member) error
+	DeleteMember(ctx context.Context, organizationID string, userID string) error
+	SaveInvitation(ctx context.Context, invitation OrganizationInvitation) error
+	GetInvitationByHash(ctx context.Context, hash string) (OrganizationInvitation, error)
+	AcceptInvitation(ctx context.Context, invitation OrganizationInvitation, member Member) error
+}
+
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserOrganization struct {
+	Organization
+	Role string `json:"role"`
+}
+
+type Member struct {
+	OrganizationID string    `json:"organization_id"`
+	UserID         string    `json:"user_id"`
+	Email          string    `json:"email,omitempty"`
+	Firstname      string    `json:"firstname,omitempty"`
+	Lastname       string    `json:"lastname,omitempty"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+type OrganizationInvitation struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organization_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Hash           string     `json:"-"`
+	InvitedBy      string     `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type NewOrganizationInvitation struct {
+	OrganizationInvitation
+	Token string `json:"token"`
+}
+
+func (s *Service) CreateOrganization(ctx context.Context, token string, req CreateOrganizationRequest) (Organization, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return Organization{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Organization{}, fmt.Errorf("creating organization: %v", err)
+	}
+
+	now := time.Now()
+	organization := Organization{
+		ID:        id.String(),
+		Name:      req.Name,
+		CreatedBy: user.ID,
+		CreatedAt: now,
+	}
+
+	owner := Member{
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+		Role:           OrganizationRoleOwner,
+		JoinedAt:       now,
+	}
+
+	if err := s.OrganizationRepository.SaveOrganization(ctx, organization, owner); err != nil {
+		return Organization{}, err
+	}
+
+	return organization, nil
+}
+
+func (s *Service) ListOrganizations(ctx context.Context, token string) ([]UserOrganization, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.OrganizationRepository.GetUserOrganizations(ctx, user.ID)
+}
+
+func (s *Service) ListMembers(ctx context.Context, token string, organizationID string) ([]Member, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.organizationMember(ctx, organizationID, user.ID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.OrganizationRepository.GetMembers(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range members {
+		u, err := s.UserRepository.GetUserByID(ctx, m.UserID)
+		if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+			return nil, err
+		}
+
+		members[i].Email = u.Email
+		members[i].Firstname = u.Firstname
+		members[i].Lastname = u.Lastname
+	}
+
+	return members, nil
+}
+
+func (s *Service) UpdateMember(ctx context.Context, token string, organizationID string, userID string, req UpdateMemberRequest) (Member, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if _, err := s.organizationManager(ctx, organizationID, user.ID, req.Role); err != nil {
+		return Member{}, err
+	}
+
+	member, err := s.OrganizationRepository.GetMember(ctx, organizationID, userID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if _, err := s.organizationManager(ctx, organizationID, user.ID, member.Role); err != nil {
+		return Member{}, err
+	}
+
+	if member.Role == OrganizationRoleOwner && req.Role != OrganizationRoleOwner {
+		if err := s.ensureAnotherOwner(ctx, organizationID, userID); err != nil {
+			return Member{}, err
+		}
+	}
+
+	member.Role = req.Role
+	if err := s.OrganizationRepository.UpdateMember(ctx, member); err != nil {
+		return Member{}, err
+	}
+
+	return member, nil
+}
+
+func (s *Service) RemoveMember(ctx context.Context, token string, organizationID string, userID string) error {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if user.ID != userID {
+		if _, err := s.organizationManager(ctx, organizationID, user.ID, OrganizationRoleMember); err != nil {
+			return err
+		}
+	}
+
+	member, err := s.OrganizationRepository.GetMember(ctx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.ID != userID {
+		if _, err := s.organizationManager(ctx, organizationID, user.ID, member.Role); err != nil {
+			return err
+		}
+	}
+
+	if member.Role == OrganizationRoleOwner {
+		if err := s.ensureAnotherOwner(ctx, organizationID, userID); err != nil {
+			return err
+		}
+	}
+
+	return s.OrganizationRepository.DeleteMember(ctx, organizationID, userID)
+}
+
+func (s *Service) InviteMember(ctx context.Context, token string, organizationID string, req InviteMemberRequest) (NewOrganizationInvitation, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return NewOrganizationInvitation{}, err
+	}
+
+	if _, err := s.organizationManager(ctx, organizationID, user.ID, req.Role); err != nil {
+		return NewOrganizationInvitation{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return NewOrganizationInvitation{}, fmt.Errorf("creating invitation: %v", err)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return NewOrganizationInvitation{}, fmt.Errorf("generating invitation token: %v", err)
+	}
+
+	now := time.Now()
+	invitation := OrganizationInvitation{
+		ID:             id.String(),
+		OrganizationID: organizationID,
+		Email:          strings.ToLower(req.Email),
+		Role:           req.Role,
+		Hash:           hashToken(secret),
+		InvitedBy:      user.ID,
+		ExpiresAt:      now.Add(organizationInvitationExpiration),
+		CreatedAt:      now,
+	}
+
+	if err := s.OrganizationRepository.SaveInvitation(ctx, invitation); err != nil {
+		return NewOrganizationInvitation{}, err
+	}
+
+	return NewOrganizationInvitation{OrganizationInvitation: invitation, Token: secret}, nil
+}
+
+func (s *Service) AcceptInvitation(ctx context.Context, token string, invitationToken string) (Member, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return Member{}, err
+	}
+
+	invitation, err := s.OrganizationRepository.GetInvitationByHash(ctx, hashToken(invitationToken))
+	if err != nil {
+		return Member{}, err
+	}
+
+	now := time.Now()
+	switch {
+	case invitation.AcceptedAt != nil:
+		return Member{}, fmt.Errorf("%w: invitation has already been accepted", internal.ErrBadRequest)
+	case now.After(invitation.ExpiresAt):
+		return Member{}, fmt.Errorf("%w: invitation has expired", internal.ErrBadRequest)
+	case !strings.EqualFold(invitation.Email, user.Email):
+		return Member{}, fmt.Errorf("%w: invitation was sent to another email", internal.ErrForbidden)
+	}
+
+	_, err = s.OrganizationRepository.GetMember(ctx, invitation.OrganizationID, user.ID)
+	if err == nil {
+		return Member{}, fmt.Errorf("%w: user is already a member", internal.ErrResourceAlreadyExists)
+	}
+
+	if !errors.Is(err, internal.ErrResourceNotFound) {
+		return Member{}, err
+	}
+
+	member := Member{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         user.ID,
+		Role:           invitation.Role,
+		JoinedAt:       now,
+	}
+
+	invitation.AcceptedAt = &now
+	if err := s.OrganizationRepository.AcceptInvitation(ctx, invitation, member); err != nil {
+		return Member{}, err
+	}
+
+	return member, nil
+}
+
+func (s *Service) SwitchOrganization(ctx context.Context, token string, organizationID string) (AccessToken, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	if user.scoped {
+		return AccessToken{}, fmt.Errorf("%w: personal access tokens can't switch organization", internal.ErrForbidden)
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return AccessToken{}, err
+	}
+
+	user.OrganizationID = ""
+	user.OrganizationRole = ""
+	if organizationID != "" {
+		member, err := s.organizationMember(ctx, organizationID, user.ID)
+		if err != nil {
+			return AccessToken{}, err
+		}
+
+		user.OrganizationID = member.OrganizationID
+		user.OrganizationRole = member.Role
+	}
+
+	if user.sessionID != "" {
+		if err := s.SessionRepository.ExtendSession(ctx, user.sessionID, time.Now().Add(tokenExpiration)); err != nil {
+			return AccessToken{}, err
+		}
+	}
+
+	t, err := s.issueToken(ctx, user)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("authorizing user: %v", err)
+	}
+
+	return AccessToken{
+		AccessToken: t,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(tokenExpiration.Seconds()),
+	}, nil
+}
+
+func (s *Service) organizationMember(ctx context.Context, organizationID string, userID string) (Member, error) {
+	member, err := s.OrganizationRepository.GetMember(ctx, organizationID, userID)
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return Member{}, fmt.Errorf("%w: user is not a member of the organization", internal.ErrForbidden)
+	}
+
+	return member, err
+}
+
+func (s *Service) organizationManager(ctx context.Context, organizationID string, userID string, grantedRole string) (Member, error) {
+	member, err := s.organizationMember(ctx, organizationID, userID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	switch {
+	case member.Role == OrganizationRoleMember:
+		return Member{}, fmt.Errorf("%w: organization admin role is required", internal.ErrForbidden)
+	case grantedRole == OrganizationRoleOwner && member.Role != OrganizationRoleOwner:
+		return Member{}, fmt.Errorf("%w: organization owner role is required", internal.ErrForbidden)
+	}
+
+	return member, nil
+}
+
+func (s *Service) ensureAnotherOwner(ctx context.Context, organizationID string, userID string) error {
+	members, err := s.OrganizationRepository.GetMembers(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		if m.Role == OrganizationRoleOwner && m.UserID != userID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: organization must keep at least one owner", internal.ErrBadRequest)
+}
+
+// ensureNoSoleOwnership refuses to remove a user who is the only owner of an organization, since
+// deleting them would leave it without anyone able to manage it.
+func (s *Service) ensureNoSoleOwnership(ctx context.Context, userID string) error {
+	organizations, err := s.OrganizationRepository.GetUserOrganizations(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range organizations {
+		if o.Role != OrganizationRoleOwner {
+			continue
+		}
+
+		err := s.ensureAnotherOwner(ctx, o.ID, userID)
+		if errors.Is(err, internal.ErrBadRequest) {
+			return fmt.Errorf("%w: transfer ownership of organization %s first", internal.ErrBadRequest, o.ID)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
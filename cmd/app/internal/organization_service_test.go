@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type organizationRepository struct {
+	mock.Mock
+}
+
+func (r *organizationRepository) SaveOrganization(ctx context.Context, organization Organization, owner Member) error {
+	return r.Called(organization, owner).Error(0)
+}
+
+func (r *organizationRepository) GetUserOrganizations(ctx context.Context, userID string) ([]UserOrganization, error) {
+	args := r.Called(userID)
+	return args.Get(0).([]UserOrganization), args.Error(1)
+}
+
+func (r *organizationRepository) GetMembers(ctx context.Context, organizationID string) ([]Member, error) {
+	args := r.Called(organizationID)
+	return args.Get(0).([]Member), args.Error(1)
+}
+
+func (r *organizationRepository) GetMember(ctx context.Context, organizationID string, userID string) (Member, error) {
+	args := r.Called(organizationID, userID)
+	return args.Get(0).(Member), args.Error(1)
+}
+
+func (r *organizationRepository) UpdateMember(ctx context.Context, member Member) error {
+	return r.Called(member).Error(0)
+}
+
+func (r *organizationRepository) DeleteMember(ctx context.Context, organizationID string, userID string) error {
+	return r.Called(organizationID, userID).Error(0)
+}
+
+func (r *organizationRepository) SaveInvitation(ctx context.Context, invitation OrganizationInvitation) error {
+	return r.Called(invitation).Error(0)
+}
+
+func (r *organizationRepository) GetInvitationByHash(ctx context.Context, hash string) (OrganizationInvitation, error) {
+	args := r.Called(hash)
+	return args.Get(0).(OrganizationInvitation), args.Error(1)
+}
+
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, invitation OrganizationInvitation, member Member) error {
+	return r.Called(invitation, member).Error(0)
+}
+
+func newOrganizationService(u User, or *organizationRepository) (*Service, string) {
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+
+	return s, token
+}
+
+func TestCreateOrganization(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("SaveOrganization", mock.AnythingOfType("Organization"), mock.AnythingOfType("Member")).Return(nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	resp, err := s.CreateOrganization(context.Background(), token, CreateOrganizationRequest{Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	owner := or.Calls[0].Arguments.Get(1).(Member)
+	require.Equal(t, "acme", resp.Name)
+	require.Equal(t, "id", resp.CreatedBy)
+	require.Equal(t, resp.ID, owner.OrganizationID)
+	require.Equal(t, OrganizationRoleOwner, owner.Role)
+}
+
+func TestListMembers_NotMemberError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(Member{}, internal.ErrResourceNotFound)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	_, err := s.ListMembers(context.Background(), token, "org")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrForbidden))
+	or.AssertNotCalled(t, "GetMembers", "org")
+}
+
+func TestUpdateMember_AdminGrantingOwnerError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(Member{UserID: "id", Role: OrganizationRoleAdmin}, nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	_, err := s.UpdateMember(context.Background(), token, "org", "other", UpdateMemberRequest{Role: OrganizationRoleOwner})
+
+	// Then
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: organization owner role is required")
+}
+
+func TestUpdateMember_LastOwnerError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	owner := Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleOwner}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(owner, nil)
+	or.On("GetMembers", "org").Return([]Member{owner, {UserID: "other", Role: OrganizationRoleAdmin}}, nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	_, err := s.UpdateMember(context.Background(), token, "org", "id", UpdateMemberRequest{Role: OrganizationRoleMember})
+
+	// Then
+	require.EqualError(t, err, "bad request: organization must keep at least one owner")
+	or.AssertNotCalled(t, "UpdateMember", mock.Anything)
+}
+
+func TestRemoveMember_Self(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(Member{UserID: "id", Role: OrganizationRoleMember}, nil)
+	or.On("DeleteMember", "org", "id").Return(nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	err := s.RemoveMember(context.Background(), token, "org", "id")
+
+	// Then
+	require.NoError(t, err)
+	or.AssertExpectations(t)
+}
+
+func TestInviteMember(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(Member{UserID: "id", Role: OrganizationRoleAdmin}, nil)
+	or.On("SaveInvitation", mock.AnythingOfType("OrganizationInvitation")).Return(nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	resp, err := s.InviteMember(context.Background(), token, "org", InviteMemberRequest{Email: "John@Example.com", Role: OrganizationRoleMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "john@example.com", resp.Email)
+	require.Equal(t, hashToken(resp.Token), resp.Hash)
+	require.Equal(t, "id", resp.InvitedBy)
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	invitation := OrganizationInvitation{
+		ID:             "invitation",
+		OrganizationID: "org",
+		Email:          u.Email,
+		Role:           OrganizationRoleAdmin,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}
+
+	or := &organizationRepository{}
+	or.On("GetInvitationByHash", hashToken("secret")).Return(invitation, nil)
+	or.On("GetMember", "org", "id").Return(Member{}, internal.ErrResourceNotFound)
+	or.On("AcceptInvitation", mock.AnythingOfType("OrganizationInvitation"), mock.AnythingOfType("Member")).Return(nil)
+
+	s, token := newOrganizationService(u, or)
+
+	// When
+	resp, err := s.AcceptInvitation(context.Background(), token, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleAdmin, JoinedAt: resp.JoinedAt}, resp)
+}
+
+func TestAcceptInvitation_Errors(t *testing.T) {
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	accepted := time.Now()
+
+	tests := []struct {
+		name       string
+		invitation OrganizationInvitation
+		expected   error
+	}{
+		{
+			name:       "already accepted",
+			invitation: OrganizationInvitation{Email: u.Email, ExpiresAt: time.Now().Add(time.Hour), AcceptedAt: &accepted},
+			expected:   internal.ErrBadRequest,
+		},
+		{
+			name:       "expired",
+			invitation: OrganizationInvitation{Email: u.Email, ExpiresAt: time.Now().Add(-time.Hour)},
+			expected:   internal.ErrBadRequest,
+		},
+		{
+			name:       "another email",
+			invitation: OrganizationInvitation{Email: "john@example.com", ExpiresAt: time.Now().Add(time.Hour)},
+			expected:   internal.ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			or := &organizationRepository{}
+			or.On("GetInvitationByHash", hashToken("secret")).Return(tt.invitation, nil)
+
+			s, token := newOrganizationService(u, or)
+
+			// When
+			_, err := s.AcceptInvitation(context.Background(), token, "secret")
+
+			// Then
+			require.True(t, errors.Is(err, tt.expected))
+			or.AssertNotCalled(t, "AcceptInvitation", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+
+	or := &organizationRepository{}
+	or.On("GetMember", "org", "id").Return(Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleAdmin}, nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", "id").Return([]Role{}, nil)
+
+	s, token := newOrganizationService(u, or)
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.SwitchOrganization(context.Background(), token, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	var c claims
+	_, err = jwt.ParseWithClaims(resp.AccessToken, &c, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testConfig.Auth.SigningKey), nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "org", c.OrganizationID)
+	require.Equal(t, OrganizationRoleAdmin, c.OrganizationRole)
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getMeTokens   = "/users/me/tokens"
+	postMeTokens  = "/users/me/tokens"
+	getMeToken    = "/users/me/tokens/{id}"
+	patchMeToken  = "/users/me/tokens/{id}"
+	deleteMeToken = "/users/me/tokens/{id}"
+)
+
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=128"`
+	Scopes    []string   `json:"scopes" validate:"dive,required,max=64"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreatePersonalAccessTokenHandler func(ctx context.Context, token string, req CreatePersonalAccessTokenRequest) (NewPersonalAccessToken, error)
+
+func (h *Handler) RouteCreatePersonalAccessToken(handler CreatePersonalAccessTokenHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req CreatePersonalAccessTokenRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.Wrap(http.MethodPost, postMeTokens, wrapH)
+}
+
+type ListPersonalAccessTokensHandler func(ctx context.Context, token string) ([]PersonalAccessToken, error)
+
+func (h *Handler) RouteListPersonalAccessTokens(handler ListPersonalAccessTokensHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getMeTokens, wrapH)
+}
+
+type GetPersonalAccessTokenHandler func(ctx context.Context, token string, id string) (PersonalAccessToken, error)
+
+func (h *Handler) RouteGetPersonalAccessToken(handler GetPersonalAccessTokenHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getMeToken, wrapH)
+}
+
+type UpdatePersonalAccessTokenRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+type UpdatePersonalAccessTokenHandler func(ctx context.Context, token string, id string, req UpdatePersonalAccessTokenRequest) (PersonalAccessToken, error)
+
+func (h *Handler) RouteUpdatePersonalAccessToken(handler UpdatePersonalAccessTokenHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		var req UpdatePersonalAccessTokenRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token, mux.Vars(r)["id"], req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodPatch, patchMeToken, wrapH)
+}
+
+type DeletePersonalAccessTokenHandler func(ctx context.Context, token string, id string) error
+
+func (h *Handler) RouteDeletePersonalAccessToken(handler DeletePersonalAccessTokenHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		if err := handler(r.Context(), token, mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMeToken, wrapH)
+}
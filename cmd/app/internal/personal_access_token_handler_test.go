@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteCreatePersonalAccessToken(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteCreatePersonalAccessToken(func(_ context.Context, token string, req CreatePersonalAccessTokenRequest) (NewPersonalAccessToken, error) {
+		require.Equal(t, "token", token)
+		require.Equal(t, "ci", req.Name)
+
+		return NewPersonalAccessToken{
+			PersonalAccessToken: PersonalAccessToken{ID: "pat", Name: req.Name, Hash: "hash"},
+			Token:               "auth_pat_secret",
+		}, nil
+	})
+
+	b := []byte(`{"name": "ci", "scopes": ["users:read"]}`)
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/users/me/tokens", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "auth_pat_secret", r["token"])
+	require.Equal(t, "pat", r["id"])
+	require.NotContains(t, r, "hash")
+}
+
+func TestHandler_RouteCreatePersonalAccessToken_UnprocessableEntityError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteCreatePersonalAccessToken(func(_ context.Context, token string, req CreatePersonalAccessTokenRequest) (NewPersonalAccessToken, error) {
+		return NewPersonalAccessToken{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/users/me/tokens", ts.URL), bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandler_RouteListPersonalAccessTokens_InvalidSchemeError(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteListPersonalAccessTokens(func(_ context.Context, token string) ([]PersonalAccessToken, error) {
+		return nil, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/me/tokens", ts.URL), nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, "can't access to the resource. invalid token: authorization header must use the bearer scheme", m)
+}
+
+func TestHandler_RouteDeletePersonalAccessToken(t *testing.T) {
+	// Given
+	w := server.NewServer(config.Server{})
+	h := NewHandler(w)
+
+	h.RouteDeletePersonalAccessToken(func(_ context.Context, token string, id string) error {
+		require.Equal(t, "token", token)
+		require.Equal(t, "pat", id)
+
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/me/tokens/pat", ts.URL), nil)
+	req.AddCookie(&http.Cookie{
+		Name:  "authorization",
+		Value: "token",
+	})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
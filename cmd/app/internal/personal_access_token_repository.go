@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type PersonalAccessTokenSQLRepository struct {
+	db *DB
+}
+
+func NewPersonalAccessTokenRepository(db *DB) PersonalAccessTokenRepository {
+	return &PersonalAccessTokenSQLRepository{
+		db: db,
+	}
+}
+
+type personalAccessToken struct {
+	ID         string       `db:"id"`
+	UserID     string       `db:"user_id"`
+	Name       string       `db:"name"`
+	Prefix     string       `db:"token_prefix"`
+	Hash       string       `db:"token_hash"`
+	Scopes     string       `db:"scopes"`
+	ExpiresAt  sql.NullTime `db:"expires_at"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
+
+func (t personalAccessToken) toPersonalAccessToken() PersonalAccessToken {
+	return PersonalAccessToken{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Hash:       t.Hash,
+		Scopes:     strings.Fields(t.Scopes),
+		ExpiresAt:  timeFromNullTime(t.ExpiresAt),
+		LastUsedAt: timeFromNullTime(t.LastUsedAt),
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func personalAccessTokenParams(t PersonalAccessToken) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           t.ID,
+		"user_id":      t.UserID,
+		"name":         t.Name,
+		"token_prefix": t.Prefix,
+		"token_hash":   t.Hash,
+		"scopes":       strings.Join(t.Scopes, " "),
+		"expires_at":   nullTimeFromTime(t.ExpiresAt),
+		"last_used_at": nullTimeFromTime(t.LastUsedAt),
+		"created_at":   t.CreatedAt,
+	}
+}
+
+const insertPersonalAccessToken = `INSERT INTO personal_access_token (id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at)
+								VALUES (:id, :user_id, :name, :token_prefix, :token_hash, :scopes, :expires_at, :last_used_at, :created_at)`
+
+func (r *PersonalAccessTokenSQLRepository) SavePersonalAccessToken(ctx context.Context, t PersonalAccessToken) (err error) {
+	ctx, end := r.db.start(ctx, "SavePersonalAccessToken")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertPersonalAccessToken, personalAccessTokenParams(t))
+	return err
+}
+
+const getPersonalAccessTokens = `SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+								FROM personal_access_token
+								WHERE user_id = :user_id
+								ORDER BY created_at`
+
+func (r *PersonalAccessTokenSQLRepository) GetPersonalAccessTokens(ctx context.Context, userID string) (_ []PersonalAccessToken, err error) {
+	ctx, end := r.db.start(ctx, "GetPersonalAccessTokens")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getPersonalAccessTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var tokens []personalAccessToken
+	if err := stmt.SelectContext(ctx, &tokens, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+
+	resp := make([]PersonalAccessToken, 0, len(tokens))
+	for _, t := range tokens {
+		resp = append(resp, t.toPersonalAccessToken())
+	}
+
+	return resp, nil
+}
+
+const getPersonalAccessToken = `SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+								FROM personal_access_token
+								WHERE user_id = :user_id AND id = :id`
+
+func (r *PersonalAccessTokenSQLRepository) GetPersonalAccessToken(ctx context.Context, userID string, id string) (_ PersonalAccessToken, err error) {
+	ctx, end := r.db.start(ctx, "GetPersonalAccessToken")
+	defer end(&err)
+
+	return r.getPersonalAccessToken(ctx, getPersonalAccessToken, map[string]interface{}{"user_id": userID, "id": id})
+}
+
+const getPersonalAccessTokenByHash = `SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+								FROM personal_access_token
+								WHERE token_hash = :token_hash`
+
+func (r *PersonalAccessTokenSQLRepository) GetPersonalAccessTokenByHash(ctx context.Context, hash string) (_ PersonalAccessToken, err error) {
+	ctx, end := r.db.start(ctx, "GetPersonalAccessTokenByHash")
+	defer end(&err)
+
+	return r.getPersonalAccessToken(ctx, getPersonalAccessTokenByHash, map[string]interface{}{"token_hash": hash})
+}
+
+func (r *PersonalAccessTokenSQLRepository) getPersonalAccessToken(ctx context.Context, query string, queryParams map[string]interface{}) (PersonalAccessToken, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	defer stmt.Close()
+
+	var t personalAccessToken
+	err = stmt.GetContext(ctx, &t, queryParams)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return PersonalAccessToken{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return PersonalAccessToken{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return t.toPersonalAccessToken(), nil
+}
+
+const updatePersonalAccessToken = `UPDATE personal_access_token
+								SET name = :name
+								WHERE id = :id`
+
+func (r *PersonalAccessTokenSQLRepository) UpdatePersonalAccessToken(ctx context.Context, t PersonalAccessToken) (err error) {
+	ctx, end := r.db.start(ctx, "UpdatePersonalAccessToken")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, updatePersonalAccessToken, personalAccessTokenParams(t))
+	return err
+}
+
+const touchPersonalAccessToken = `UPDATE personal_access_token SET last_used_at = :last_used_at WHERE id = :id`
+
+func (r *PersonalAccessTokenSQLRepository) TouchPersonalAccessToken(ctx context.Context, id string, lastUsedAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "TouchPersonalAccessToken")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, touchPersonalAccessToken, map[string]interface{}{"id": id, "last_used_at": lastUsedAt})
+	return err
+}
+
+const deletePersonalAccessToken = `DELETE FROM personal_access_token WHERE user_id = :user_id AND id = :id`
+
+func (r *PersonalAccessTokenSQLRepository) DeletePersonalAccessToken(ctx context.Context, userID string, id string) (err error) {
+	ctx, end := r.db.start(ctx, "DeletePersonalAccessToken")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, deletePersonalAccessToken, map[string]interface{}{"user_id": userID, "id": id})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+func timeFromNullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func nullTimeFromTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
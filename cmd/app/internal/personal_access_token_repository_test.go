@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavePersonalAccessToken(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPersonalAccessTokenRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	p := PersonalAccessToken{
+		ID:        "pat",
+		UserID:    "id",
+		Name:      "ci",
+		Prefix:    "auth_pat_abcdef",
+		Hash:      "hash",
+		Scopes:    []string{"users:read", "users:write"},
+		CreatedAt: time.Now(),
+	}
+
+	mock.ExpectExec(`INSERT INTO personal_access_token (id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`).
+		WithArgs(p.ID, p.UserID, p.Name, p.Prefix, p.Hash, "users:read users:write", sql.NullTime{}, sql.NullTime{}, p.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.SavePersonalAccessToken(context.Background(), p)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPersonalAccessTokenByHash(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPersonalAccessTokenRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	expiresAt := time.Now()
+	q := `SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+			FROM personal_access_token
+			WHERE token_hash = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("hash").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "token_prefix", "token_hash", "scopes", "expires_at", "last_used_at", "created_at"}).
+				AddRow("pat", "id", "ci", "auth_pat_abcdef", "hash", "users:read", expiresAt, nil, time.Now()),
+		)
+
+	// When
+	resp, err := r.GetPersonalAccessTokenByHash(context.Background(), "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "pat", resp.ID)
+	require.Equal(t, []string{"users:read"}, resp.Scopes)
+	require.Equal(t, expiresAt, *resp.ExpiresAt)
+	require.Nil(t, resp.LastUsedAt)
+}
+
+func TestGetPersonalAccessTokenByHash_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPersonalAccessTokenRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+			FROM personal_access_token
+			WHERE token_hash = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("hash").
+		WillReturnError(sql.ErrNoRows)
+
+	// When
+	_, err = r.GetPersonalAccessTokenByHash(context.Background(), "hash")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestDeletePersonalAccessToken_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPersonalAccessTokenRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectExec(`DELETE FROM personal_access_token WHERE user_id = ? AND id = ?`).
+		WithArgs("id", "pat").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.DeletePersonalAccessToken(context.Background(), "id", "pat")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestTouchPersonalAccessToken(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPersonalAccessTokenRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE personal_access_token SET last_used_at = ? WHERE id = ?`).
+		WithArgs(now, "pat").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.TouchPersonalAccessToken(context.Background(), "pat", now)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
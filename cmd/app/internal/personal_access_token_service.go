@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	personalAccessTokenPrefix        = "auth_pat_"
+	personalAccessTokenDisplayLength = len(personalAccessTokenPrefix) + 6
+)
+
+type PersonalAccessTokenRepository interface {
+	SavePersonalAccessToken(ctx context.Context, token PersonalAccessToken) error
+	GetPersonalAccessTokens(ctx context.Context, userID string) ([]PersonalAccessToken, error)
+	GetPersonalAccessToken(ctx context.Context, userID string, id string) (PersonalAccessToken, error)
+	GetPersonalAccessTokenByHash(ctx context.Context, hash string) (PersonalAccessToken, error)
+	UpdatePersonalAccessToken(ctx context.Context, token PersonalAccessToken) error
+	TouchPersonalAccessToken(ctx context.Context, id string, lastUsedAt time.Time) error
+	DeletePersonalAccessToken(ctx context.Context, userID string, id string) error
+}
+
+type PersonalAccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type NewPersonalAccessToken struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+func (s *Service) CreatePersonalAccessToken(ctx context.Context, token string, req CreatePersonalAccessTokenRequest) (NewPersonalAccessToken, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return NewPersonalAccessToken{}, err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return NewPersonalAccessToken{}, err
+	}
+
+	if user.scoped {
+		return NewPersonalAccessToken{}, fmt.Errorf("%w: personal access tokens can't mint other tokens", internal.ErrForbidden)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return NewPersonalAccessToken{}, fmt.Errorf("%w: expires_at must be in the future", internal.ErrUnprocessableEntity)
+	}
+
+	if err := s.validatePermissions(ctx, req.Scopes); err != nil {
+		return NewPersonalAccessToken{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return NewPersonalAccessToken{}, fmt.Errorf("creating token: %v", err)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return NewPersonalAccessToken{}, fmt.Errorf("generating token: %v", err)
+	}
+
+	secret = personalAccessTokenPrefix + secret
+
+	pat := PersonalAccessToken{
+		ID:        id.String(),
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    secret[:personalAccessTokenDisplayLength],
+		Hash:      hashToken(secret),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.PersonalAccessTokenRepository.SavePersonalAccessToken(ctx, pat); err != nil {
+		return NewPersonalAccessToken{}, err
+	}
+
+	return NewPersonalAccessToken{PersonalAccessToken: pat, Token: secret}, nil
+}
+
+func (s *Service) ListPersonalAccessTokens(ctx context.Context, token string) ([]PersonalAccessToken, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.PersonalAccessTokenRepository.GetPersonalAccessTokens(ctx, user.ID)
+}
+
+func (s *Service) GetPersonalAccessToken(ctx context.Context, token string, id string) (PersonalAccessToken, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	return s.PersonalAccessTokenRepository.GetPersonalAccessToken(ctx, user.ID, id)
+}
+
+func (s *Service) UpdatePersonalAccessToken(ctx context.Context, token string, id string, req UpdatePersonalAccessTokenRequest) (PersonalAccessToken, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	pat, err := s.PersonalAccessTokenRepository.GetPersonalAccessToken(ctx, user.ID, id)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	pat.Name = req.Name
+	if err := s.PersonalAccessTokenRepository.UpdatePersonalAccessToken(ctx, pat); err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	return pat, nil
+}
+
+func (s *Service) DeletePersonalAccessToken(ctx context.Context, token string, id string) error {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.PersonalAccessTokenRepository.DeletePersonalAccessToken(ctx, user.ID, id)
+}
+
+func (s *Service) authorizePersonalAccessToken(ctx context.Context, token string) (User, error) {
+	pat, err := s.PersonalAccessTokenRepository.GetPersonalAccessTokenByHash(ctx, hashToken(token))
+	if errors.Is(err, internal.ErrResourceNotFound) {
+		return User{}, fmt.Errorf("%w: unknown personal access token", internal.ErrInvalidToken)
+	}
+
+	if err != nil {
+		return User{}, err
+	}
+
+	now := time.Now()
+	if pat.ExpiresAt != nil && now.After(*pat.ExpiresAt) {
+		return User{}, fmt.Errorf("%w: personal access token has expired", internal.ErrInvalidToken)
+	}
+
+	if err := s.PersonalAccessTokenRepository.TouchPersonalAccessToken(ctx, pat.ID, now); err != nil {
+		return User{}, err
+	}
+
+	user, err := s.UserRepository.GetUserByID(ctx, pat.UserID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := ensureActive(user); err != nil {
+		return User{}, err
+	}
+
+	user.scopes = pat.Scopes
+	user.scoped = true
+
+	return user, nil
+}
+
+func isPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
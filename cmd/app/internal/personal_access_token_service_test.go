@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type personalAccessTokenRepository struct {
+	mock.Mock
+}
+
+func (r *personalAccessTokenRepository) SavePersonalAccessToken(ctx context.Context, token PersonalAccessToken) error {
+	return r.Called(token).Error(0)
+}
+
+func (r *personalAccessTokenRepository) GetPersonalAccessTokens(ctx context.Context, userID string) ([]PersonalAccessToken, error) {
+	args := r.Called(userID)
+	return args.Get(0).([]PersonalAccessToken), args.Error(1)
+}
+
+func (r *personalAccessTokenRepository) GetPersonalAccessToken(ctx context.Context, userID string, id string) (PersonalAccessToken, error) {
+	args := r.Called(userID, id)
+	return args.Get(0).(PersonalAccessToken), args.Error(1)
+}
+
+func (r *personalAccessTokenRepository) GetPersonalAccessTokenByHash(ctx context.Context, hash string) (PersonalAccessToken, error) {
+	args := r.Called(hash)
+	return args.Get(0).(PersonalAccessToken), args.Error(1)
+}
+
+func (r *personalAccessTokenRepository) UpdatePersonalAccessToken(ctx context.Context, token PersonalAccessToken) error {
+	return r.Called(token).Error(0)
+}
+
+func (r *personalAccessTokenRepository) TouchPersonalAccessToken(ctx context.Context, id string, lastUsedAt time.Time) error {
+	return r.Called(id, lastUsedAt).Error(0)
+}
+
+func (r *personalAccessTokenRepository) DeletePersonalAccessToken(ctx context.Context, userID string, id string) error {
+	return r.Called(userID, id).Error(0)
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("SavePersonalAccessToken", mock.AnythingOfType("PersonalAccessToken")).Return(nil)
+
+	roles := &roleRepository{}
+	roles.On("GetPermissions").Return([]Permission{{Name: PermissionUsersRead}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+	s.RoleRepository = roles
+
+	// When
+	resp, err := s.CreatePersonalAccessToken(context.Background(), token, CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"users:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	saved := p.Calls[0].Arguments.Get(0).(PersonalAccessToken)
+	require.True(t, strings.HasPrefix(resp.Token, "auth_pat_"))
+	require.True(t, strings.HasPrefix(resp.Token, resp.Prefix))
+	require.Equal(t, hashToken(resp.Token), saved.Hash)
+	require.NotEqual(t, resp.Token, saved.Hash)
+	require.Equal(t, "id", saved.UserID)
+	require.Equal(t, []string{"users:read"}, saved.Scopes)
+}
+
+func TestCreatePersonalAccessToken_ExpiredError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	s := NewService(r, nil, testConfig)
+	expiresAt := time.Now().Add(-time.Hour)
+
+	// When
+	_, err := s.CreatePersonalAccessToken(context.Background(), token, CreatePersonalAccessTokenRequest{Name: "ci", ExpiresAt: &expiresAt})
+
+	// Then
+	require.EqualError(t, err, "unprocessable entity: expires_at must be in the future")
+}
+
+func TestCreatePersonalAccessToken_UnknownScopeError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	roles := &roleRepository{}
+	roles.On("GetPermissions").Return([]Permission{{Name: PermissionUsersRead}}, nil)
+
+	p := &personalAccessTokenRepository{}
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+	s.RoleRepository = roles
+
+	// When
+	_, err := s.CreatePersonalAccessToken(context.Background(), token, CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{"users:read", "everything"}})
+
+	// Then
+	require.EqualError(t, err, "unprocessable entity: unknown permission everything")
+	p.AssertNotCalled(t, "SavePersonalAccessToken", mock.Anything)
+}
+
+func TestCreatePersonalAccessToken_PersonalAccessTokenError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token := "auth_pat_secret"
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("GetPersonalAccessTokenByHash", hashToken(token)).Return(PersonalAccessToken{ID: "pat", UserID: u.ID, Scopes: []string{PermissionUsersRead}}, nil)
+	p.On("TouchPersonalAccessToken", "pat", mock.AnythingOfType("time.Time")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+
+	// When
+	_, err := s.CreatePersonalAccessToken(context.Background(), token, CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{PermissionUsersRead}})
+
+	// Then
+	require.EqualError(t, err, "can't access to the resource. insufficient permissions: personal access tokens can't mint other tokens")
+	p.AssertNotCalled(t, "SavePersonalAccessToken", mock.Anything)
+}
+
+func TestAuthorize_PersonalAccessToken(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token := "auth_pat_secret"
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("GetPersonalAccessTokenByHash", hashToken(token)).Return(PersonalAccessToken{ID: "pat", UserID: u.ID}, nil)
+	p.On("TouchPersonalAccessToken", "pat", mock.AnythingOfType("time.Time")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+
+	// When
+	resp, err := s.Authorize(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, u.ID, resp.ID)
+	require.Equal(t, u.Email, resp.Email)
+	p.AssertNotCalled(t, "UpdatePersonalAccessToken", mock.Anything)
+	p.AssertExpectations(t)
+}
+
+func TestAuthorize_PersonalAccessTokenErrors(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Minute)
+
+	tt := []struct {
+		name          string
+		token         PersonalAccessToken
+		err           error
+		expectedError string
+	}{
+		{
+			name:          "unknown token",
+			err:           internal.ErrResourceNotFound,
+			expectedError: "can't access to the resource. invalid token: unknown personal access token",
+		},
+		{
+			name:          "expired token",
+			token:         PersonalAccessToken{UserID: "id", ExpiresAt: &expiresAt},
+			expectedError: "can't access to the resource. invalid token: personal access token has expired",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			p := &personalAccessTokenRepository{}
+			p.On("GetPersonalAccessTokenByHash", mock.Anything).Return(tc.token, tc.err)
+
+			s := NewService(&repository{}, nil, testConfig)
+			s.PersonalAccessTokenRepository = p
+
+			// When
+			_, err := s.Authorize(context.Background(), "auth_pat_secret")
+
+			// Then
+			require.EqualError(t, err, tc.expectedError)
+			require.True(t, errors.Is(err, internal.ErrInvalidToken))
+		})
+	}
+}
+
+func TestDeletePersonalAccessToken(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("DeletePersonalAccessToken", u.ID, "pat").Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+
+	// When
+	err := s.DeletePersonalAccessToken(context.Background(), token, "pat")
+
+	// Then
+	require.NoError(t, err)
+	p.AssertExpectations(t)
+}
+
+func TestUpdatePersonalAccessToken(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("GetPersonalAccessToken", u.ID, "pat").Return(PersonalAccessToken{ID: "pat", Name: "old"}, nil)
+	p.On("UpdatePersonalAccessToken", PersonalAccessToken{ID: "pat", Name: "new"}).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+
+	// When
+	resp, err := s.UpdatePersonalAccessToken(context.Background(), token, "pat", UpdatePersonalAccessTokenRequest{Name: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "new", resp.Name)
+	p.AssertExpectations(t)
+}
@@ -0,0 +1,447 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	pqUniqueViolation = "23505"
+	postgresSearch    = `%s ILIKE :search`
+)
+
+// PostgresUserRepository stores users in the users and login tables created by
+// cmd/app/migrations/postgres.
+type PostgresUserRepository struct {
+	db *DB
+}
+
+func NewPostgresUserRepository(db *DB) Repository {
+	return &PostgresUserRepository{
+		db: db,
+	}
+}
+
+const postgresFindUserByEmail = `SELECT COUNT(1) FROM login WHERE email = :email`
+
+func (r *PostgresUserRepository) FindUserByEmail(ctx context.Context, email string) (err error) {
+	ctx, end := r.db.start(ctx, "FindUserByEmail")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, postgresFindUserByEmail)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	var count int
+	err = stmt.GetContext(ctx, &count, map[string]interface{}{"email": email})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+const postgresSelectUsers = `SELECT users._id, users.firstname, users.lastname, users.status, users.password_reset_required, users.created_at, users.updated_at, users.delete_after, login.email, login.password
+								FROM login
+								INNER JOIN users
+								ON users.id = login.user_id`
+
+const postgresGetUserByEmail = postgresSelectUsers + ` WHERE login.email = :email`
+
+func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (_ User, err error) {
+	ctx, end := r.db.start(ctx, "GetUserByEmail")
+	defer end(&err)
+
+	return r.getUser(ctx, postgresGetUserByEmail, map[string]interface{}{"email": email})
+}
+
+const postgresGetUserByID = postgresSelectUsers + ` WHERE users._id = :id`
+
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id string) (_ User, err error) {
+	ctx, end := r.db.start(ctx, "GetUserByID")
+	defer end(&err)
+
+	return r.getUser(ctx, postgresGetUserByID, map[string]interface{}{"id": id})
+}
+
+func (r *PostgresUserRepository) getUser(ctx context.Context, query string, queryParams map[string]interface{}) (User, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return User{}, err
+	}
+
+	defer stmt.Close()
+
+	var u user
+	err = stmt.GetContext(ctx, &u, queryParams)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return User{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return u.toUser(), nil
+}
+
+const (
+	postgresInsertUserIntoUsersTable = `INSERT INTO users (_id, firstname, lastname) VALUES (:_id, :firstname, :lastname) RETURNING id`
+	postgresInsertUserIntoLoginTable = `INSERT INTO login (email, password, user_id) VALUES (:email, :password, :user_id)`
+)
+
+func (r *PostgresUserRepository) SaveUser(ctx context.Context, newUser NewUser) (err error) {
+	ctx, end := r.db.start(ctx, "SaveUser")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	stmt, err := tx.PrepareNamedContext(ctx, postgresInsertUserIntoUsersTable)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	var userID int64
+	err = stmt.GetContext(ctx, &userID, map[string]interface{}{
+		"_id":       newUser.ID,
+		"firstname": newUser.Firstname,
+		"lastname":  newUser.Lastname,
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExecContext(ctx, postgresInsertUserIntoLoginTable, map[string]interface{}{
+		"email":    newUser.Email,
+		"password": newUser.Password,
+		"user_id":  userID,
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+const postgresUpdateUser = `UPDATE users
+					SET firstname = :firstname, lastname = :lastname, updated_at = :updated_at
+					WHERE _id = :id AND updated_at = :previous_updated_at`
+
+func (r *PostgresUserRepository) UpdateUser(ctx context.Context, u User, previousUpdatedAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateUser")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, postgresUpdateUser, map[string]interface{}{
+		"id":                  u.ID,
+		"firstname":           u.Firstname,
+		"lastname":            u.Lastname,
+		"updated_at":          u.UpdatedAt,
+		"previous_updated_at": previousUpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: user has been modified", internal.ErrPreconditionFailed)
+	}
+
+	return nil
+}
+
+const postgresGetUserPassword = `SELECT login.password
+						FROM login
+						INNER JOIN users
+						ON users.id = login.user_id
+						WHERE users._id = :id`
+
+func (r *PostgresUserRepository) GetUserPassword(ctx context.Context, id string) (_ string, err error) {
+	ctx, end := r.db.start(ctx, "GetUserPassword")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, postgresGetUserPassword)
+	if err != nil {
+		return "", err
+	}
+
+	defer stmt.Close()
+
+	var password string
+	err = stmt.GetContext(ctx, &password, map[string]interface{}{"id": id})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return password, nil
+}
+
+const postgresScheduleUserDeletion = `UPDATE users SET delete_after = :delete_after, updated_at = :updated_at WHERE _id = :id`
+
+func (r *PostgresUserRepository) ScheduleUserDeletion(ctx context.Context, id string, deleteAfter *time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "ScheduleUserDeletion")
+	defer end(&err)
+
+	return r.updateUser(ctx, postgresScheduleUserDeletion, map[string]interface{}{
+		"id":           id,
+		"delete_after": nullTimeFromTime(deleteAfter),
+		"updated_at":   time.Now(),
+	})
+}
+
+const postgresGetUsersScheduledForDeletion = `SELECT _id FROM users WHERE delete_after IS NOT NULL AND delete_after <= :now`
+
+func (r *PostgresUserRepository) GetUsersScheduledForDeletion(ctx context.Context, now time.Time) (_ []string, err error) {
+	ctx, end := r.db.start(ctx, "GetUsersScheduledForDeletion")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, postgresGetUsersScheduledForDeletion)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var ids []string
+	if err := stmt.SelectContext(ctx, &ids, map[string]interface{}{"now": now}); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+const (
+	postgresCountUsers = `SELECT COUNT(1)
+					FROM login
+					INNER JOIN users
+					ON users.id = login.user_id`
+	postgresOrderAndPaginateUsers = ` ORDER BY users.created_at DESC, users.id DESC LIMIT :limit OFFSET :offset`
+)
+
+func (r *PostgresUserRepository) GetUsers(ctx context.Context, query UserQuery) (_ []User, _ int, err error) {
+	ctx, end := r.db.start(ctx, "GetUsers")
+	defer end(&err)
+
+	where, queryParams := userQueryConditions(query, "users", postgresSearch)
+
+	countStmt, err := r.db.PrepareNamedContext(ctx, postgresCountUsers+where)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer countStmt.Close()
+
+	var total int
+	if err := countStmt.GetContext(ctx, &total, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	stmt, err := r.db.PrepareNamedContext(ctx, postgresSelectUsers+where+postgresOrderAndPaginateUsers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer stmt.Close()
+
+	queryParams["limit"] = query.Limit
+	queryParams["offset"] = query.Offset
+
+	var users []user
+	if err := stmt.SelectContext(ctx, &users, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	resp := make([]User, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, u.toUser())
+	}
+
+	return resp, total, nil
+}
+
+const postgresUpdateUserStatus = `UPDATE users SET status = :status, updated_at = :updated_at WHERE _id = :id`
+
+func (r *PostgresUserRepository) UpdateUserStatus(ctx context.Context, id string, status string) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateUserStatus")
+	defer end(&err)
+
+	return r.updateUser(ctx, postgresUpdateUserStatus, map[string]interface{}{
+		"id":         id,
+		"status":     status,
+		"updated_at": time.Now(),
+	})
+}
+
+const postgresRequirePasswordReset = `UPDATE users SET password_reset_required = TRUE, updated_at = :updated_at WHERE _id = :id`
+
+func (r *PostgresUserRepository) RequirePasswordReset(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "RequirePasswordReset")
+	defer end(&err)
+
+	return r.updateUser(ctx, postgresRequirePasswordReset, map[string]interface{}{
+		"id":         id,
+		"updated_at": time.Now(),
+	})
+}
+
+const (
+	postgresResetPassword      = `UPDATE users SET password_reset_required = FALSE, updated_at = :updated_at WHERE _id = :id`
+	postgresResetLoginPassword = `UPDATE login SET password = :password WHERE user_id = (SELECT id FROM users WHERE _id = :id)`
+)
+
+// ResetPassword replaces the user's password and clears a forced reset.
+func (r *PostgresUserRepository) ResetPassword(ctx context.Context, id string, password string) (err error) {
+	ctx, end := r.db.start(ctx, "ResetPassword")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{
+		"id":         id,
+		"password":   password,
+		"updated_at": time.Now(),
+	}
+
+	result, err := tx.NamedExecContext(ctx, postgresResetPassword, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+		return err
+	}
+
+	if _, err = tx.NamedExecContext(ctx, postgresResetLoginPassword, queryParams); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresUserRepository) updateUser(ctx context.Context, query string, queryParams map[string]interface{}) error {
+	result, err := r.db.NamedExecContext(ctx, query, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+var postgresDeleteUserData = []string{
+	`DELETE FROM personal_access_token WHERE user_id = :id`,
+	`DELETE FROM user_session WHERE user_id = :id`,
+	`DELETE FROM user_role WHERE user_id = :id`,
+	`DELETE FROM organization_member WHERE user_id = :id`,
+	`DELETE FROM login WHERE user_id = (SELECT id FROM users WHERE _id = :id)`,
+}
+
+const postgresDeleteUser = `DELETE FROM users WHERE _id = :id`
+
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteUser")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{"id": id}
+	for _, query := range postgresDeleteUserData {
+		if _, err = tx.NamedExecContext(ctx, query, queryParams); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.NamedExecContext(ctx, postgresDeleteUser, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresSaveUser(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPostgresUserRepository(&DB{DB: sqlx.NewDb(db, "postgres")})
+	user := NewUser{
+		ID:        "id",
+		Firstname: "mateo",
+		Lastname:  "ferrari coronel",
+		Email:     "mateo.ferrari97@gmail.com",
+		Password:  "123",
+	}
+
+	firstQuery := `INSERT INTO users (_id, firstname, lastname) VALUES ($1, $2, $3) RETURNING id`
+	secondQuery := `INSERT INTO login (email, password, user_id) VALUES ($1, $2, $3)`
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(firstQuery)
+	mock.ExpectQuery(firstQuery).
+		WithArgs(user.ID, user.Firstname, user.Lastname).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(secondQuery).
+		WithArgs(user.Email, user.Password, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// When
+	err = r.SaveUser(context.Background(), user)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresSaveUser_EmailAlreadyExists(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPostgresUserRepository(&DB{DB: sqlx.NewDb(db, "postgres")})
+	user := NewUser{ID: "id", Firstname: "mateo", Lastname: "ferrari coronel", Email: "mateo.ferrari97@gmail.com", Password: "123"}
+
+	firstQuery := `INSERT INTO users (_id, firstname, lastname) VALUES ($1, $2, $3) RETURNING id`
+	secondQuery := `INSERT INTO login (email, password, user_id) VALUES ($1, $2, $3)`
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(firstQuery)
+	mock.ExpectQuery(firstQuery).
+		WithArgs(user.ID, user.Firstname, user.Lastname).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(secondQuery).
+		WithArgs(user.Email, user.Password, 7).
+		WillReturnError(&pq.Error{Code: pqUniqueViolation, Constraint: "login_email_key"})
+	mock.ExpectRollback()
+
+	// When
+	err = r.SaveUser(context.Background(), user)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresGetUserByID_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPostgresUserRepository(&DB{DB: sqlx.NewDb(db, "postgres")})
+	q := `SELECT users._id, users.firstname, users.lastname, users.status, users.password_reset_required, users.created_at, users.updated_at, users.delete_after, login.email, login.password
+			FROM login
+			INNER JOIN users
+			ON users.id = login.user_id WHERE users._id = $1`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("id").
+		WillReturnRows(sqlmock.NewRows([]string{"_id"}))
+
+	// When
+	_, err = r.GetUserByID(context.Background(), "id")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+}
+
+func TestPostgresGetUsers(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewPostgresUserRepository(&DB{DB: sqlx.NewDb(db, "postgres")})
+	where := ` WHERE (login.email ILIKE $1 OR users.firstname ILIKE $2 OR users.lastname ILIKE $3) AND users.status = $4`
+	count := `SELECT COUNT(1)
+			FROM login
+			INNER JOIN users
+			ON users.id = login.user_id` + where
+	q := `SELECT users._id, users.firstname, users.lastname, users.status, users.password_reset_required, users.created_at, users.updated_at, users.delete_after, login.email, login.password
+			FROM login
+			INNER JOIN users
+			ON users.id = login.user_id` + where + ` ORDER BY users.created_at DESC, users.id DESC LIMIT $5 OFFSET $6`
+
+	mock.ExpectPrepare(count)
+	mock.ExpectQuery(count).
+		WithArgs(`%mateo\_f%`, `%mateo\_f%`, `%mateo\_f%`, "active").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(11))
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs(`%mateo\_f%`, `%mateo\_f%`, `%mateo\_f%`, "active", 10, 10).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"_id", "firstname", "lastname", "status", "email"}).
+				AddRow("id", "mateo", "ferrari coronel", "active", "mateo.ferrari97@gmail.com"),
+		)
+
+	// When
+	resp, total, err := r.GetUsers(context.Background(), UserQuery{Search: "mateo_f", Status: "active", Limit: 10, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 11, total)
+	require.Equal(t, []User{{ID: "id", Firstname: "mateo", Lastname: "ferrari coronel", Email: "mateo.ferrari97@gmail.com", Status: "active"}}, resp)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
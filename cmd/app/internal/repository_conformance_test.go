@@ -0,0 +1,86 @@
+package internal_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	app "github.com/mateoferrari97/auth/cmd/app/internal"
+	"github.com/mateoferrari97/auth/cmd/app/internal/repositorytest"
+	"github.com/mateoferrari97/auth/cmd/app/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func TestMemoryUserRepository_Conformance(t *testing.T) {
+	repositorytest.TestRepository(t, func(t *testing.T) app.Repository {
+		return app.NewMemoryUserRepository(nil)
+	})
+}
+
+func TestSQLiteUserRepository_Conformance(t *testing.T) {
+	repositorytest.TestRepository(t, func(t *testing.T) app.Repository {
+		db, err := sqlx.Connect("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+		if err != nil {
+			t.Fatalf("opening sqlite: %v", err)
+		}
+
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := migrations.New(db, "sqlite")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := migrator.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		return app.NewSQLiteUserRepository(&app.DB{DB: db})
+	})
+}
+
+// The MySQL and Postgres suites need a database migrated with "app migrate", e.g.
+// AUTH_TEST_MYSQL_DSN="auth:auth@tcp(localhost:3306)/auth_test?parseTime=true".
+func TestUserRepository_Conformance(t *testing.T) {
+	db := connect(t, "mysql", "AUTH_TEST_MYSQL_DSN")
+	repositorytest.TestRepository(t, func(t *testing.T) app.Repository {
+		truncate(t, db, "user")
+		return app.NewUserRepository(&app.DB{DB: db})
+	})
+}
+
+func TestPostgresUserRepository_Conformance(t *testing.T) {
+	db := connect(t, "postgres", "AUTH_TEST_POSTGRES_DSN")
+	repositorytest.TestRepository(t, func(t *testing.T) app.Repository {
+		truncate(t, db, "users")
+		return app.NewPostgresUserRepository(&app.DB{DB: db})
+	})
+}
+
+func connect(t *testing.T, driver string, env string) *sqlx.DB {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s is not set", env)
+	}
+
+	db, err := sqlx.Connect(driver, dsn)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", driver, err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func truncate(t *testing.T, db *sqlx.DB, userTable string) {
+	for _, table := range []string{"login", userTable} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("cleaning %s: %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package repositorytest checks that an implementation of the user Repository behaves like
+// every other one, regardless of the storage behind it.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	app "github.com/mateoferrari97/auth/cmd/app/internal"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// NewRepository returns an empty repository. It's called once per subtest.
+type NewRepository func(t *testing.T) app.Repository
+
+// TestRepository runs the conformance suite against the repositories built by newRepository.
+func TestRepository(t *testing.T, newRepository NewRepository) {
+	tt := []struct {
+		name string
+		test func(t *testing.T, r app.Repository)
+	}{
+		{name: "SaveAndGet", test: testSaveAndGet},
+		{name: "DuplicateEmail", test: testDuplicateEmail},
+		{name: "NotFound", test: testNotFound},
+		{name: "ConcurrentRegistrations", test: testConcurrentRegistrations},
+		{name: "UpdateUser", test: testUpdateUser},
+		{name: "UpdateUserStatus", test: testUpdateUserStatus},
+		{name: "ResetPassword", test: testResetPassword},
+		{name: "ScheduleUserDeletion", test: testScheduleUserDeletion},
+		{name: "DeleteUser", test: testDeleteUser},
+		{name: "GetUsers", test: testGetUsers},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepository(t))
+		})
+	}
+}
+
+func newUser(id string, email string) app.NewUser {
+	return app.NewUser{
+		ID:        id,
+		Firstname: "mateo",
+		Lastname:  "ferrari coronel",
+		Email:     email,
+		Password:  "hash-" + id,
+	}
+}
+
+func save(t *testing.T, r app.Repository, users ...app.NewUser) {
+	t.Helper()
+
+	for _, u := range users {
+		if err := r.SaveUser(context.Background(), u); err != nil {
+			t.Fatalf("saving user %s: %v", u.ID, err)
+		}
+	}
+}
+
+func testSaveAndGet(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+
+	// When
+	byEmail, err := r.GetUserByEmail(ctx, "mateo.ferrari97@gmail.com")
+	require.NoError(t, err)
+
+	byID, err := r.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+
+	password, err := r.GetUserPassword(ctx, "1")
+	require.NoError(t, err)
+
+	// Then
+	require.NoError(t, r.FindUserByEmail(ctx, "mateo.ferrari97@gmail.com"))
+	require.Equal(t, "1", byEmail.ID)
+	require.Equal(t, "mateo", byEmail.Firstname)
+	require.Equal(t, "ferrari coronel", byEmail.Lastname)
+	require.Equal(t, "mateo.ferrari97@gmail.com", byEmail.Email)
+	require.Equal(t, app.UserStatusActive, byEmail.Status)
+	require.False(t, byEmail.PasswordResetRequired)
+	require.NotNil(t, byEmail.CreatedAt)
+	require.NotNil(t, byEmail.UpdatedAt)
+	require.Nil(t, byEmail.DeleteAfter)
+	require.Equal(t, byEmail, byID)
+	require.Equal(t, "hash-1", password)
+}
+
+func testDuplicateEmail(t *testing.T, r app.Repository) {
+	// Given
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+
+	// When
+	err := r.SaveUser(context.Background(), newUser("2", "mateo.ferrari97@gmail.com"))
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists), "got %v", err)
+
+	user, err := r.GetUserByEmail(context.Background(), "mateo.ferrari97@gmail.com")
+	require.NoError(t, err)
+	require.Equal(t, "1", user.ID)
+
+	_, err = r.GetUserByID(context.Background(), "2")
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound), "got %v", err)
+}
+
+func testNotFound(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	deleteAfter := time.Now()
+
+	// When
+	_, getByEmailErr := r.GetUserByEmail(ctx, "missing@gmail.com")
+	_, getByIDErr := r.GetUserByID(ctx, "missing")
+	_, getPasswordErr := r.GetUserPassword(ctx, "missing")
+	errs := map[string]error{
+		"GetUserByEmail":       getByEmailErr,
+		"GetUserByID":          getByIDErr,
+		"GetUserPassword":      getPasswordErr,
+		"FindUserByEmail":      r.FindUserByEmail(ctx, "missing@gmail.com"),
+		"UpdateUserStatus":     r.UpdateUserStatus(ctx, "missing", app.UserStatusDisabled),
+		"RequirePasswordReset": r.RequirePasswordReset(ctx, "missing"),
+		"ResetPassword":        r.ResetPassword(ctx, "missing", "hash"),
+		"ScheduleUserDeletion": r.ScheduleUserDeletion(ctx, "missing", &deleteAfter),
+		"DeleteUser":           r.DeleteUser(ctx, "missing"),
+	}
+
+	// Then
+	for method, err := range errs {
+		require.True(t, errors.Is(err, internal.ErrResourceNotFound), "%s: got %v", method, err)
+	}
+}
+
+func testConcurrentRegistrations(t *testing.T, r app.Repository) {
+	// Given
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+
+	// When
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = r.SaveUser(context.Background(), newUser(fmt.Sprint(i), "mateo.ferrari97@gmail.com"))
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Then
+	var saved int
+	for _, err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+
+		require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists), "got %v", err)
+	}
+
+	require.Equal(t, 1, saved)
+
+	users, total, err := r.GetUsers(context.Background(), app.UserQuery{Limit: n})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Len(t, users, 1)
+}
+
+func testUpdateUser(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+
+	user, err := r.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+
+	previousUpdatedAt := *user.UpdatedAt
+	updatedAt := previousUpdatedAt.Add(time.Second).Truncate(time.Millisecond)
+	user.Firstname = "juan"
+	user.Lastname = "perez"
+	user.UpdatedAt = &updatedAt
+
+	// When
+	err = r.UpdateUser(ctx, user, previousUpdatedAt)
+	require.NoError(t, err)
+
+	staleErr := r.UpdateUser(ctx, user, previousUpdatedAt)
+
+	// Then
+	require.True(t, errors.Is(staleErr, internal.ErrPreconditionFailed), "got %v", staleErr)
+
+	resp, err := r.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "juan", resp.Firstname)
+	require.Equal(t, "perez", resp.Lastname)
+	require.True(t, updatedAt.Equal(*resp.UpdatedAt), "expected %v, got %v", updatedAt, *resp.UpdatedAt)
+
+	missingErr := r.UpdateUser(ctx, app.User{ID: "missing", UpdatedAt: &updatedAt}, previousUpdatedAt)
+	require.True(t, errors.Is(missingErr, internal.ErrPreconditionFailed), "got %v", missingErr)
+}
+
+func testUpdateUserStatus(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+
+	// When
+	require.NoError(t, r.UpdateUserStatus(ctx, "1", app.UserStatusDisabled))
+	require.NoError(t, r.RequirePasswordReset(ctx, "1"))
+
+	// Then
+	user, err := r.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, app.UserStatusDisabled, user.Status)
+	require.True(t, user.PasswordResetRequired)
+}
+
+func testResetPassword(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+	require.NoError(t, r.RequirePasswordReset(ctx, "1"))
+
+	// When
+	require.NoError(t, r.ResetPassword(ctx, "1", "new-hash"))
+
+	// Then
+	user, err := r.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	require.False(t, user.PasswordResetRequired)
+
+	password, err := r.GetUserPassword(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "new-hash", password)
+}
+
+func testScheduleUserDeletion(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"), newUser("2", "juan@gmail.com"))
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	// When
+	require.NoError(t, r.ScheduleUserDeletion(ctx, "1", &past))
+	require.NoError(t, r.ScheduleUserDeletion(ctx, "2", &future))
+
+	due, err := r.GetUsersScheduledForDeletion(ctx, now)
+	require.NoError(t, err)
+
+	require.NoError(t, r.ScheduleUserDeletion(ctx, "1", nil))
+
+	dueAfterCancel, err := r.GetUsersScheduledForDeletion(ctx, now)
+	require.NoError(t, err)
+
+	// Then
+	require.Equal(t, []string{"1"}, due)
+	require.Empty(t, dueAfterCancel)
+
+	user, err := r.GetUserByID(ctx, "2")
+	require.NoError(t, err)
+	require.NotNil(t, user.DeleteAfter)
+	require.WithinDuration(t, future, *user.DeleteAfter, time.Millisecond)
+}
+
+func testDeleteUser(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	save(t, r, newUser("1", "mateo.ferrari97@gmail.com"))
+
+	// When
+	err := r.DeleteUser(ctx, "1")
+
+	// Then
+	require.NoError(t, err)
+
+	_, err = r.GetUserByID(ctx, "1")
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound), "got %v", err)
+
+	err = r.FindUserByEmail(ctx, "mateo.ferrari97@gmail.com")
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound), "got %v", err)
+
+	require.NoError(t, r.SaveUser(ctx, newUser("2", "mateo.ferrari97@gmail.com")))
+}
+
+func testGetUsers(t *testing.T, r app.Repository) {
+	// Given
+	ctx := context.Background()
+	users := []app.NewUser{
+		{ID: "1", Firstname: "mateo_f", Lastname: "ferrari", Email: "mateo@gmail.com"},
+		{ID: "2", Firstname: "mateoxf", Lastname: "perez", Email: "juan@gmail.com"},
+		{ID: "3", Firstname: "Mateo_F", Lastname: "gomez", Email: "mgomez@gmail.com"},
+		{ID: "4", Firstname: "lucia", Lastname: "lopez", Email: "lucia@gmail.com"},
+	}
+
+	for _, u := range users {
+		save(t, r, u)
+	}
+
+	require.NoError(t, r.UpdateUserStatus(ctx, "4", app.UserStatusDisabled))
+
+	// When
+	all, total, err := r.GetUsers(ctx, app.UserQuery{Limit: 10})
+	require.NoError(t, err)
+
+	searched, searchedTotal, err := r.GetUsers(ctx, app.UserQuery{Search: "MATEO_", Limit: 10})
+	require.NoError(t, err)
+
+	page, pageTotal, err := r.GetUsers(ctx, app.UserQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+
+	disabled, disabledTotal, err := r.GetUsers(ctx, app.UserQuery{Status: app.UserStatusDisabled, Limit: 10})
+	require.NoError(t, err)
+
+	// Then
+	require.Equal(t, 4, total)
+	require.Equal(t, []string{"4", "3", "2", "1"}, ids(all))
+	require.Equal(t, 2, searchedTotal)
+	require.Equal(t, []string{"3", "1"}, ids(searched))
+	require.Equal(t, 4, pageTotal)
+	require.Equal(t, []string{"3", "2"}, ids(page))
+	require.Equal(t, 1, disabledTotal)
+	require.Equal(t, []string{"4"}, ids(disabled))
+}
+
+func ids(users []app.User) []string {
+	resp := make([]string, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, u.ID)
+	}
+
+	return resp
+}
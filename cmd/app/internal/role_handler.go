@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getAdminRoles           = "/admin/roles"
+	postAdminRoles          = "/admin/roles"
+	getAdminRole            = "/admin/roles/{name}"
+	putAdminRolePermissions = "/admin/roles/{name}/permissions"
+	deleteAdminRole         = "/admin/roles/{name}"
+	getAdminPermissions     = "/admin/permissions"
+	postAdminPermissions    = "/admin/permissions"
+	getAdminUserRoles       = "/admin/users/{id}/roles"
+	putAdminUserRole        = "/admin/users/{id}/roles/{role}"
+	deleteAdminUserRole     = "/admin/users/{id}/roles/{role}"
+)
+
+type ListRolesHandler func(ctx context.Context) ([]Role, error)
+
+func (h *Handler) RouteListRoles(handler ListRolesHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminRoles, []string{PermissionRolesRead}, wrapH)
+}
+
+type GetRoleHandler func(ctx context.Context, name string) (Role, error)
+
+func (h *Handler) RouteGetRole(handler GetRoleHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context(), mux.Vars(r)["name"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminRole, []string{PermissionRolesRead}, wrapH)
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,max=64"`
+	Description string   `json:"description" validate:"max=256"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type CreateRoleHandler func(ctx context.Context, req CreateRoleRequest) (Role, error)
+
+func (h *Handler) RouteCreateRole(handler CreateRoleHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req CreateRoleRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminRoles, []string{PermissionRolesWrite}, wrapH)
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type UpdateRolePermissionsHandler func(ctx context.Context, name string, req UpdateRolePermissionsRequest) (Role, error)
+
+func (h *Handler) RouteUpdateRolePermissions(handler UpdateRolePermissionsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req UpdateRolePermissionsRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), mux.Vars(r)["name"], req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodPut, putAdminRolePermissions, []string{PermissionRolesWrite}, wrapH)
+}
+
+type DeleteRoleHandler func(ctx context.Context, name string) error
+
+func (h *Handler) RouteDeleteRole(handler DeleteRoleHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		if err := handler(r.Context(), mux.Vars(r)["name"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(http.MethodDelete, deleteAdminRole, []string{PermissionRolesWrite}, wrapH)
+}
+
+type ListPermissionsHandler func(ctx context.Context) ([]Permission, error)
+
+func (h *Handler) RouteListPermissions(handler ListPermissionsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminPermissions, []string{PermissionRolesRead}, wrapH)
+}
+
+type CreatePermissionRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=256"`
+}
+
+type CreatePermissionHandler func(ctx context.Context, req CreatePermissionRequest) (Permission, error)
+
+func (h *Handler) RouteCreatePermission(handler CreatePermissionHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req CreatePermissionRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminPermissions, []string{PermissionRolesWrite}, wrapH)
+}
+
+type ListUserRolesHandler func(ctx context.Context, userID string) ([]Role, error)
+
+func (h *Handler) RouteListUserRoles(handler ListUserRolesHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminUserRoles, []string{PermissionRolesRead}, wrapH)
+}
+
+type UserRoleHandler func(ctx context.Context, userID string, role string) error
+
+func (h *Handler) RouteAssignRole(handler UserRoleHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		vars := mux.Vars(r)
+		if err := handler(r.Context(), vars["id"], vars["role"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(http.MethodPut, putAdminUserRole, []string{PermissionRolesWrite}, wrapH)
+}
+
+func (h *Handler) RouteUnassignRole(handler UserRoleHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		vars := mux.Vars(r)
+		if err := handler(r.Context(), vars["id"], vars["role"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(http.MethodDelete, deleteAdminUserRole, []string{PermissionRolesWrite}, wrapH)
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthorizedServer(permissions ...string) *server.Server {
+	w := server.NewServer(config.Server{})
+	w.Authorizer = NewAuthorizer(func(_ context.Context, _ Origin, token string) (User, error) {
+		return User{ID: "admin", Permissions: permissions}, nil
+	}, nil)
+
+	return w
+}
+
+func TestNewAuthorizer(t *testing.T) {
+	// Given
+	authorizer := NewAuthorizer(func(_ context.Context, _ Origin, token string) (User, error) {
+		require.Equal(t, "token", token)
+		return User{ID: "id"}, nil
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/roles", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	// When
+	resp, err := authorizer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, User{ID: "id"}, resp)
+}
+
+func TestHandler_RouteListRoles(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionRolesRead)
+	h := NewHandler(w)
+
+	h.RouteListRoles(func(_ context.Context) ([]Role, error) {
+		return []Role{{Name: "admin", Permissions: []string{PermissionRolesRead}}}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/roles", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r []Role
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "admin", r[0].Name)
+}
+
+func TestHandler_RouteCreateRole_ForbiddenError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionRolesRead)
+	h := NewHandler(w)
+
+	h.RouteCreateRole(func(_ context.Context, req CreateRoleRequest) (Role, error) {
+		return Role{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/roles", ts.URL), bytes.NewReader([]byte(`{"name": "auditor"}`)))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	m := decodeErrorMessageFromBody(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, "can't access to the resource. insufficient permissions: roles:write is required", m)
+}
+
+func TestHandler_RouteCreateRole(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionRolesWrite)
+	h := NewHandler(w)
+
+	h.RouteCreateRole(func(_ context.Context, req CreateRoleRequest) (Role, error) {
+		require.Equal(t, "auditor", req.Name)
+		require.Equal(t, []string{PermissionRolesRead}, req.Permissions)
+
+		return Role{Name: req.Name, Permissions: req.Permissions}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	b := []byte(`{"name": "auditor", "permissions": ["roles:read"]}`)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/roles", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestHandler_RouteAssignRole(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionRolesWrite)
+	h := NewHandler(w)
+
+	h.RouteAssignRole(func(_ context.Context, userID string, role string) error {
+		require.Equal(t, "id", userID)
+		require.Equal(t, "admin", role)
+
+		return nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/admin/users/id/roles/admin", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
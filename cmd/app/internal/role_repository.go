@@ -0,0 +1,313 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type RoleSQLRepository struct {
+	db *DB
+}
+
+func NewRoleRepository(db *DB) RoleRepository {
+	return &RoleSQLRepository{
+		db: db,
+	}
+}
+
+type rolePermission struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Permission  sql.NullString `db:"permission_name"`
+}
+
+func groupRoles(rows []rolePermission) []Role {
+	roles := make([]Role, 0)
+	index := make(map[string]int)
+	for _, row := range rows {
+		i, ok := index[row.Name]
+		if !ok {
+			i = len(roles)
+			index[row.Name] = i
+			roles = append(roles, Role{Name: row.Name, Description: row.Description, Permissions: []string{}})
+		}
+
+		if row.Permission.Valid {
+			roles[i].Permissions = append(roles[i].Permissions, row.Permission.String)
+		}
+	}
+
+	return roles
+}
+
+const getRoles = `SELECT role.name, role.description, role_permission.permission_name
+								FROM role
+								LEFT JOIN role_permission
+								ON role_permission.role_name = role.name
+								ORDER BY role.name, role_permission.permission_name`
+
+func (r *RoleSQLRepository) GetRoles(ctx context.Context) (_ []Role, err error) {
+	ctx, end := r.db.start(ctx, "GetRoles")
+	defer end(&err)
+
+	var rows []rolePermission
+	if err := r.db.SelectContext(ctx, &rows, getRoles); err != nil {
+		return nil, err
+	}
+
+	return groupRoles(rows), nil
+}
+
+const getRole = `SELECT role.name, role.description, role_permission.permission_name
+								FROM role
+								LEFT JOIN role_permission
+								ON role_permission.role_name = role.name
+								WHERE role.name = :name
+								ORDER BY role_permission.permission_name`
+
+func (r *RoleSQLRepository) GetRole(ctx context.Context, name string) (_ Role, err error) {
+	ctx, end := r.db.start(ctx, "GetRole")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getRole)
+	if err != nil {
+		return Role{}, err
+	}
+
+	defer stmt.Close()
+
+	var rows []rolePermission
+	if err := stmt.SelectContext(ctx, &rows, map[string]interface{}{"name": name}); err != nil {
+		return Role{}, err
+	}
+
+	roles := groupRoles(rows)
+	if len(roles) == 0 {
+		return Role{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return roles[0], nil
+}
+
+const (
+	insertRole           = `INSERT INTO role (name, description) VALUES (:name, :description)`
+	insertRolePermission = `INSERT INTO role_permission (role_name, permission_name) VALUES (:role_name, :permission_name)`
+)
+
+func (r *RoleSQLRepository) SaveRole(ctx context.Context, role Role) (err error) {
+	ctx, end := r.db.start(ctx, "SaveRole")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	_, err = tx.NamedExecContext(ctx, insertRole, map[string]interface{}{
+		"name":        role.Name,
+		"description": role.Description,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = insertRolePermissions(ctx, tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const deleteRolePermissions = `DELETE FROM role_permission WHERE role_name = :role_name`
+
+func (r *RoleSQLRepository) UpdateRolePermissions(ctx context.Context, name string, permissions []string) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateRolePermissions")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	_, err = tx.NamedExecContext(ctx, deleteRolePermissions, map[string]interface{}{"role_name": name})
+	if err != nil {
+		return err
+	}
+
+	if err = insertRolePermissions(ctx, tx, name, permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const (
+	deleteRoleAssignments = `DELETE FROM user_role WHERE role_name = :role_name`
+	deleteRole            = `DELETE FROM role WHERE name = :role_name`
+)
+
+func (r *RoleSQLRepository) DeleteRole(ctx context.Context, name string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteRole")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{"role_name": name}
+	for _, q := range []string{deleteRolePermissions, deleteRoleAssignments} {
+		if _, err = tx.NamedExecContext(ctx, q, queryParams); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.NamedExecContext(ctx, deleteRole, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const getPermissions = `SELECT name, description FROM permission ORDER BY name`
+
+func (r *RoleSQLRepository) GetPermissions(ctx context.Context) (_ []Permission, err error) {
+	ctx, end := r.db.start(ctx, "GetPermissions")
+	defer end(&err)
+
+	var permissions []Permission
+	if err := r.db.SelectContext(ctx, &permissions, getPermissions); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+const insertPermission = `INSERT INTO permission (name, description) VALUES (:name, :description)`
+
+func (r *RoleSQLRepository) SavePermission(ctx context.Context, permission Permission) (err error) {
+	ctx, end := r.db.start(ctx, "SavePermission")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertPermission, map[string]interface{}{
+		"name":        permission.Name,
+		"description": permission.Description,
+	})
+
+	return err
+}
+
+const getUserRoles = `SELECT role.name, role.description, role_permission.permission_name
+								FROM user_role
+								INNER JOIN role
+								ON role.name = user_role.role_name
+								LEFT JOIN role_permission
+								ON role_permission.role_name = role.name
+								WHERE user_role.user_id = :user_id
+								ORDER BY role.name, role_permission.permission_name`
+
+func (r *RoleSQLRepository) GetUserRoles(ctx context.Context, userID string) (_ []Role, err error) {
+	ctx, end := r.db.start(ctx, "GetUserRoles")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUserRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var rows []rolePermission
+	if err := stmt.SelectContext(ctx, &rows, map[string]interface{}{"user_id": userID}); err != nil {
+		return nil, err
+	}
+
+	return groupRoles(rows), nil
+}
+
+const insertUserRole = `INSERT INTO user_role (user_id, role_name) VALUES (:user_id, :role_name)`
+
+func (r *RoleSQLRepository) AssignRole(ctx context.Context, userID string, role string) (err error) {
+	ctx, end := r.db.start(ctx, "AssignRole")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertUserRole, map[string]interface{}{
+		"user_id":   userID,
+		"role_name": role,
+	})
+
+	return err
+}
+
+const deleteUserRole = `DELETE FROM user_role WHERE user_id = :user_id AND role_name = :role_name`
+
+func (r *RoleSQLRepository) UnassignRole(ctx context.Context, userID string, role string) (err error) {
+	ctx, end := r.db.start(ctx, "UnassignRole")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, deleteUserRole, map[string]interface{}{
+		"user_id":   userID,
+		"role_name": role,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+func insertRolePermissions(ctx context.Context, tx *sqlx.Tx, role string, permissions []string) error {
+	for _, permission := range permissions {
+		_, err := tx.NamedExecContext(ctx, insertRolePermission, map[string]interface{}{
+			"role_name":       role,
+			"permission_name": permission,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserRoles(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewRoleRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT role.name, role.description, role_permission.permission_name
+			FROM user_role
+			INNER JOIN role
+			ON role.name = user_role.role_name
+			LEFT JOIN role_permission
+			ON role_permission.role_name = role.name
+			WHERE user_role.user_id = ?
+			ORDER BY role.name, role_permission.permission_name`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("id").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"name", "description", "permission_name"}).
+				AddRow("admin", "Full access", "roles:read").
+				AddRow("admin", "Full access", "roles:write").
+				AddRow("empty", "No permissions", nil),
+		)
+
+	// When
+	resp, err := r.GetUserRoles(context.Background(), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []Role{
+		{Name: "admin", Description: "Full access", Permissions: []string{"roles:read", "roles:write"}},
+		{Name: "empty", Description: "No permissions", Permissions: []string{}},
+	}, resp)
+}
+
+func TestGetRole_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewRoleRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT role.name, role.description, role_permission.permission_name
+			FROM role
+			LEFT JOIN role_permission
+			ON role_permission.role_name = role.name
+			WHERE role.name = ?
+			ORDER BY role_permission.permission_name`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description", "permission_name"}))
+
+	// When
+	_, err = r.GetRole(context.Background(), "admin")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestSaveRole(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewRoleRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	role := Role{Name: "auditor", Description: "Read only", Permissions: []string{"roles:read"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO role (name, description) VALUES (?, ?)`).
+		WithArgs(role.Name, role.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO role_permission (role_name, permission_name) VALUES (?, ?)`).
+		WithArgs(role.Name, "roles:read").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// When
+	err = r.SaveRole(context.Background(), role)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveRole_InsertingPermissionError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewRoleRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	role := Role{Name: "auditor", Permissions: []string{"roles:read"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO role (name, description) VALUES (?, ?)`).
+		WithArgs(role.Name, role.Description).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO role_permission (role_name, permission_name) VALUES (?, ?)`).
+		WithArgs(role.Name, "roles:read").
+		WillReturnError(errors.New("db error"))
+	mock.ExpectRollback()
+
+	// When
+	err = r.SaveRole(context.Background(), role)
+
+	// Then
+	require.EqualError(t, err, "db error")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRole_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewRoleRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM role_permission WHERE role_name = ?`).
+		WithArgs("auditor").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM user_role WHERE role_name = ?`).
+		WithArgs("auditor").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM role WHERE name = ?`).
+		WithArgs("auditor").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// When
+	err = r.DeleteRole(context.Background(), "auditor")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
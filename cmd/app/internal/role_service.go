@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	PermissionRolesRead  = "roles:read"
+	PermissionRolesWrite = "roles:write"
+)
+
+type RoleRepository interface {
+	GetRoles(ctx context.Context) ([]Role, error)
+	GetRole(ctx context.Context, name string) (Role, error)
+	SaveRole(ctx context.Context, role Role) error
+	UpdateRolePermissions(ctx context.Context, name string, permissions []string) error
+	DeleteRole(ctx context.Context, name string) error
+	GetPermissions(ctx context.Context) ([]Permission, error)
+	SavePermission(ctx context.Context, permission Permission) error
+	GetUserRoles(ctx context.Context, userID string) ([]Role, error)
+	AssignRole(ctx context.Context, userID string, role string) error
+	UnassignRole(ctx context.Context, userID string, role string) error
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (s *Service) AuthorizeWithRoles(ctx context.Context, origin Origin, token string) (User, error) {
+	user, err := s.authorize(ctx, origin, token)
+	if err != nil {
+		return User{}, err
+	}
+
+	return s.withRoles(ctx, user)
+}
+
+func (s *Service) ListRoles(ctx context.Context) ([]Role, error) {
+	return s.RoleRepository.GetRoles(ctx)
+}
+
+func (s *Service) GetRole(ctx context.Context, name string) (Role, error) {
+	return s.RoleRepository.GetRole(ctx, name)
+}
+
+func (s *Service) CreateRole(ctx context.Context, req CreateRoleRequest) (Role, error) {
+	_, err := s.RoleRepository.GetRole(ctx, req.Name)
+	if err == nil {
+		return Role{}, fmt.Errorf("%w: role already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	if !errors.Is(err, internal.ErrResourceNotFound) {
+		return Role{}, err
+	}
+
+	if err := s.validatePermissions(ctx, req.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	role := Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := s.RoleRepository.SaveRole(ctx, role); err != nil {
+		return Role{}, err
+	}
+
+	return role, nil
+}
+
+func (s *Service) UpdateRolePermissions(ctx context.Context, name string, req UpdateRolePermissionsRequest) (Role, error) {
+	role, err := s.RoleRepository.GetRole(ctx, name)
+	if err != nil {
+		return Role{}, err
+	}
+
+	if err := s.validatePermissions(ctx, req.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	if err := s.RoleRepository.UpdateRolePermissions(ctx, name, req.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	role.Permissions = req.Permissions
+
+	return role, nil
+}
+
+func (s *Service) DeleteRole(ctx context.Context, name string) error {
+	return s.RoleRepository.DeleteRole(ctx, name)
+}
+
+func (s *Service) ListPermissions(ctx context.Context) ([]Permission, error) {
+	return s.RoleRepository.GetPermissions(ctx)
+}
+
+func (s *Service) CreatePermission(ctx context.Context, req CreatePermissionRequest) (Permission, error) {
+	permissions, err := s.RoleRepository.GetPermissions(ctx)
+	if err != nil {
+		return Permission{}, err
+	}
+
+	for _, p := range permissions {
+		if p.Name == req.Name {
+			return Permission{}, fmt.Errorf("%w: permission already exists", internal.ErrResourceAlreadyExists)
+		}
+	}
+
+	permission := Permission{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := s.RoleRepository.SavePermission(ctx, permission); err != nil {
+		return Permission{}, err
+	}
+
+	return permission, nil
+}
+
+func (s *Service) ListUserRoles(ctx context.Context, userID string) ([]Role, error) {
+	if _, err := s.UserRepository.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.RoleRepository.GetUserRoles(ctx, userID)
+}
+
+func (s *Service) AssignRole(ctx context.Context, userID string, role string) error {
+	if _, err := s.UserRepository.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if _, err := s.RoleRepository.GetRole(ctx, role); err != nil {
+		return err
+	}
+
+	roles, err := s.RoleRepository.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range roles {
+		if r.Name == role {
+			return nil
+		}
+	}
+
+	return s.RoleRepository.AssignRole(ctx, userID, role)
+}
+
+func (s *Service) UnassignRole(ctx context.Context, userID string, role string) error {
+	return s.RoleRepository.UnassignRole(ctx, userID, role)
+}
+
+func (s *Service) validatePermissions(ctx context.Context, permissions []string) error {
+	unknown, err := s.unknownPermission(ctx, permissions)
+	if err != nil {
+		return err
+	}
+
+	if unknown != "" {
+		return fmt.Errorf("%w: unknown permission %s", internal.ErrUnprocessableEntity, unknown)
+	}
+
+	return nil
+}
+
+// unknownPermission returns the first of permissions that doesn't exist, or "" if they all do.
+func (s *Service) unknownPermission(ctx context.Context, permissions []string) (string, error) {
+	known, err := s.RoleRepository.GetPermissions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]bool, len(known))
+	for _, p := range known {
+		names[p.Name] = true
+	}
+
+	for _, p := range permissions {
+		if !names[p] {
+			return p, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *Service) withRoles(ctx context.Context, user User) (User, error) {
+	roles, err := s.RoleRepository.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return User{}, fmt.Errorf("getting user roles: %w", err)
+	}
+
+	permissions := make(map[string]bool)
+	user.Roles = make([]string, 0, len(roles))
+	for _, role := range roles {
+		user.Roles = append(user.Roles, role.Name)
+		for _, p := range role.Permissions {
+			permissions[p] = true
+		}
+	}
+
+	user.Permissions = make([]string, 0, len(permissions))
+	for p := range permissions {
+		if user.scoped && !contains(user.scopes, p) {
+			continue
+		}
+
+		user.Permissions = append(user.Permissions, p)
+	}
+
+	sort.Strings(user.Permissions)
+
+	return user, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
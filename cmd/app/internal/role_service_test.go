@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type roleRepository struct {
+	mock.Mock
+}
+
+func (r *roleRepository) GetRoles(ctx context.Context) ([]Role, error) {
+	args := r.Called()
+	return args.Get(0).([]Role), args.Error(1)
+}
+
+func (r *roleRepository) GetRole(ctx context.Context, name string) (Role, error) {
+	args := r.Called(name)
+	return args.Get(0).(Role), args.Error(1)
+}
+
+func (r *roleRepository) SaveRole(ctx context.Context, role Role) error {
+	return r.Called(role).Error(0)
+}
+
+func (r *roleRepository) UpdateRolePermissions(ctx context.Context, name string, permissions []string) error {
+	return r.Called(name, permissions).Error(0)
+}
+
+func (r *roleRepository) DeleteRole(ctx context.Context, name string) error {
+	return r.Called(name).Error(0)
+}
+
+func (r *roleRepository) GetPermissions(ctx context.Context) ([]Permission, error) {
+	args := r.Called()
+	return args.Get(0).([]Permission), args.Error(1)
+}
+
+func (r *roleRepository) SavePermission(ctx context.Context, permission Permission) error {
+	return r.Called(permission).Error(0)
+}
+
+func (r *roleRepository) GetUserRoles(ctx context.Context, userID string) ([]Role, error) {
+	args := r.Called(userID)
+	return args.Get(0).([]Role), args.Error(1)
+}
+
+func (r *roleRepository) AssignRole(ctx context.Context, userID string, role string) error {
+	return r.Called(userID, role).Error(0)
+}
+
+func (r *roleRepository) UnassignRole(ctx context.Context, userID string, role string) error {
+	return r.Called(userID, role).Error(0)
+}
+
+func TestAuthorizeWithRoles(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token, _ := _newJWT(u)
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{
+		{Name: "admin", Permissions: []string{PermissionRolesWrite, PermissionRolesRead}},
+		{Name: "auditor", Permissions: []string{PermissionRolesRead}},
+	}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.AuthorizeWithRoles(context.Background(), Origin{}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []string{"admin", "auditor"}, resp.Roles)
+	require.Equal(t, []string{PermissionRolesRead, PermissionRolesWrite}, resp.Permissions)
+	require.True(t, resp.HasPermission(PermissionRolesWrite))
+	require.False(t, resp.HasPermission("users:delete"))
+}
+
+func TestAuthorizeWithRoles_PersonalAccessTokenScopes(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	token := "auth_pat_secret"
+
+	r := &repository{}
+	r.On("GetUserByID", u.ID).Return(u, nil)
+
+	p := &personalAccessTokenRepository{}
+	p.On("GetPersonalAccessTokenByHash", hashToken(token)).
+		Return(PersonalAccessToken{UserID: u.ID, Scopes: []string{PermissionRolesRead}}, nil)
+	p.On("TouchPersonalAccessToken", mock.Anything, mock.AnythingOfType("time.Time")).Return(nil)
+
+	rr := &roleRepository{}
+	rr.On("GetUserRoles", u.ID).Return([]Role{
+		{Name: "admin", Permissions: []string{PermissionRolesRead, PermissionRolesWrite}},
+	}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.PersonalAccessTokenRepository = p
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.AuthorizeWithRoles(context.Background(), Origin{}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []string{PermissionRolesRead}, resp.Permissions)
+}
+
+func TestNewJWT_Roles(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com", Roles: []string{"admin"}}
+
+	// When
+	token, err := NewService(&repository{}, nil, testConfig).newJWT(u, tokenExpiration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	var c claims
+	_, err = jwt.ParseWithClaims(token, &c, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testConfig.Auth.SigningKey), nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin"}, c.Roles)
+	require.Equal(t, `{"id":"id","firstname":"","lastname":"","email":"mateo.ferrari97@gmail.com"}`, c.Subject)
+}
+
+func TestCreateRole(t *testing.T) {
+	// Given
+	req := CreateRoleRequest{Name: "auditor", Permissions: []string{PermissionRolesRead}}
+
+	rr := &roleRepository{}
+	rr.On("GetRole", "auditor").Return(Role{}, internal.ErrResourceNotFound)
+	rr.On("GetPermissions").Return([]Permission{{Name: PermissionRolesRead}}, nil)
+	rr.On("SaveRole", Role{Name: "auditor", Permissions: []string{PermissionRolesRead}}).Return(nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	resp, err := s.CreateRole(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "auditor", resp.Name)
+	rr.AssertExpectations(t)
+}
+
+func TestCreateRole_AlreadyExists(t *testing.T) {
+	// Given
+	rr := &roleRepository{}
+	rr.On("GetRole", "admin").Return(Role{Name: "admin"}, nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	_, err := s.CreateRole(context.Background(), CreateRoleRequest{Name: "admin"})
+
+	// Then
+	require.EqualError(t, err, "resource already exists: role already exists")
+}
+
+func TestCreateRole_UnknownPermission(t *testing.T) {
+	// Given
+	rr := &roleRepository{}
+	rr.On("GetRole", "auditor").Return(Role{}, internal.ErrResourceNotFound)
+	rr.On("GetPermissions").Return([]Permission{{Name: PermissionRolesRead}}, nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	_, err := s.CreateRole(context.Background(), CreateRoleRequest{Name: "auditor", Permissions: []string{"unknown"}})
+
+	// Then
+	require.EqualError(t, err, "unprocessable entity: unknown permission unknown")
+}
+
+func TestAssignRole_AlreadyAssigned(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("GetUserByID", "id").Return(User{ID: "id"}, nil)
+
+	rr := &roleRepository{}
+	rr.On("GetRole", "admin").Return(Role{Name: "admin"}, nil)
+	rr.On("GetUserRoles", "id").Return([]Role{{Name: "admin"}}, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	err := s.AssignRole(context.Background(), "id", "admin")
+
+	// Then
+	require.NoError(t, err)
+	rr.AssertNotCalled(t, "AssignRole", "id", "admin")
+}
+
+func TestAssignRole_UserNotFound(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("GetUserByID", "id").Return(User{}, internal.ErrResourceNotFound)
+
+	s := NewService(r, nil, testConfig)
+	s.RoleRepository = &roleRepository{}
+
+	// When
+	err := s.AssignRole(context.Background(), "id", "admin")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+}
@@ -2,47 +2,74 @@ package internal
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/cmd/server"
 	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
-var mySigningKey = os.Getenv("PRIVATE_KEY")
-
-var config = &oauth2.Config{
-	ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-	ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-	Endpoint:     google.Endpoint,
-	RedirectURL:  "http://localhost:8081/login/google/callback",
-	Scopes: []string{
-		"https://www.googleapis.com/auth/userinfo.email",
-	},
-}
-
-const state = "random"
+const (
+	state           = "random"
+	tokenExpiration = 15 * time.Minute
+)
 
 type Client interface {
-	GetUserEmailFromAccessToken(accessToken string) (string, error)
+	GetUserEmailFromAccessToken(ctx context.Context, accessToken string) (string, error)
 }
 
 type Repository interface {
-	SaveUser(newUser NewUser) error
-	GetUserByEmail(email string) (User, error)
-	FindUserByEmail(email string) error
+	SaveUser(ctx context.Context, newUser NewUser) error
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByID(ctx context.Context, id string) (User, error)
+	FindUserByEmail(ctx context.Context, email string) error
+	GetUsers(ctx context.Context, query UserQuery) ([]User, int, error)
+	UpdateUserStatus(ctx context.Context, id string, status string) error
+	RequirePasswordReset(ctx context.Context, id string) error
+	ResetPassword(ctx context.Context, id string, password string) error
+	DeleteUser(ctx context.Context, id string) error
+	UpdateUser(ctx context.Context, user User, previousUpdatedAt time.Time) error
+	GetUserPassword(ctx context.Context, id string) (string, error)
+	ScheduleUserDeletion(ctx context.Context, id string, deleteAfter *time.Time) error
+	GetUsersScheduledForDeletion(ctx context.Context, now time.Time) ([]string, error)
 }
 
 type Service struct {
-	UserRepository Repository
-	Client         Client
+	UserRepository                Repository
+	DeviceCodeRepository          DeviceCodeRepository
+	PersonalAccessTokenRepository PersonalAccessTokenRepository
+	RoleRepository                RoleRepository
+	OrganizationRepository        OrganizationRepository
+	AuditRepository               AuditRepository
+	SessionRepository             SessionRepository
+	WebhookRepository             WebhookRepository
+	SignupInvitationRepository    SignupInvitationRepository
+	Client                        Client
+	HTTPClient                    *http.Client
+	WebhookClient                 WebhookClient
+	Metrics                       *Metrics
+	SignupMode                    string
+
+	signingKey   []byte
+	bcryptCost   int
+	oauthConfig  *oauth2.Config
+	oauthTimeout time.Duration
+
+	deviceVerificationURL string
 }
 
 type NewUser struct {
@@ -54,37 +81,99 @@ type NewUser struct {
 }
 
 type User struct {
-	ID        string `json:"id"`
-	Firstname string `json:"firstname"`
-	Lastname  string `json:"lastname"`
-	Email     string `json:"email"`
+	ID          string   `json:"id"`
+	Firstname   string   `json:"firstname"`
+	Lastname    string   `json:"lastname"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	OrganizationID   string `json:"organization_id,omitempty"`
+	OrganizationRole string `json:"organization_role,omitempty"`
+
+	Status                string     `json:"status,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"`
+	CreatedAt             *time.Time `json:"created_at,omitempty"`
+	UpdatedAt             *time.Time `json:"updated_at,omitempty"`
+	DeleteAfter           *time.Time `json:"delete_after,omitempty"`
+
+	Actor *Actor `json:"act,omitempty"`
+
+	scopes    []string
+	scoped    bool
+	sessionID string
 }
 
-func NewService(repository Repository, client Client) *Service {
+func (u User) HasPermission(permission string) bool {
+	return contains(u.Permissions, permission)
+}
+
+func (u User) PrincipalID() string {
+	return u.ID
+}
+
+type claims struct {
+	jwt.StandardClaims
+	// Roles tells clients what the user could do when the token was issued. Authorization doesn't
+	// trust it: AuthorizeWithRoles reads the roles from the repository on every request, so
+	// unassigning a role takes effect without waiting for the token to expire.
+	Roles            []string `json:"roles,omitempty"`
+	OrganizationID   string   `json:"org_id,omitempty"`
+	OrganizationRole string   `json:"org_role,omitempty"`
+	Actor            *Actor   `json:"act,omitempty"`
+	SessionID        string   `json:"sid,omitempty"`
+	// Scope limits the token to these space separated permissions, like a personal access token's
+	// scopes. Tokens without it carry every permission of the user.
+	Scope string `json:"scope,omitempty"`
+}
+
+func NewService(repository Repository, client Client, cfg config.Config) *Service {
 	return &Service{
-		UserRepository: repository,
-		Client:         client,
+		UserRepository:        repository,
+		Client:                client,
+		SignupMode:            cfg.Auth.SignupMode,
+		signingKey:            []byte(cfg.Auth.SigningKey),
+		bcryptCost:            cfg.Auth.BcryptCost,
+		oauthTimeout:          cfg.Google.Timeout,
+		deviceVerificationURL: strings.TrimSuffix(cfg.Server.PublicURL, "/") + getDevice,
+		oauthConfig: &oauth2.Config{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			Endpoint:     google.Endpoint,
+			RedirectURL:  cfg.Google.RedirectURL,
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+			},
+		},
 	}
 }
 
-func (s *Service) Register(newUser RegisterRequest) error {
-	err := s.UserRepository.FindUserByEmail(newUser.Email)
-	if err == nil {
-		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+func (s *Service) Register(ctx context.Context, origin Origin, newUser RegisterRequest) error {
+	user, err := s.register(ctx, newUser)
+	s.Metrics.registration(err)
+	if err := s.auditCompleted(ctx, origin, AuditEvent{Actor: user.ID, Action: AuditActionRegister, Target: user.ID}, err); err != nil {
+		return err
 	}
 
-	if !errors.Is(err, internal.ErrResourceNotFound) {
-		return err
+	s.notifyWebhooks(ctx, WebhookEventUserRegistered, user)
+
+	return nil
+}
+
+func (s *Service) register(ctx context.Context, newUser RegisterRequest) (User, error) {
+	invitation, err := s.signupInvitation(ctx, newUser)
+	if err != nil {
+		return User{}, err
 	}
 
 	id, err := uuid.NewV4()
 	if err != nil {
-		return fmt.Errorf("creating user: %v", err)
+		return User{}, fmt.Errorf("creating user: %v", err)
 	}
 
-	b, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), 10)
+	b, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), s.bcryptCost)
 	if err != nil {
-		return fmt.Errorf("generating password: %v", err)
+		return User{}, fmt.Errorf("generating password: %v", err)
 	}
 
 	user := NewUser{
@@ -95,16 +184,78 @@ func (s *Service) Register(newUser RegisterRequest) error {
 		Password:  string(b),
 	}
 
-	return s.UserRepository.SaveUser(user)
+	// Duplicate emails are rejected by the login.email unique index, which the repository reports
+	// as internal.ErrResourceAlreadyExists. Checking beforehand would race with another request.
+	if err := s.UserRepository.SaveUser(ctx, user); err != nil {
+		return User{}, err
+	}
+
+	// The invitation is consumed once the user exists, so a rejected registration leaves it usable.
+	// It's bound to the email that was just saved, so no other registration can race for it.
+	if invitation != nil {
+		now := time.Now()
+		invitation.UsedAt = &now
+		invitation.UsedBy = user.ID
+		if err := s.SignupInvitationRepository.ConsumeSignupInvitation(ctx, *invitation); err != nil {
+			return User{}, err
+		}
+
+		for _, role := range invitation.Roles {
+			if err := s.RoleRepository.AssignRole(ctx, user.ID, role); err != nil {
+				return User{}, err
+			}
+		}
+	}
+
+	return User{ID: user.ID, Firstname: user.Firstname, Lastname: user.Lastname, Email: user.Email}, nil
+}
+
+func (s *Service) Authorize(ctx context.Context, token string) (User, error) {
+	return s.authorize(ctx, originFromContext(ctx), token)
+}
+
+func (s *Service) authorize(ctx context.Context, origin Origin, token string) (User, error) {
+	user, err := s.authorizeToken(ctx, token)
+	if err != nil {
+		s.Metrics.authorizeFailure(err)
+		if isMalformedToken(err) {
+			return User{}, err
+		}
+
+		return User{}, s.audit(ctx, origin, AuditEvent{Action: AuditActionAuthorize}, err)
+	}
+
+	server.SetRequestUser(ctx, user.ID)
+
+	return user, nil
+}
+
+// malformedTokenError is returned for tokens that don't parse or aren't signed with our key. They
+// identify nobody, so they're only counted in metrics and kept out of the audit chain.
+type malformedTokenError struct {
+	err error
+}
+
+func (e malformedTokenError) Error() string {
+	return fmt.Sprintf("parsing token: %v", e.err)
+}
+
+func isMalformedToken(err error) bool {
+	var malformed malformedTokenError
+	return errors.As(err, &malformed)
 }
 
-func (s *Service) Authorize(token string) (User, error) {
+func (s *Service) authorizeToken(ctx context.Context, token string) (User, error) {
+	if isPersonalAccessToken(token) {
+		return s.authorizePersonalAccessToken(ctx, token)
+	}
+
 	t, err := jwt.Parse(token, func(token *jwt.Token) (i interface{}, err error) {
-		return []byte(mySigningKey), nil
+		return s.signingKey, nil
 	})
 
 	if err != nil {
-		return User{}, fmt.Errorf("parsing token: %v", err)
+		return User{}, malformedTokenError{err: err}
 	}
 
 	if !t.Valid {
@@ -126,63 +277,239 @@ func (s *Service) Authorize(token string) (User, error) {
 		return User{}, fmt.Errorf("decoding claims: %v", err)
 	}
 
-	user, err := s.UserRepository.GetUserByEmail(u.Email)
+	user, err := s.UserRepository.GetUserByEmail(ctx, u.Email)
 	if err != nil {
 		return User{}, err
 	}
 
+	if err := ensureActive(user); err != nil {
+		return User{}, err
+	}
+
+	user.OrganizationID, _ = c["org_id"].(string)
+	user.OrganizationRole, _ = c["org_role"].(string)
+
+	if scope, ok := c["scope"].(string); ok && scope != "" {
+		user.scopes = strings.Fields(scope)
+		user.scoped = true
+	}
+
+	if act, ok := c["act"].(map[string]interface{}); ok {
+		actor, err := s.impersonationActor(ctx, act)
+		if err != nil {
+			return User{}, err
+		}
+
+		user.Actor = &actor
+	}
+
+	if sid, ok := c["sid"].(string); ok && sid != "" {
+		if err := s.checkSession(ctx, user.ID, sid); err != nil {
+			return User{}, err
+		}
+
+		user.sessionID = sid
+	}
+
 	return user, nil
 }
 
+func (s *Service) UpdateMe(ctx context.Context, token string, etag string, req UpdateMeRequest) (User, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return User{}, err
+	}
+
+	if user.UpdatedAt == nil {
+		return User{}, errors.New("user has no updated_at")
+	}
+
+	if etag != "*" && etag != UserETag(user) {
+		return User{}, fmt.Errorf("%w: user has been modified", internal.ErrPreconditionFailed)
+	}
+
+	if req.Firstname != nil {
+		user.Firstname = *req.Firstname
+	}
+
+	if req.Lastname != nil {
+		user.Lastname = *req.Lastname
+	}
+
+	previousUpdatedAt := *user.UpdatedAt
+	updatedAt := time.Now().Truncate(time.Millisecond)
+	if !updatedAt.After(previousUpdatedAt) {
+		updatedAt = previousUpdatedAt.Add(time.Millisecond)
+	}
+
+	user.UpdatedAt = &updatedAt
+	if err := s.UserRepository.UpdateUser(ctx, user, previousUpdatedAt); err != nil {
+		return User{}, err
+	}
+
+	s.notifyWebhooks(ctx, WebhookEventUserUpdated, user)
+
+	return user, nil
+}
+
+func UserETag(user User) string {
+	if user.UpdatedAt == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(`"%d"`, user.UpdatedAt.UnixNano()/int64(time.Millisecond))
+}
+
 func (s *Service) LoginWithGoogle() (string, error) {
-	return config.AuthCodeURL(state), nil
+	return s.oauthConfig.AuthCodeURL(state), nil
 }
 
-func (s *Service) LoginWithGoogleCallback(code string) (string, error) {
-	token, err := config.Exchange(context.TODO(), code)
+func (s *Service) LoginWithGoogleCallback(ctx context.Context, origin Origin, code string) (string, error) {
+	user, t, err := s.loginWithGoogleCallback(ctx, origin, code)
+	s.Metrics.login(LoginProviderGoogle, err)
+	event := AuditEvent{Actor: user.ID, Action: AuditActionLoginGoogle, Target: user.ID}
+	if err := s.auditCompleted(ctx, origin, event, err); err != nil {
+		return "", err
+	}
+
+	return t, nil
+}
+
+func (s *Service) loginWithGoogleCallback(ctx context.Context, origin Origin, code string) (User, string, error) {
+	email, err := s.googleEmail(ctx, code)
+	if err != nil {
+		return User{}, "", err
+	}
+
+	user, err := s.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, internal.ErrResourceAlreadyExists) {
+		return User{Email: email}, "", err
+	}
+
+	if errors.Is(err, internal.ErrResourceAlreadyExists) {
+		return User{Email: email}, "", internal.ErrResourceNotFound
+	}
+
+	if err := ensureActive(user); err != nil {
+		return user, "", err
+	}
+
+	user.sessionID, err = s.startSession(ctx, origin, user, tokenExpiration)
+	if err != nil {
+		return user, "", err
+	}
+
+	t, err := s.issueToken(ctx, user)
+	if err != nil {
+		return user, "", fmt.Errorf("authorizing user: %v", err)
+	}
+
+	return user, t, nil
+}
+
+// googleEmail exchanges code and fetches the email of its owner, both bounded by oauthTimeout.
+func (s *Service) googleEmail(ctx context.Context, code string) (string, error) {
+	if s.oauthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.oauthTimeout)
+		defer cancel()
+	}
+
+	if s.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, s.HTTPClient)
+	}
+
+	token, err := s.oauthConfig.Exchange(ctx, code)
 	if err != nil {
 		return "", fmt.Errorf("getting token from google: %v", err)
 	}
 
-	email, err := s.Client.GetUserEmailFromAccessToken(token.AccessToken)
+	email, err := s.Client.GetUserEmailFromAccessToken(ctx, token.AccessToken)
 	if err != nil {
 		return "", fmt.Errorf("getting user email from google: %v", err)
 	}
 
-	user, err := s.UserRepository.GetUserByEmail(email)
-	if err != nil && !errors.Is(err, internal.ErrResourceAlreadyExists) {
-		return "", err
+	return email, nil
+}
+
+func (s *Service) Logout(ctx context.Context, origin Origin, token string) error {
+	var user User
+	if token != "" {
+		user, _ = s.authorizeToken(ctx, token)
 	}
 
-	if errors.Is(err, internal.ErrResourceAlreadyExists) {
-		return "", internal.ErrResourceNotFound
+	if err := s.revokeCurrentSession(ctx, user); err != nil {
+		return err
 	}
 
-	t, err := newJWT(user)
+	return s.audit(ctx, origin, AuditEvent{Actor: user.ID, Action: AuditActionLogout, Target: user.ID}, nil)
+}
+
+func (s *Service) issueToken(ctx context.Context, user User) (string, error) {
+	user, err := s.withRoles(ctx, user)
 	if err != nil {
-		return "", fmt.Errorf("authorizing user: %v", err)
+		return "", err
 	}
 
-	return t, nil
+	expiration := tokenExpiration
+	if user.Actor != nil {
+		expiration = impersonationTokenExpiration
+	}
+
+	return s.newJWT(user, expiration)
 }
 
-func newJWT(user User) (string, error) {
-	u, err := json.Marshal(user)
+func (s *Service) newJWT(user User, expiration time.Duration) (string, error) {
+	u, err := json.Marshal(User{
+		ID:        user.ID,
+		Firstname: user.Firstname,
+		Lastname:  user.Lastname,
+		Email:     user.Email,
+	})
 	if err != nil {
 		return "", fmt.Errorf("marshaling user: %v", err)
 	}
 
-	claims := &jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
-		Subject:   string(u),
+	claims := &claims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(expiration).Unix(),
+			Subject:   string(u),
+		},
+		Roles:            user.Roles,
+		OrganizationID:   user.OrganizationID,
+		OrganizationRole: user.OrganizationRole,
+		Actor:            user.Actor,
+		SessionID:        user.sessionID,
+	}
+
+	if user.scoped {
+		claims.Scope = strings.Join(user.scopes, " ")
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	t, err := token.SignedString([]byte(mySigningKey))
+	t, err := token.SignedString(s.signingKey)
 	if err != nil {
 		return "", fmt.Errorf("creating token: %v", err)
 	}
 
 	return t, nil
 }
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,35 +1,103 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var testConfig = config.Config{
+	Server: config.Server{
+		PublicURL: "http://localhost:8081",
+	},
+	Auth: config.Auth{
+		SigningKey: "secret",
+		BcryptCost: bcrypt.MinCost,
+		SignupMode: SignupModeOpen,
+	},
+	Google: config.Google{
+		ClientID:    "176380119677-5r99e6b9jqho14cvfpc0inmeb1m48gkr.apps.googleusercontent.com",
+		RedirectURL: "http://localhost:8081/login/google/callback",
+	},
+}
+
 type repository struct {
 	mock.Mock
 }
 
-func (r *repository) SaveUser(newUser NewUser) error {
+func (r *repository) SaveUser(ctx context.Context, newUser NewUser) error {
 	return r.Called(newUser).Error(0)
 }
 
-func (r *repository) GetUserByEmail(email string) (User, error) {
+func (r *repository) GetUserByEmail(ctx context.Context, email string) (User, error) {
 	args := r.Called(email)
 	return args.Get(0).(User), args.Error(1)
 }
 
-func (r *repository) FindUserByEmail(email string) error {
+func (r *repository) GetUserByID(ctx context.Context, id string) (User, error) {
+	args := r.Called(id)
+	return args.Get(0).(User), args.Error(1)
+}
+
+func (r *repository) FindUserByEmail(ctx context.Context, email string) error {
 	return r.Called(email).Error(0)
 }
 
+func (r *repository) GetUsers(ctx context.Context, query UserQuery) ([]User, int, error) {
+	args := r.Called(query)
+	return args.Get(0).([]User), args.Int(1), args.Error(2)
+}
+
+func (r *repository) UpdateUserStatus(ctx context.Context, id string, status string) error {
+	return r.Called(id, status).Error(0)
+}
+
+func (r *repository) RequirePasswordReset(ctx context.Context, id string) error {
+	return r.Called(id).Error(0)
+}
+
+func (r *repository) ResetPassword(ctx context.Context, id string, password string) error {
+	return r.Called(id, password).Error(0)
+}
+
+func (r *repository) DeleteUser(ctx context.Context, id string) error {
+	return r.Called(id).Error(0)
+}
+
+func (r *repository) UpdateUser(ctx context.Context, user User, previousUpdatedAt time.Time) error {
+	return r.Called(user, previousUpdatedAt).Error(0)
+}
+
+func (r *repository) GetUserPassword(ctx context.Context, id string) (string, error) {
+	args := r.Called(id)
+	return args.String(0), args.Error(1)
+}
+
+func (r *repository) ScheduleUserDeletion(ctx context.Context, id string, deleteAfter *time.Time) error {
+	return r.Called(id, deleteAfter).Error(0)
+}
+
+func (r *repository) GetUsersScheduledForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	args := r.Called(now)
+	return args.Get(0).([]string), args.Error(1)
+}
+
 func TestRegister(t *testing.T) {
 	// Given
 	u := RegisterRequest{
@@ -40,13 +108,12 @@ func TestRegister(t *testing.T) {
 	}
 
 	r := &repository{}
-	r.On("FindUserByEmail", u.Email).Return(internal.ErrResourceNotFound)
 	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	err := s.Register(u)
+	err := s.Register(context.Background(), Origin{}, u)
 
 	// Then
 	require.NoError(t, err)
@@ -62,19 +129,26 @@ func TestRegister_UserAlreadyExists(t *testing.T) {
 	}
 
 	r := &repository{}
-	r.On("FindUserByEmail", u.Email).Return(nil)
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists))
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	err := s.Register(u)
+	err := s.Register(context.Background(), Origin{}, u)
 
 	// Then
 	require.EqualError(t, err, "resource already exists: user already exists")
 }
 
-func TestRegister_FindingUserByEmail_InternalServerError(t *testing.T) {
+func TestRegister_MySQLDuplicateEntry(t *testing.T) {
 	// Given
+	db, sqlMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
 	u := RegisterRequest{
 		Firstname: "Mateo",
 		Lastname:  "Ferrari Coronel",
@@ -82,16 +156,64 @@ func TestRegister_FindingUserByEmail_InternalServerError(t *testing.T) {
 		Password:  "luk1n",
 	}
 
-	r := &repository{}
-	r.On("FindUserByEmail", u.Email).Return(errors.New("repository error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`INSERT INTO user (_id, firstname, lastname) VALUES (?, ?, ?)`).
+		WithArgs(sqlmock.AnyArg(), u.Firstname, u.Lastname).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(`INSERT INTO login (email, password, user_id) VALUES (?, ?, ?)`).
+		WithArgs(u.Email, sqlmock.AnyArg(), 1).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mateo.ferrari97@gmail.com' for key 'email'"})
+	sqlMock.ExpectRollback()
 
-	s := NewService(r, nil)
+	s := NewService(NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")}), nil, testConfig)
 
 	// When
-	err := s.Register(u)
+	err = s.Register(context.Background(), Origin{}, u)
 
 	// Then
-	require.EqualError(t, err, "repository error")
+	require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists), "got %v", err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRegister_ConcurrentRegistrations(t *testing.T) {
+	// Given
+	const n = 10
+
+	u := RegisterRequest{
+		Firstname: "Mateo",
+		Lastname:  "Ferrari Coronel",
+		Email:     "mateo.ferrari97@gmail.com",
+		Password:  "luk1n",
+	}
+
+	s := NewService(NewSQLiteUserRepository(newSQLiteTestDB(t)), nil, testConfig)
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+
+	// When
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Register(context.Background(), Origin{}, u)
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Then
+	var registered int
+	for _, err := range errs {
+		if err == nil {
+			registered++
+			continue
+		}
+
+		require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists), "got %v", err)
+	}
+
+	require.Equal(t, 1, registered)
 }
 
 func TestRegister_SavingUser_InternalServerError(t *testing.T) {
@@ -104,13 +226,12 @@ func TestRegister_SavingUser_InternalServerError(t *testing.T) {
 	}
 
 	r := &repository{}
-	r.On("FindUserByEmail", u.Email).Return(internal.ErrResourceNotFound)
 	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(errors.New("repository error"))
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	err := s.Register(u)
+	err := s.Register(context.Background(), Origin{}, u)
 
 	// Then
 	require.EqualError(t, err, "repository error")
@@ -130,10 +251,10 @@ func TestAuthorize(t *testing.T) {
 	r := &repository{}
 	r.On("GetUserByEmail", u.Email).Return(u, nil)
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	resp, err := s.Authorize(token)
+	resp, err := s.Authorize(context.Background(), token)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,13 +266,59 @@ func TestAuthorize(t *testing.T) {
 	require.Equal(t, "mateo.ferrari97@gmail.com", resp.Email)
 }
 
+func TestUpdateMe(t *testing.T) {
+	// Given
+	updatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	u := User{ID: "id", Firstname: "luken", Lastname: "straka", Email: "mateo.ferrari97@gmail.com", UpdatedAt: &updatedAt}
+	token, _ := _newJWT(u)
+	firstname := "mateo"
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+	r.On("UpdateUser", mock.AnythingOfType("User"), updatedAt).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	resp, err := s.UpdateMe(context.Background(), token, UserETag(u), UpdateMeRequest{Firstname: &firstname})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "mateo", resp.Firstname)
+	require.Equal(t, "straka", resp.Lastname)
+	require.True(t, resp.UpdatedAt.After(updatedAt))
+	require.NotEqual(t, UserETag(u), UserETag(resp))
+}
+
+func TestUpdateMe_PreconditionFailedError(t *testing.T) {
+	// Given
+	updatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com", UpdatedAt: &updatedAt}
+	token, _ := _newJWT(u)
+	firstname := "mateo"
+
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	s := NewService(r, nil, testConfig)
+
+	// When
+	_, err := s.UpdateMe(context.Background(), token, `"1"`, UpdateMeRequest{Firstname: &firstname})
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrPreconditionFailed))
+	r.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
 func TestAuthorize_ParsingTokenError(t *testing.T) {
 	// Given
 	token := "invalid token"
-	s := NewService(&repository{}, nil)
+	s := NewService(&repository{}, nil, testConfig)
 
 	// When
-	_, err := s.Authorize(token)
+	_, err := s.Authorize(context.Background(), token)
 
 	// Then
 	require.EqualError(t, err, "parsing token: token contains an invalid number of segments")
@@ -171,10 +338,10 @@ func TestAuthorize_RepositoryInternalServerError(t *testing.T) {
 	r := &repository{}
 	r.On("GetUserByEmail", u.Email).Return(User{}, errors.New("internal server error"))
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	_, err := s.Authorize(token)
+	_, err := s.Authorize(context.Background(), token)
 
 	// Then
 	require.EqualError(t, err, "internal server error")
@@ -194,10 +361,10 @@ func TestAuthorize_RepositoryNotFoundError(t *testing.T) {
 	r := &repository{}
 	r.On("GetUserByEmail", u.Email).Return(User{}, internal.ErrResourceNotFound)
 
-	s := NewService(r, nil)
+	s := NewService(r, nil, testConfig)
 
 	// When
-	_, err := s.Authorize(token)
+	_, err := s.Authorize(context.Background(), token)
 
 	// Then
 	require.EqualError(t, err, "resource not found")
@@ -205,7 +372,7 @@ func TestAuthorize_RepositoryNotFoundError(t *testing.T) {
 
 func TestLoginWithGoogle(t *testing.T) {
 	// Given
-	s := NewService(&repository{}, nil)
+	s := NewService(&repository{}, nil, testConfig)
 	expectedURL := "https://accounts.google.com/o/oauth2/auth?client_id=176380119677-5r99e6b9jqho14cvfpc0inmeb1m48gkr.apps.googleusercontent.com&redirect_uri=http%3A%2F%2Flocalhost%3A8081%2Flogin%2Fgoogle%2Fcallback&response_type=code&scope=https%3A%2F%2Fwww.googleapis.com%2Fauth%2Fuserinfo.email&state=random"
 
 	// When
@@ -220,7 +387,7 @@ func TestLoginWithGoogle(t *testing.T) {
 
 func TestLoginWithGoogle_Error(t *testing.T) {
 	// Given
-	s := NewService(&repository{}, nil)
+	s := NewService(&repository{}, nil, testConfig)
 	expectedURL := "https://accounts.google.com/o/oauth2/auth?client_id=176380119677-5r99e6b9jqho14cvfpc0inmeb1m48gkr.apps.googleusercontent.com&redirect_uri=http%3A%2F%2Flocalhost%3A8081%2Flogin%2Fgoogle%2Fcallback&response_type=code&scope=https%3A%2F%2Fwww.googleapis.com%2Fauth%2Fuserinfo.email&state=random"
 
 	// When
@@ -246,10 +413,35 @@ func _newJWT(user User) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	t, err := token.SignedString([]byte(mySigningKey))
+	t, err := token.SignedString([]byte(testConfig.Auth.SigningKey))
 	if err != nil {
 		return "", fmt.Errorf("creating token: %v", err)
 	}
 
 	return t, nil
 }
+
+func TestLoginWithGoogleCallback_TimeoutError(t *testing.T) {
+	// Given
+	release := make(chan struct{})
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer google.Close()
+	defer close(release)
+
+	cfg := testConfig
+	cfg.Google.Timeout = 50 * time.Millisecond
+
+	s := NewService(&repository{}, nil, cfg)
+	s.HTTPClient = google.Client()
+	s.oauthConfig.Endpoint.TokenURL = google.URL
+
+	// When
+	_, err := s.LoginWithGoogleCallback(context.Background(), Origin{}, "code")
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "getting token from google")
+	require.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+}
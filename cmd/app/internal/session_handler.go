@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getMeSessions    = "/users/me/sessions"
+	deleteMeSession  = "/users/me/sessions/{id}"
+	deleteMeSessions = "/users/me/sessions"
+)
+
+type ListSessionsHandler func(ctx context.Context, token string) ([]Session, error)
+
+func (h *Handler) RouteListSessions(handler ListSessionsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.Wrap(http.MethodGet, getMeSessions, wrapH)
+}
+
+type RevokeSessionHandler func(ctx context.Context, token string, id string) error
+
+func (h *Handler) RouteRevokeSession(handler RevokeSessionHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		if err := handler(r.Context(), token, mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMeSession, wrapH)
+}
+
+type RevokeOtherSessionsHandler func(ctx context.Context, token string) error
+
+func (h *Handler) RouteRevokeOtherSessions(handler RevokeOtherSessionsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		token, err := authorizationToken(r)
+		if err != nil {
+			return err
+		}
+
+		if err := handler(r.Context(), token); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.Wrap(http.MethodDelete, deleteMeSessions, wrapH)
+}
@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type SessionSQLRepository struct {
+	db *DB
+}
+
+func NewSessionRepository(db *DB) SessionRepository {
+	return &SessionSQLRepository{
+		db: db,
+	}
+}
+
+type session struct {
+	ID             string       `db:"id"`
+	UserID         string       `db:"user_id"`
+	UserAgent      string       `db:"user_agent"`
+	IP             string       `db:"ip"`
+	ImpersonatorID string       `db:"impersonator_id"`
+	CreatedAt      time.Time    `db:"created_at"`
+	LastSeenAt     time.Time    `db:"last_seen_at"`
+	ExpiresAt      time.Time    `db:"expires_at"`
+	RevokedAt      sql.NullTime `db:"revoked_at"`
+}
+
+func (s session) toSession() Session {
+	return Session{
+		ID:             s.ID,
+		UserID:         s.UserID,
+		UserAgent:      s.UserAgent,
+		IP:             s.IP,
+		ImpersonatorID: s.ImpersonatorID,
+		CreatedAt:      s.CreatedAt,
+		LastSeenAt:     s.LastSeenAt,
+		ExpiresAt:      s.ExpiresAt,
+		RevokedAt:      timeFromNullTime(s.RevokedAt),
+	}
+}
+
+const insertSession = `INSERT INTO user_session (id, user_id, user_agent, ip, impersonator_id, created_at, last_seen_at, expires_at)
+					VALUES (:id, :user_id, :user_agent, :ip, :impersonator_id, :created_at, :last_seen_at, :expires_at)`
+
+func (r *SessionSQLRepository) SaveSession(ctx context.Context, s Session) (err error) {
+	ctx, end := r.db.start(ctx, "SaveSession")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertSession, map[string]interface{}{
+		"id":              s.ID,
+		"user_id":         s.UserID,
+		"user_agent":      s.UserAgent,
+		"ip":              s.IP,
+		"impersonator_id": s.ImpersonatorID,
+		"created_at":      s.CreatedAt,
+		"last_seen_at":    s.LastSeenAt,
+		"expires_at":      s.ExpiresAt,
+	})
+
+	return err
+}
+
+const getSession = `SELECT id, user_id, user_agent, ip, impersonator_id, created_at, last_seen_at, expires_at, revoked_at
+					FROM user_session
+					WHERE id = :id`
+
+func (r *SessionSQLRepository) GetSession(ctx context.Context, id string) (_ Session, err error) {
+	ctx, end := r.db.start(ctx, "GetSession")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getSession)
+	if err != nil {
+		return Session{}, err
+	}
+
+	defer stmt.Close()
+
+	var s session
+	err = stmt.GetContext(ctx, &s, map[string]interface{}{"id": id})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Session{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return s.toSession(), nil
+}
+
+const getActiveSessions = `SELECT id, user_id, user_agent, ip, impersonator_id, created_at, last_seen_at, expires_at, revoked_at
+						FROM user_session
+						WHERE user_id = :user_id AND revoked_at IS NULL AND expires_at > :now
+						ORDER BY last_seen_at DESC`
+
+func (r *SessionSQLRepository) GetActiveSessions(ctx context.Context, userID string, now time.Time) (_ []Session, err error) {
+	ctx, end := r.db.start(ctx, "GetActiveSessions")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var sessions []session
+	if err := stmt.SelectContext(ctx, &sessions, map[string]interface{}{"user_id": userID, "now": now}); err != nil {
+		return nil, err
+	}
+
+	resp := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, s.toSession())
+	}
+
+	return resp, nil
+}
+
+const touchSession = `UPDATE user_session SET last_seen_at = :last_seen_at WHERE id = :id`
+
+func (r *SessionSQLRepository) TouchSession(ctx context.Context, id string, lastSeenAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "TouchSession")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, touchSession, map[string]interface{}{"id": id, "last_seen_at": lastSeenAt})
+	return err
+}
+
+const extendSession = `UPDATE user_session SET expires_at = :expires_at WHERE id = :id`
+
+func (r *SessionSQLRepository) ExtendSession(ctx context.Context, id string, expiresAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "ExtendSession")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, extendSession, map[string]interface{}{"id": id, "expires_at": expiresAt})
+	return err
+}
+
+const revokeSession = `UPDATE user_session
+					SET revoked_at = :revoked_at
+					WHERE user_id = :user_id AND id = :id AND revoked_at IS NULL`
+
+func (r *SessionSQLRepository) RevokeSession(ctx context.Context, userID string, id string, revokedAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "RevokeSession")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, revokeSession, map[string]interface{}{"user_id": userID, "id": id, "revoked_at": revokedAt})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+const revokeOtherSessions = `UPDATE user_session
+						SET revoked_at = :revoked_at
+						WHERE user_id = :user_id AND id <> :except_id AND revoked_at IS NULL`
+
+func (r *SessionSQLRepository) RevokeOtherSessions(ctx context.Context, userID string, exceptID string, revokedAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "RevokeOtherSessions")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, revokeOtherSessions, map[string]interface{}{"user_id": userID, "except_id": exceptID, "revoked_at": revokedAt})
+	return err
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetActiveSessions(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+	q := `SELECT id, user_id, user_agent, ip, impersonator_id, created_at, last_seen_at, expires_at, revoked_at
+			FROM user_session
+			WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+			ORDER BY last_seen_at DESC`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("id", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "user_agent", "ip"}).AddRow("session", "id", "curl/7.64.1", "127.0.0.1"))
+
+	// When
+	resp, err := r.GetActiveSessions(context.Background(), "id", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []Session{{ID: "session", UserID: "id", UserAgent: "curl/7.64.1", IP: "127.0.0.1"}}, resp)
+}
+
+func TestRevokeSession_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE user_session SET revoked_at = ? WHERE user_id = ? AND id = ? AND revoked_at IS NULL`).
+		WithArgs(now, "id", "session").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.RevokeSession(context.Background(), "id", "session", now)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+}
+
+func TestRevokeOtherSessions_Query(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSessionRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE user_session SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL`).
+		WithArgs(now, "id", "session").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// When
+	err = r.RevokeOtherSessions(context.Background(), "id", "session", now)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const sessionTouchInterval = time.Minute
+
+type SessionRepository interface {
+	SaveSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetActiveSessions(ctx context.Context, userID string, now time.Time) ([]Session, error)
+	TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error
+	ExtendSession(ctx context.Context, id string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, userID string, id string, revokedAt time.Time) error
+	RevokeOtherSessions(ctx context.Context, userID string, exceptID string, revokedAt time.Time) error
+}
+
+type Session struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"-"`
+	Device         string     `json:"device"`
+	Browser        string     `json:"browser"`
+	OS             string     `json:"os"`
+	UserAgent      string     `json:"user_agent"`
+	IP             string     `json:"ip"`
+	ImpersonatorID string     `json:"impersonator_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	Current        bool       `json:"current"`
+}
+
+func (s *Service) ListSessions(ctx context.Context, token string) ([]Session, error) {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.activeSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == user.sessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *Service) RevokeSession(ctx context.Context, token string, id string) error {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return err
+	}
+
+	return s.SessionRepository.RevokeSession(ctx, user.ID, id, time.Now())
+}
+
+func (s *Service) RevokeOtherSessions(ctx context.Context, token string) error {
+	user, err := s.Authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureNotImpersonated(user); err != nil {
+		return err
+	}
+
+	return s.SessionRepository.RevokeOtherSessions(ctx, user.ID, user.sessionID, time.Now())
+}
+
+func (s *Service) activeSessions(ctx context.Context, userID string) ([]Session, error) {
+	sessions, err := s.SessionRepository.GetActiveSessions(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Device, sessions[i].Browser, sessions[i].OS = describeUserAgent(sessions[i].UserAgent)
+	}
+
+	return sessions, nil
+}
+
+func (s *Service) startSession(ctx context.Context, origin Origin, user User, expiration time.Duration) (string, error) {
+	if s.SessionRepository == nil {
+		return "", nil
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("creating session: %v", err)
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:         id.String(),
+		UserID:     user.ID,
+		UserAgent:  origin.UserAgent,
+		IP:         origin.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(expiration),
+	}
+
+	if len(session.UserAgent) > auditUserAgentMaxLength {
+		session.UserAgent = session.UserAgent[:auditUserAgentMaxLength]
+	}
+
+	if user.Actor != nil {
+		session.ImpersonatorID = user.Actor.Subject
+	}
+
+	if err := s.SessionRepository.SaveSession(ctx, session); err != nil {
+		return "", err
+	}
+
+	return session.ID, nil
+}
+
+func (s *Service) checkSession(ctx context.Context, userID string, id string) error {
+	if s.SessionRepository == nil {
+		return nil
+	}
+
+	session, err := s.SessionRepository.GetSession(ctx, id)
+	if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	if err != nil || session.UserID != userID || session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return fmt.Errorf("%w: session has been revoked", internal.ErrRevokedSession)
+	}
+
+	if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	return s.SessionRepository.TouchSession(ctx, id, now)
+}
+
+func (s *Service) revokeCurrentSession(ctx context.Context, user User) error {
+	if s.SessionRepository == nil || user.sessionID == "" {
+		return nil
+	}
+
+	err := s.SessionRepository.RevokeSession(ctx, user.ID, user.sessionID, time.Now())
+	if err != nil && !errors.Is(err, internal.ErrResourceNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func describeUserAgent(userAgent string) (device string, browser string, os string) {
+	has := func(values ...string) bool {
+		for _, v := range values {
+			if strings.Contains(userAgent, v) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch {
+	case has("Edg/"):
+		browser = "Edge"
+	case has("OPR/", "Opera"):
+		browser = "Opera"
+	case has("Firefox/", "FxiOS/"):
+		browser = "Firefox"
+	case has("Chrome/", "CriOS/"):
+		browser = "Chrome"
+	case has("Safari/"):
+		browser = "Safari"
+	case has("curl/"):
+		browser = "curl"
+	default:
+		browser = "Unknown"
+	}
+
+	switch {
+	case has("Windows"):
+		os = "Windows"
+	case has("iPhone", "iPad"):
+		os = "iOS"
+	case has("Mac OS X", "Macintosh"):
+		os = "macOS"
+	case has("Android"):
+		os = "Android"
+	case has("Linux"):
+		os = "Linux"
+	default:
+		os = "Unknown"
+	}
+
+	switch {
+	case has("iPad", "Tablet") || (os == "Android" && !has("Mobi")):
+		device = "tablet"
+	case has("Mobi", "iPhone"):
+		device = "mobile"
+	case os == "Windows" || os == "macOS" || os == "Linux":
+		device = "desktop"
+	default:
+		device = "unknown"
+	}
+
+	return device, browser, os
+}
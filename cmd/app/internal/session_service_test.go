@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionRepository struct {
+	mock.Mock
+}
+
+func (r *sessionRepository) SaveSession(ctx context.Context, session Session) error {
+	return r.Called(session).Error(0)
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id string) (Session, error) {
+	args := r.Called(id)
+	return args.Get(0).(Session), args.Error(1)
+}
+
+func (r *sessionRepository) GetActiveSessions(ctx context.Context, userID string, now time.Time) ([]Session, error) {
+	args := r.Called(userID, now)
+	return args.Get(0).([]Session), args.Error(1)
+}
+
+func (r *sessionRepository) TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error {
+	return r.Called(id, lastSeenAt).Error(0)
+}
+
+func (r *sessionRepository) ExtendSession(ctx context.Context, id string, expiresAt time.Time) error {
+	return r.Called(id, expiresAt).Error(0)
+}
+
+func (r *sessionRepository) RevokeSession(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	return r.Called(userID, id, revokedAt).Error(0)
+}
+
+func (r *sessionRepository) RevokeOtherSessions(ctx context.Context, userID string, exceptID string, revokedAt time.Time) error {
+	return r.Called(userID, exceptID, revokedAt).Error(0)
+}
+
+func newSessionService(u User, session Session) (*Service, *sessionRepository, string) {
+	r := &repository{}
+	r.On("GetUserByEmail", u.Email).Return(u, nil)
+
+	sr := &sessionRepository{}
+	sr.On("GetSession", session.ID).Return(session, nil)
+
+	s := NewService(r, nil, testConfig)
+	s.SessionRepository = sr
+
+	u.sessionID = session.ID
+	token, _ := s.newJWT(u, tokenExpiration)
+
+	return s, sr, token
+}
+
+func TestAuthorize_Session(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+
+	// When
+	resp, err := s.Authorize(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "session", resp.sessionID)
+	sr.AssertNotCalled(t, "TouchSession", mock.Anything, mock.Anything)
+}
+
+func TestAuthorize_SessionTouch(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+	sr.On("TouchSession", "session", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// When
+	_, err := s.Authorize(context.Background(), token)
+
+	// Then
+	require.NoError(t, err)
+	sr.AssertCalled(t, "TouchSession", "session", mock.AnythingOfType("time.Time"))
+}
+
+func TestAuthorize_SessionError(t *testing.T) {
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name    string
+		session Session
+	}{
+		{
+			name:    "revoked",
+			session: Session{ID: "session", UserID: "id", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt},
+		},
+		{
+			name:    "expired",
+			session: Session{ID: "session", UserID: "id", ExpiresAt: time.Now().Add(-time.Hour)},
+		},
+		{
+			name:    "another user",
+			session: Session{ID: "session", UserID: "another", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+			s, _, token := newSessionService(u, tt.session)
+
+			// When
+			_, err := s.Authorize(context.Background(), token)
+
+			// Then
+			require.True(t, errors.Is(err, internal.ErrRevokedSession))
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+	sr.On("GetActiveSessions", u.ID, mock.AnythingOfType("time.Time")).Return([]Session{
+		{ID: "session", UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.111 Safari/537.36"},
+		{ID: "phone", UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 14_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1"},
+	}, nil)
+
+	// When
+	resp, err := s.ListSessions(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.True(t, resp[0].Current)
+	require.Equal(t, []string{"desktop", "Chrome", "macOS"}, []string{resp[0].Device, resp[0].Browser, resp[0].OS})
+	require.False(t, resp[1].Current)
+	require.Equal(t, []string{"mobile", "Safari", "iOS"}, []string{resp[1].Device, resp[1].Browser, resp[1].OS})
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+	sr.On("RevokeOtherSessions", u.ID, "session", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// When
+	err := s.RevokeOtherSessions(context.Background(), token)
+
+	// Then
+	require.NoError(t, err)
+	sr.AssertExpectations(t)
+}
+
+func TestRevokeSession_NotFoundError(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+	sr.On("RevokeSession", u.ID, "unknown", mock.AnythingOfType("time.Time")).Return(internal.ErrResourceNotFound)
+
+	// When
+	err := s.RevokeSession(context.Background(), token, "unknown")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+}
+
+func TestLogout_RevokeSession(t *testing.T) {
+	// Given
+	u := User{ID: "id", Email: "mateo.ferrari97@gmail.com"}
+	session := Session{ID: "session", UserID: u.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	s, sr, token := newSessionService(u, session)
+	sr.On("RevokeSession", u.ID, "session", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// When
+	err := s.Logout(context.Background(), Origin{}, token)
+
+	// Then
+	require.NoError(t, err)
+	sr.AssertExpectations(t)
+}
+
+func TestDescribeUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		expected  []string
+	}{
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.111 Safari/537.36 Edg/86.0.622.51",
+			expected:  []string{"desktop", "Edge", "Windows"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:82.0) Gecko/20100101 Firefox/82.0",
+			expected:  []string{"desktop", "Firefox", "Linux"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 10; SM-G975F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.110 Mobile Safari/537.36",
+			expected:  []string{"mobile", "Chrome", "Android"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 14_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1",
+			expected:  []string{"tablet", "Safari", "iOS"},
+		},
+		{
+			userAgent: "curl/7.64.1",
+			expected:  []string{"unknown", "curl", "Unknown"},
+		},
+		{
+			userAgent: "",
+			expected:  []string{"unknown", "Unknown", "Unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		// When
+		device, browser, os := describeUserAgent(tt.userAgent)
+
+		// Then
+		require.Equal(t, tt.expected, []string{device, browser, os}, tt.userAgent)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	postAdminInvitations  = "/admin/invitations"
+	getAdminInvitations   = "/admin/invitations"
+	deleteAdminInvitation = "/admin/invitations/{id}"
+)
+
+type CreateSignupInvitationRequest struct {
+	Email string   `json:"email" validate:"required,email"`
+	Roles []string `json:"roles" validate:"dive,required"`
+}
+
+type CreateSignupInvitationHandler func(ctx context.Context, req CreateSignupInvitationRequest) (NewSignupInvitation, error)
+
+func (h *Handler) RouteCreateSignupInvitation(handler CreateSignupInvitationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req CreateSignupInvitationRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminInvitations, []string{PermissionUsersWrite}, wrapH)
+}
+
+type ListSignupInvitationsHandler func(ctx context.Context) ([]SignupInvitation, error)
+
+func (h *Handler) RouteListSignupInvitations(handler ListSignupInvitationsHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminInvitations, []string{PermissionUsersRead}, wrapH)
+}
+
+type RevokeSignupInvitationHandler func(ctx context.Context, id string) error
+
+func (h *Handler) RouteRevokeSignupInvitation(handler RevokeSignupInvitationHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		if err := handler(r.Context(), mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(http.MethodDelete, deleteAdminInvitation, []string{PermissionUsersWrite}, wrapH)
+}
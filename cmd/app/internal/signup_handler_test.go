@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteCreateSignupInvitation(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersWrite)
+	h := NewHandler(w)
+
+	h.RouteCreateSignupInvitation(func(_ context.Context, req CreateSignupInvitationRequest) (NewSignupInvitation, error) {
+		require.Equal(t, "mateo.ferrari97@gmail.com", req.Email)
+		require.Equal(t, []string{"admin"}, req.Roles)
+
+		return NewSignupInvitation{Token: "token"}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	b := []byte(`{"email": "mateo.ferrari97@gmail.com", "roles": ["admin"]}`)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/invitations", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestHandler_RouteCreateSignupInvitation_ForbiddenError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionUsersRead)
+	h := NewHandler(w)
+
+	h.RouteCreateSignupInvitation(func(_ context.Context, req CreateSignupInvitationRequest) (NewSignupInvitation, error) {
+		return NewSignupInvitation{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	b := []byte(`{"email": "mateo.ferrari97@gmail.com"}`)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/invitations", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
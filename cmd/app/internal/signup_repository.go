@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type SignupInvitationSQLRepository struct {
+	db *DB
+}
+
+func NewSignupInvitationRepository(db *DB) SignupInvitationRepository {
+	return &SignupInvitationSQLRepository{
+		db: db,
+	}
+}
+
+type signupInvitation struct {
+	ID        string         `db:"id"`
+	Email     string         `db:"email"`
+	Roles     string         `db:"roles"`
+	Hash      string         `db:"token_hash"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	UsedAt    sql.NullTime   `db:"used_at"`
+	UsedBy    sql.NullString `db:"used_by"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+func (i signupInvitation) toSignupInvitation() SignupInvitation {
+	return SignupInvitation{
+		ID:        i.ID,
+		Email:     i.Email,
+		Roles:     strings.Fields(i.Roles),
+		Hash:      i.Hash,
+		ExpiresAt: i.ExpiresAt,
+		UsedAt:    timeFromNullTime(i.UsedAt),
+		UsedBy:    i.UsedBy.String,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+const insertSignupInvitation = `INSERT INTO signup_invitation (id, email, roles, token_hash, expires_at, created_at)
+							VALUES (:id, :email, :roles, :token_hash, :expires_at, :created_at)`
+
+func (r *SignupInvitationSQLRepository) SaveSignupInvitation(ctx context.Context, i SignupInvitation) (err error) {
+	ctx, end := r.db.start(ctx, "SaveSignupInvitation")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertSignupInvitation, map[string]interface{}{
+		"id":         i.ID,
+		"email":      i.Email,
+		"roles":      strings.Join(i.Roles, " "),
+		"token_hash": i.Hash,
+		"expires_at": i.ExpiresAt,
+		"created_at": i.CreatedAt,
+	})
+
+	return err
+}
+
+const getSignupInvitations = `SELECT id, email, roles, token_hash, expires_at, used_at, used_by, created_at
+							FROM signup_invitation
+							ORDER BY created_at DESC`
+
+func (r *SignupInvitationSQLRepository) GetSignupInvitations(ctx context.Context) (_ []SignupInvitation, err error) {
+	ctx, end := r.db.start(ctx, "GetSignupInvitations")
+	defer end(&err)
+
+	var invitations []signupInvitation
+	if err := r.db.SelectContext(ctx, &invitations, getSignupInvitations); err != nil {
+		return nil, err
+	}
+
+	resp := make([]SignupInvitation, 0, len(invitations))
+	for _, i := range invitations {
+		resp = append(resp, i.toSignupInvitation())
+	}
+
+	return resp, nil
+}
+
+const getSignupInvitationByHash = `SELECT id, email, roles, token_hash, expires_at, used_at, used_by, created_at
+							FROM signup_invitation
+							WHERE token_hash = :token_hash`
+
+func (r *SignupInvitationSQLRepository) GetSignupInvitationByHash(ctx context.Context, hash string) (_ SignupInvitation, err error) {
+	ctx, end := r.db.start(ctx, "GetSignupInvitationByHash")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getSignupInvitationByHash)
+	if err != nil {
+		return SignupInvitation{}, err
+	}
+
+	defer stmt.Close()
+
+	var i signupInvitation
+	err = stmt.GetContext(ctx, &i, map[string]interface{}{"token_hash": hash})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return SignupInvitation{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return SignupInvitation{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return i.toSignupInvitation(), nil
+}
+
+const consumeSignupInvitation = `UPDATE signup_invitation
+							SET used_at = :used_at, used_by = :used_by
+							WHERE id = :id AND used_at IS NULL`
+
+func (r *SignupInvitationSQLRepository) ConsumeSignupInvitation(ctx context.Context, i SignupInvitation) (err error) {
+	ctx, end := r.db.start(ctx, "ConsumeSignupInvitation")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, consumeSignupInvitation, map[string]interface{}{
+		"id":      i.ID,
+		"used_at": nullTimeFromTime(i.UsedAt),
+		"used_by": i.UsedBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: invitation has already been used", internal.ErrBadRequest)
+	}
+
+	return nil
+}
+
+const deleteSignupInvitation = `DELETE FROM signup_invitation WHERE id = :id`
+
+func (r *SignupInvitationSQLRepository) DeleteSignupInvitation(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteSignupInvitation")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, deleteSignupInvitation, map[string]interface{}{"id": id})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeSignupInvitation(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSignupInvitationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE signup_invitation SET used_at = ?, used_by = ? WHERE id = ? AND used_at IS NULL`).
+		WithArgs(now, "user", "invitation").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When
+	err = r.ConsumeSignupInvitation(context.Background(), SignupInvitation{ID: "invitation", UsedAt: &now, UsedBy: "user"})
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConsumeSignupInvitation_AlreadyUsedError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSignupInvitationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE signup_invitation SET used_at = ?, used_by = ? WHERE id = ? AND used_at IS NULL`).
+		WithArgs(now, "user", "invitation").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.ConsumeSignupInvitation(context.Background(), SignupInvitation{ID: "invitation", UsedAt: &now, UsedBy: "user"})
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrBadRequest))
+}
+
+func TestGetSignupInvitationByHash(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewSignupInvitationRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	q := `SELECT id, email, roles, token_hash, expires_at, used_at, used_by, created_at FROM signup_invitation WHERE token_hash = ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "roles", "token_hash"}).AddRow("invitation", "mateo.ferrari97@gmail.com", "admin auditor", "hash"))
+
+	// When
+	resp, err := r.GetSignupInvitationByHash(context.Background(), "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []string{"admin", "auditor"}, resp.Roles)
+	require.Nil(t, resp.UsedAt)
+}
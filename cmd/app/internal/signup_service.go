@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	SignupModeOpen       = "open"
+	SignupModeInviteOnly = "invite_only"
+	SignupModeDisabled   = "disabled"
+)
+
+const signupInvitationExpiration = 7 * 24 * time.Hour
+
+type SignupInvitationRepository interface {
+	SaveSignupInvitation(ctx context.Context, invitation SignupInvitation) error
+	GetSignupInvitations(ctx context.Context) ([]SignupInvitation, error)
+	GetSignupInvitationByHash(ctx context.Context, hash string) (SignupInvitation, error)
+	ConsumeSignupInvitation(ctx context.Context, invitation SignupInvitation) error
+	DeleteSignupInvitation(ctx context.Context, id string) error
+}
+
+type SignupInvitation struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	Roles     []string   `json:"roles"`
+	Hash      string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	UsedBy    string     `json:"used_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type NewSignupInvitation struct {
+	SignupInvitation
+	Token string `json:"token"`
+}
+
+func (s *Service) CreateSignupInvitation(ctx context.Context, req CreateSignupInvitationRequest) (NewSignupInvitation, error) {
+	for _, role := range req.Roles {
+		if _, err := s.RoleRepository.GetRole(ctx, role); err != nil {
+			return NewSignupInvitation{}, err
+		}
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return NewSignupInvitation{}, fmt.Errorf("creating invitation: %v", err)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return NewSignupInvitation{}, fmt.Errorf("generating invitation token: %v", err)
+	}
+
+	now := time.Now()
+	invitation := SignupInvitation{
+		ID:        id.String(),
+		Email:     strings.ToLower(req.Email),
+		Roles:     req.Roles,
+		Hash:      hashToken(secret),
+		ExpiresAt: now.Add(signupInvitationExpiration),
+		CreatedAt: now,
+	}
+
+	if err := s.SignupInvitationRepository.SaveSignupInvitation(ctx, invitation); err != nil {
+		return NewSignupInvitation{}, err
+	}
+
+	return NewSignupInvitation{SignupInvitation: invitation, Token: secret}, nil
+}
+
+func (s *Service) ListSignupInvitations(ctx context.Context) ([]SignupInvitation, error) {
+	return s.SignupInvitationRepository.GetSignupInvitations(ctx)
+}
+
+func (s *Service) RevokeSignupInvitation(ctx context.Context, id string) error {
+	return s.SignupInvitationRepository.DeleteSignupInvitation(ctx, id)
+}
+
+func (s *Service) signupInvitation(ctx context.Context, newUser RegisterRequest) (*SignupInvitation, error) {
+	switch s.SignupMode {
+	case SignupModeDisabled:
+		return nil, fmt.Errorf("%w: signup is disabled", internal.ErrForbidden)
+	case SignupModeInviteOnly:
+		if newUser.InviteToken == "" {
+			return nil, fmt.Errorf("%w: an invitation is required to sign up", internal.ErrForbidden)
+		}
+	}
+
+	if newUser.InviteToken == "" {
+		return nil, nil
+	}
+
+	invitation, err := s.SignupInvitationRepository.GetSignupInvitationByHash(ctx, hashToken(newUser.InviteToken))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case invitation.UsedAt != nil:
+		return nil, fmt.Errorf("%w: invitation has already been used", internal.ErrBadRequest)
+	case time.Now().After(invitation.ExpiresAt):
+		return nil, fmt.Errorf("%w: invitation has expired", internal.ErrBadRequest)
+	case !strings.EqualFold(invitation.Email, newUser.Email):
+		return nil, fmt.Errorf("%w: invitation was sent to another email", internal.ErrForbidden)
+	}
+
+	return &invitation, nil
+}
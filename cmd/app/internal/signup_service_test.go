@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type signupInvitationRepository struct {
+	mock.Mock
+}
+
+func (r *signupInvitationRepository) SaveSignupInvitation(ctx context.Context, invitation SignupInvitation) error {
+	return r.Called(invitation).Error(0)
+}
+
+func (r *signupInvitationRepository) GetSignupInvitations(ctx context.Context) ([]SignupInvitation, error) {
+	args := r.Called()
+	return args.Get(0).([]SignupInvitation), args.Error(1)
+}
+
+func (r *signupInvitationRepository) GetSignupInvitationByHash(ctx context.Context, hash string) (SignupInvitation, error) {
+	args := r.Called(hash)
+	return args.Get(0).(SignupInvitation), args.Error(1)
+}
+
+func (r *signupInvitationRepository) ConsumeSignupInvitation(ctx context.Context, invitation SignupInvitation) error {
+	return r.Called(invitation).Error(0)
+}
+
+func (r *signupInvitationRepository) DeleteSignupInvitation(ctx context.Context, id string) error {
+	return r.Called(id).Error(0)
+}
+
+func newSignupRequest(inviteToken string) RegisterRequest {
+	return RegisterRequest{
+		Firstname:   "mateo",
+		Lastname:    "ferrari",
+		Email:       "mateo.ferrari97@gmail.com",
+		Password:    "Password1!",
+		InviteToken: inviteToken,
+	}
+}
+
+func TestCreateSignupInvitation(t *testing.T) {
+	// Given
+	rr := &roleRepository{}
+	rr.On("GetRole", "admin").Return(Role{Name: "admin"}, nil)
+
+	sr := &signupInvitationRepository{}
+	sr.On("SaveSignupInvitation", mock.AnythingOfType("SignupInvitation")).Return(nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.RoleRepository = rr
+	s.SignupInvitationRepository = sr
+
+	// When
+	resp, err := s.CreateSignupInvitation(context.Background(), CreateSignupInvitationRequest{Email: "Mateo.Ferrari97@gmail.com", Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	saved := sr.Calls[0].Arguments.Get(0).(SignupInvitation)
+	require.NotEmpty(t, resp.Token)
+	require.Equal(t, hashToken(resp.Token), saved.Hash)
+	require.Equal(t, "mateo.ferrari97@gmail.com", saved.Email)
+	require.Equal(t, []string{"admin"}, saved.Roles)
+}
+
+func TestCreateSignupInvitation_UnknownRoleError(t *testing.T) {
+	// Given
+	rr := &roleRepository{}
+	rr.On("GetRole", "owner").Return(Role{}, internal.ErrResourceNotFound)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.RoleRepository = rr
+
+	// When
+	_, err := s.CreateSignupInvitation(context.Background(), CreateSignupInvitationRequest{Email: "mateo.ferrari97@gmail.com", Roles: []string{"owner"}})
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+}
+
+func TestRegister_InviteOnly(t *testing.T) {
+	// Given
+	u := newSignupRequest("invite")
+	invitation := SignupInvitation{ID: "invitation", Email: u.Email, Roles: []string{"admin"}, ExpiresAt: time.Now().Add(time.Hour)}
+
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
+
+	rr := &roleRepository{}
+	rr.On("AssignRole", mock.AnythingOfType("string"), "admin").Return(nil)
+
+	sr := &signupInvitationRepository{}
+	sr.On("GetSignupInvitationByHash", hashToken("invite")).Return(invitation, nil)
+	sr.On("ConsumeSignupInvitation", mock.AnythingOfType("SignupInvitation")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.SignupMode = SignupModeInviteOnly
+	s.RoleRepository = rr
+	s.SignupInvitationRepository = sr
+
+	// When
+	err := s.Register(context.Background(), Origin{}, u)
+
+	// Then
+	consumed := sr.Calls[1].Arguments.Get(0).(SignupInvitation)
+	saved := r.Calls[0].Arguments.Get(0).(NewUser)
+	require.NoError(t, err)
+	require.NotNil(t, consumed.UsedAt)
+	require.Equal(t, saved.ID, consumed.UsedBy)
+	rr.AssertCalled(t, "AssignRole", saved.ID, "admin")
+}
+
+func TestRegister_DuplicateEmailKeepsInvitation(t *testing.T) {
+	// Given
+	db := newSQLiteTestDB(t)
+	u := newSignupRequest("")
+
+	s := NewService(NewSQLiteUserRepository(db), nil, testConfig)
+	s.SignupInvitationRepository = NewSignupInvitationRepository(db)
+
+	if err := s.Register(context.Background(), Origin{}, u); err != nil {
+		t.Fatal(err)
+	}
+
+	invitation, err := s.CreateSignupInvitation(context.Background(), CreateSignupInvitationRequest{Email: u.Email})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.InviteToken = invitation.Token
+
+	// When
+	err = s.Register(context.Background(), Origin{}, u)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceAlreadyExists), "got %v", err)
+
+	stored, err := s.SignupInvitationRepository.GetSignupInvitationByHash(context.Background(), hashToken(invitation.Token))
+	require.NoError(t, err)
+	require.Nil(t, stored.UsedAt)
+}
+
+func TestRegister_SignupModeError(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	valid := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		mode        string
+		inviteToken string
+		invitation  SignupInvitation
+		expectedErr string
+	}{
+		{
+			name:        "disabled",
+			mode:        SignupModeDisabled,
+			inviteToken: "invite",
+			expectedErr: "can't access to the resource. insufficient permissions: signup is disabled",
+		},
+		{
+			name:        "missing invitation",
+			mode:        SignupModeInviteOnly,
+			expectedErr: "can't access to the resource. insufficient permissions: an invitation is required to sign up",
+		},
+		{
+			name:        "used invitation",
+			mode:        SignupModeInviteOnly,
+			inviteToken: "invite",
+			invitation:  SignupInvitation{Email: "mateo.ferrari97@gmail.com", ExpiresAt: valid, UsedAt: &expired},
+			expectedErr: "bad request: invitation has already been used",
+		},
+		{
+			name:        "expired invitation",
+			mode:        SignupModeInviteOnly,
+			inviteToken: "invite",
+			invitation:  SignupInvitation{Email: "mateo.ferrari97@gmail.com", ExpiresAt: expired},
+			expectedErr: "bad request: invitation has expired",
+		},
+		{
+			name:        "another email",
+			mode:        SignupModeOpen,
+			inviteToken: "invite",
+			invitation:  SignupInvitation{Email: "someone@gmail.com", ExpiresAt: valid},
+			expectedErr: "can't access to the resource. insufficient permissions: invitation was sent to another email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			sr := &signupInvitationRepository{}
+			sr.On("GetSignupInvitationByHash", hashToken(tt.inviteToken)).Return(tt.invitation, nil)
+
+			s := NewService(&repository{}, nil, testConfig)
+			s.SignupMode = tt.mode
+			s.SignupInvitationRepository = sr
+
+			// When
+			err := s.Register(context.Background(), Origin{}, newSignupRequest(tt.inviteToken))
+
+			// Then
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
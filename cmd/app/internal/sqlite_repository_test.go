@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/cmd/app/migrations"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return &DB{DB: db}
+}
+
+func TestSQLiteUserRepository(t *testing.T) {
+	// Given
+	r := NewSQLiteUserRepository(newSQLiteTestDB(t))
+	ctx := context.Background()
+	if err := r.SaveUser(ctx, NewUser{ID: "1", Firstname: "mateo_f", Lastname: "ferrari", Email: "mateo@gmail.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SaveUser(ctx, NewUser{ID: "2", Firstname: "mateoxf", Lastname: "ferrari", Email: "other@gmail.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAfter := time.Now().Add(-time.Minute)
+
+	// When
+	user, err := r.GetUserByEmail(ctx, "mateo@gmail.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, total, err := r.GetUsers(ctx, UserQuery{Search: "MATEO_", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.ScheduleUserDeletion(ctx, "1", &deleteAfter); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := r.GetUsersScheduledForDeletion(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, "mateo_f", user.Firstname)
+	require.NotNil(t, user.CreatedAt)
+	require.Equal(t, 1, total)
+	require.Equal(t, "1", users[0].ID)
+	require.Equal(t, []string{"1"}, scheduled)
+	require.NoError(t, r.DeleteUser(ctx, "1"))
+	require.True(t, errors.Is(r.DeleteUser(ctx, "1"), internal.ErrResourceNotFound))
+}
+
+func TestSQLiteAuditRepository_AppendOnly(t *testing.T) {
+	// Given
+	db := newSQLiteTestDB(t)
+	r := NewAuditRepository(db)
+	event := AuditEvent{ID: "1", Actor: "mateo@gmail.com", Action: AuditActionRegister, CreatedAt: time.Now()}
+	if err := r.AppendAuditEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	_, err := db.Exec(`DELETE FROM audit_event`)
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "audit_event is append-only")
+}
+
+func TestSQLiteDeviceCodeRepository_DecidesPendingCodesOnly(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	r := NewDeviceCodeRepository(newSQLiteTestDB(t))
+	d := DeviceCode{DeviceCode: "device", UserCode: "BCDFGHJK", ClientID: "cli", Status: DeviceCodeStatusPending, Interval: 5 * time.Second, ExpiresAt: time.Now().Add(time.Minute)}
+	require.NoError(t, r.SaveDeviceCode(ctx, d))
+	require.NoError(t, r.PollDeviceCode(ctx, "device", 10*time.Second, time.Now()))
+
+	// When
+	require.NoError(t, r.ApproveDeviceCode(ctx, "device", "id"))
+	denyErr := r.DenyDeviceCode(ctx, "device")
+	pollErr := r.PollDeviceCode(ctx, "device", 15*time.Second, time.Now())
+
+	// Then
+	require.True(t, errors.Is(denyErr, internal.ErrResourceNotFound), "got %v", denyErr)
+	require.True(t, errors.Is(pollErr, internal.ErrResourceNotFound), "got %v", pollErr)
+
+	resp, err := r.GetDeviceCode(ctx, "device")
+	require.NoError(t, err)
+	require.Equal(t, DeviceCodeStatusApproved, resp.Status)
+	require.Equal(t, "id", resp.UserID)
+	require.Equal(t, 10*time.Second, resp.Interval)
+}
+
+func TestSQLiteWebhookRepository_ClaimSkipsAttemptedDeliveries(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	r := NewWebhookRepository(newSQLiteTestDB(t))
+	now := time.Now()
+	require.NoError(t, r.SaveWebhook(ctx, Webhook{ID: "webhook", URL: "http://crm", Secret: "secret", Events: []string{WebhookEventUserRegistered}, CreatedAt: now}))
+
+	d := WebhookDelivery{ID: "delivery", WebhookID: "webhook", Event: WebhookEventUserRegistered, Payload: []byte(`{}`), Status: WebhookDeliveryPending, NextAttemptAt: now, CreatedAt: now}
+	require.NoError(t, r.SaveWebhookDeliveries(ctx, []WebhookDelivery{d}))
+
+	// When
+	require.NoError(t, r.ClaimWebhookDelivery(ctx, d.ID, now, now.Add(time.Minute)))
+
+	d.Attempts = 1
+	d.NextAttemptAt = now.Add(time.Minute)
+	d.LastError = "unexpected status code 500"
+	require.NoError(t, r.UpdateWebhookDelivery(ctx, d))
+
+	err := r.ClaimWebhookDelivery(ctx, d.ID, now, now.Add(time.Minute))
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound), "got %v", err)
+	require.NoError(t, r.ClaimWebhookDelivery(ctx, d.ID, now.Add(time.Minute), now.Add(2*time.Minute)))
+}
+
+func TestMemoryUserRepository_DeleteUserRemovesUserData(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	db := newSQLiteTestDB(t)
+	r := NewMemoryUserRepository(db)
+	now := time.Now()
+	require.NoError(t, r.SaveUser(ctx, NewUser{ID: "id", Firstname: "mateo", Lastname: "ferrari", Email: "mateo@gmail.com", Password: "hash"}))
+	require.NoError(t, NewPersonalAccessTokenRepository(db).SavePersonalAccessToken(ctx, PersonalAccessToken{ID: "pat", UserID: "id", Name: "ci", Prefix: "prefix", Hash: "hash", Scopes: []string{"users:read"}, CreatedAt: now}))
+	require.NoError(t, NewSessionRepository(db).SaveSession(ctx, Session{ID: "session", UserID: "id", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, NewRoleRepository(db).AssignRole(ctx, "id", "admin"))
+	require.NoError(t, NewOrganizationRepository(db).SaveOrganization(ctx, Organization{ID: "org", Name: "acme", CreatedAt: now}, Member{OrganizationID: "org", UserID: "id", Role: OrganizationRoleOwner, JoinedAt: now}))
+
+	// When
+	err := r.DeleteUser(ctx, "id")
+
+	// Then
+	require.NoError(t, err)
+	for _, table := range []string{"personal_access_token", "user_session", "user_role", "organization_member"} {
+		var count int
+		require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM `+table+` WHERE user_id = 'id'`))
+		require.Equal(t, 0, count, table)
+	}
+}
@@ -1,36 +1,111 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/go-sql-driver/mysql"
 	"github.com/mateoferrari97/auth/internal"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	mysqlSearch  = `%s LIKE :search`
+	sqliteSearch = `%s LIKE :search ESCAPE '\'`
 )
 
 type UserRepository struct {
-	db *sqlx.DB
+	db *DB
+	// search matches a column against the :search pattern, which is escaped by escapeLike.
+	search string
+	// updateQuery is the optimistic update run by UpdateUser.
+	updateQuery string
+	// isDuplicate reports whether err is the driver's unique constraint violation.
+	isDuplicate func(err error) bool
+}
+
+func NewUserRepository(db *DB) Repository {
+	return &UserRepository{
+		db:          db,
+		search:      mysqlSearch,
+		updateQuery: updateUser,
+		isDuplicate: isMySQLDuplicateEntry,
+	}
 }
 
-func NewUserRepository(db *sqlx.DB) Repository {
+// NewSQLiteUserRepository stores users in the same tables as the MySQL repository. SQLite
+// has no default LIKE escape character, so searches declare it, and keeps times as text, so
+// they are compared as julian days rather than strings.
+func NewSQLiteUserRepository(db *DB) Repository {
 	return &UserRepository{
-		db: db,
+		db:          db,
+		search:      sqliteSearch,
+		updateQuery: sqliteUpdateUser,
+		isDuplicate: isSQLiteUniqueViolation,
 	}
 }
 
+// mysqlDuplicateEntry is ER_DUP_ENTRY.
+const mysqlDuplicateEntry = 1062
+
+func isMySQLDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
 type user struct {
-	ID        string `db:"_id"`
-	Firstname string `db:"firstname"`
-	Lastname  string `db:"lastname"`
-	Email     string `db:"email"`
-	Password  string `db:"password"`
+	ID                    string       `db:"_id"`
+	Firstname             string       `db:"firstname"`
+	Lastname              string       `db:"lastname"`
+	Status                string       `db:"status"`
+	PasswordResetRequired bool         `db:"password_reset_required"`
+	CreatedAt             time.Time    `db:"created_at"`
+	UpdatedAt             time.Time    `db:"updated_at"`
+	DeleteAfter           sql.NullTime `db:"delete_after"`
+	Email                 string       `db:"email"`
+	Password              string       `db:"password"`
+}
+
+func (u user) toUser() User {
+	var createdAt, updatedAt *time.Time
+	if !u.CreatedAt.IsZero() {
+		createdAt = &u.CreatedAt
+	}
+
+	if !u.UpdatedAt.IsZero() {
+		updatedAt = &u.UpdatedAt
+	}
+
+	return User{
+		ID:                    u.ID,
+		Firstname:             u.Firstname,
+		Lastname:              u.Lastname,
+		Email:                 u.Email,
+		Status:                u.Status,
+		PasswordResetRequired: u.PasswordResetRequired,
+		CreatedAt:             createdAt,
+		UpdatedAt:             updatedAt,
+		DeleteAfter:           timeFromNullTime(u.DeleteAfter),
+	}
 }
 
 const findUserByEmail = `SELECT COUNT(1) FROM login WHERE email = :email`
 
-func (r *UserRepository) FindUserByEmail(email string) error {
-	stmt, err := r.db.PrepareNamed(findUserByEmail)
+func (r *UserRepository) FindUserByEmail(ctx context.Context, email string) (err error) {
+	ctx, end := r.db.start(ctx, "FindUserByEmail")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, findUserByEmail)
 	if err != nil {
 		return err
 	}
@@ -40,7 +115,7 @@ func (r *UserRepository) FindUserByEmail(email string) error {
 	queryParams := map[string]interface{}{"email": email}
 
 	var count int
-	err = stmt.Get(&count, queryParams)
+	err = stmt.GetContext(ctx, &count, queryParams)
 	if err != nil {
 		return err
 	}
@@ -52,14 +127,17 @@ func (r *UserRepository) FindUserByEmail(email string) error {
 	return nil
 }
 
-const getUserByEmail = `SELECT user._id, user.firstname, user.lastname, login.email, login.password
+const getUserByEmail = `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
 								FROM login
 								INNER JOIN user
 								ON user.id = login.user_id
 								WHERE email = :email`
 
-func (r *UserRepository) GetUserByEmail(email string) (User, error) {
-	stmt, err := r.db.PrepareNamed(getUserByEmail)
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (_ User, err error) {
+	ctx, end := r.db.start(ctx, "GetUserByEmail")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUserByEmail)
 	if err != nil {
 		return User{}, err
 	}
@@ -69,7 +147,7 @@ func (r *UserRepository) GetUserByEmail(email string) (User, error) {
 	queryParams := map[string]interface{}{"email": email}
 
 	var u user
-	err = stmt.Get(&u, queryParams)
+	err = stmt.GetContext(ctx, &u, queryParams)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return User{}, err
 	}
@@ -78,12 +156,39 @@ func (r *UserRepository) GetUserByEmail(email string) (User, error) {
 		return User{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
 	}
 
-	return User{ // nolint
-		ID:        u.ID,
-		Firstname: u.Firstname,
-		Lastname:  u.Lastname,
-		Email:     u.Email,
-	}, nil
+	return u.toUser(), nil
+}
+
+const getUserByID = `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
+								FROM login
+								INNER JOIN user
+								ON user.id = login.user_id
+								WHERE user._id = :id`
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (_ User, err error) {
+	ctx, end := r.db.start(ctx, "GetUserByID")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUserByID)
+	if err != nil {
+		return User{}, err
+	}
+
+	defer stmt.Close()
+
+	queryParams := map[string]interface{}{"id": id}
+
+	var u user
+	err = stmt.GetContext(ctx, &u, queryParams)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return User{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return u.toUser(), nil
 }
 
 const (
@@ -91,8 +196,11 @@ const (
 	insertUserIntoLoginTable = `INSERT INTO login (email, password, user_id) VALUES (:email, :password, :user_id)`
 )
 
-func (r *UserRepository) SaveUser(newUser NewUser) (err error) {
-	tx, err := r.db.Beginx()
+func (r *UserRepository) SaveUser(ctx context.Context, newUser NewUser) (err error) {
+	ctx, end := r.db.start(ctx, "SaveUser")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("beggining tx: %v", err)
 	}
@@ -103,11 +211,15 @@ func (r *UserRepository) SaveUser(newUser NewUser) (err error) {
 		}
 	}()
 
-	result, err := tx.NamedExec(insertUserIntoUserTable, map[string]interface{}{
+	result, err := tx.NamedExecContext(ctx, insertUserIntoUserTable, map[string]interface{}{
 		"_id":       newUser.ID,
 		"firstname": newUser.Firstname,
 		"lastname":  newUser.Lastname,
 	})
+	if r.isDuplicate(err) {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -117,12 +229,352 @@ func (r *UserRepository) SaveUser(newUser NewUser) (err error) {
 		return fmt.Errorf("getting last insert id: %v", err)
 	}
 
-	_, err = tx.NamedExec(insertUserIntoLoginTable, map[string]interface{}{
+	_, err = tx.NamedExecContext(ctx, insertUserIntoLoginTable, map[string]interface{}{
 		"email":    newUser.Email,
 		"password": newUser.Password,
 		"user_id":  lastID,
 	})
+	if r.isDuplicate(err) {
+		return fmt.Errorf("%w: user already exists", internal.ErrResourceAlreadyExists)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const (
+	updateUser = `UPDATE user
+					SET firstname = :firstname, lastname = :lastname, updated_at = :updated_at
+					WHERE _id = :id AND updated_at = :previous_updated_at`
+	sqliteUpdateUser = `UPDATE user
+					SET firstname = :firstname, lastname = :lastname, updated_at = :updated_at
+					WHERE _id = :id AND julianday(updated_at) = julianday(:previous_updated_at)`
+)
+
+func (r *UserRepository) UpdateUser(ctx context.Context, u User, previousUpdatedAt time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateUser")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, r.updateQuery, map[string]interface{}{
+		"id":                  u.ID,
+		"firstname":           u.Firstname,
+		"lastname":            u.Lastname,
+		"updated_at":          u.UpdatedAt,
+		"previous_updated_at": previousUpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: user has been modified", internal.ErrPreconditionFailed)
+	}
+
+	return nil
+}
+
+const getUserPassword = `SELECT login.password
+						FROM login
+						INNER JOIN user
+						ON user.id = login.user_id
+						WHERE user._id = :id`
+
+func (r *UserRepository) GetUserPassword(ctx context.Context, id string) (_ string, err error) {
+	ctx, end := r.db.start(ctx, "GetUserPassword")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUserPassword)
+	if err != nil {
+		return "", err
+	}
+
+	defer stmt.Close()
+
+	var password string
+	err = stmt.GetContext(ctx, &password, map[string]interface{}{"id": id})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return password, nil
+}
+
+const scheduleUserDeletion = `UPDATE user SET delete_after = :delete_after, updated_at = :updated_at WHERE _id = :id`
+
+func (r *UserRepository) ScheduleUserDeletion(ctx context.Context, id string, deleteAfter *time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "ScheduleUserDeletion")
+	defer end(&err)
+
+	return r.updateUser(ctx, scheduleUserDeletion, map[string]interface{}{
+		"id":           id,
+		"delete_after": nullTimeFromTime(deleteAfter),
+		"updated_at":   time.Now(),
+	})
+}
+
+const getUsersScheduledForDeletion = `SELECT _id FROM user WHERE delete_after IS NOT NULL AND delete_after <= :now`
+
+func (r *UserRepository) GetUsersScheduledForDeletion(ctx context.Context, now time.Time) (_ []string, err error) {
+	ctx, end := r.db.start(ctx, "GetUsersScheduledForDeletion")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUsersScheduledForDeletion)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var ids []string
+	if err := stmt.SelectContext(ctx, &ids, map[string]interface{}{"now": now}); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+const (
+	countUsers = `SELECT COUNT(1)
+					FROM login
+					INNER JOIN user
+					ON user.id = login.user_id`
+	getUsers = `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
+					FROM login
+					INNER JOIN user
+					ON user.id = login.user_id`
+	orderAndPaginateUsers = ` ORDER BY user.created_at DESC, user.id DESC LIMIT :limit OFFSET :offset`
+)
+
+func (r *UserRepository) GetUsers(ctx context.Context, query UserQuery) (_ []User, _ int, err error) {
+	ctx, end := r.db.start(ctx, "GetUsers")
+	defer end(&err)
+
+	where, queryParams := userQueryConditions(query, "user", r.search)
+
+	countStmt, err := r.db.PrepareNamedContext(ctx, countUsers+where)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer countStmt.Close()
+
+	var total int
+	if err := countStmt.GetContext(ctx, &total, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getUsers+where+orderAndPaginateUsers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer stmt.Close()
+
+	queryParams["limit"] = query.Limit
+	queryParams["offset"] = query.Offset
+
+	var users []user
+	if err := stmt.SelectContext(ctx, &users, queryParams); err != nil {
+		return nil, 0, err
+	}
+
+	resp := make([]User, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, u.toUser())
+	}
+
+	return resp, total, nil
+}
+
+// userQueryConditions builds the WHERE clause of a user listing. userTable and search differ
+// between dialects: postgres can't name a table user and compares case-sensitively with LIKE.
+func userQueryConditions(query UserQuery, userTable string, search string) (string, map[string]interface{}) {
+	var conditions []string
+	queryParams := make(map[string]interface{})
+
+	if query.Search != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s OR %s OR %s)",
+			fmt.Sprintf(search, "login.email"),
+			fmt.Sprintf(search, userTable+".firstname"),
+			fmt.Sprintf(search, userTable+".lastname"),
+		))
+		queryParams["search"] = "%" + escapeLike(query.Search) + "%"
+	}
+
+	if query.Status != "" {
+		conditions = append(conditions, userTable+".status = :status")
+		queryParams["status"] = query.Status
+	}
+
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, userTable+".created_at >= :created_after")
+		queryParams["created_after"] = *query.CreatedAfter
+	}
+
+	if query.CreatedBefore != nil {
+		conditions = append(conditions, userTable+".created_at < :created_before")
+		queryParams["created_before"] = *query.CreatedBefore
+	}
+
+	if len(conditions) == 0 {
+		return "", queryParams
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), queryParams
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+const updateUserStatus = `UPDATE user SET status = :status, updated_at = :updated_at WHERE _id = :id`
+
+func (r *UserRepository) UpdateUserStatus(ctx context.Context, id string, status string) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateUserStatus")
+	defer end(&err)
+
+	return r.updateUser(ctx, updateUserStatus, map[string]interface{}{
+		"id":         id,
+		"status":     status,
+		"updated_at": time.Now(),
+	})
+}
+
+const requirePasswordReset = `UPDATE user SET password_reset_required = TRUE, updated_at = :updated_at WHERE _id = :id`
+
+func (r *UserRepository) RequirePasswordReset(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "RequirePasswordReset")
+	defer end(&err)
+
+	return r.updateUser(ctx, requirePasswordReset, map[string]interface{}{
+		"id":         id,
+		"updated_at": time.Now(),
+	})
+}
+
+const (
+	resetPassword      = `UPDATE user SET password_reset_required = FALSE, updated_at = :updated_at WHERE _id = :id`
+	resetLoginPassword = `UPDATE login SET password = :password WHERE user_id = (SELECT id FROM user WHERE _id = :id)`
+)
+
+// ResetPassword replaces the user's password and clears a forced reset.
+func (r *UserRepository) ResetPassword(ctx context.Context, id string, password string) (err error) {
+	ctx, end := r.db.start(ctx, "ResetPassword")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{
+		"id":         id,
+		"password":   password,
+		"updated_at": time.Now(),
+	}
+
+	result, err := tx.NamedExecContext(ctx, resetPassword, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+		return err
+	}
+
+	if _, err = tx.NamedExecContext(ctx, resetLoginPassword, queryParams); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) updateUser(ctx context.Context, query string, queryParams map[string]interface{}) error {
+	result, err := r.db.NamedExecContext(ctx, query, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
 	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+var deleteUserData = []string{
+	`DELETE FROM personal_access_token WHERE user_id = :id`,
+	`DELETE FROM user_session WHERE user_id = :id`,
+	`DELETE FROM user_role WHERE user_id = :id`,
+	`DELETE FROM organization_member WHERE user_id = :id`,
+	`DELETE FROM login WHERE user_id = (SELECT id FROM user WHERE _id = :id)`,
+}
+
+const deleteUser = `DELETE FROM user WHERE _id = :id`
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteUser")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{"id": id}
+	for _, query := range deleteUserData {
+		if _, err = tx.NamedExecContext(ctx, query, queryParams); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.NamedExecContext(ctx, deleteUser, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
 		return err
 	}
 
@@ -1,11 +1,14 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
 )
@@ -19,7 +22,7 @@ func TestFindUserByEmail(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	email := "mateo.ferrari97@gmail.com"
 
 	mock.ExpectPrepare(`SELECT COUNT(1) FROM login WHERE email = ?`).
@@ -34,7 +37,7 @@ func TestFindUserByEmail(t *testing.T) {
 		)
 
 	// When
-	err = r.FindUserByEmail(email)
+	err = r.FindUserByEmail(context.Background(), email)
 
 	// Then
 	require.NoError(t, err)
@@ -49,14 +52,14 @@ func TestFindUserByEmail_PreparingCountUsersFromEmailError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	email := "mateo.ferrari97@gmail.com"
 
 	mock.ExpectPrepare(`SELECT COUNT(1) FROM login WHERE email = ?`).
 		WillReturnError(errors.New("preparing query error"))
 
 	// When
-	err = r.FindUserByEmail(email)
+	err = r.FindUserByEmail(context.Background(), email)
 
 	// Then
 	require.EqualError(t, err, "preparing query error")
@@ -71,7 +74,7 @@ func TestFindUserByEmail_ExecutingCountUsersFromEmailError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	email := "mateo.ferrari97@gmail.com"
 
 	mock.ExpectPrepare(`SELECT COUNT(1) FROM login WHERE email = ?`).
@@ -82,7 +85,7 @@ func TestFindUserByEmail_ExecutingCountUsersFromEmailError(t *testing.T) {
 		WillReturnError(errors.New("executing query error"))
 
 	// When
-	err = r.FindUserByEmail(email)
+	err = r.FindUserByEmail(context.Background(), email)
 
 	// Then
 	require.EqualError(t, err, "executing query error")
@@ -97,7 +100,7 @@ func TestFindUserByEmail_NotFound(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	email := "mateo.ferrari97@gmail.com"
 
 	mock.ExpectPrepare(`SELECT COUNT(1) FROM login WHERE email = ?`).
@@ -112,7 +115,7 @@ func TestFindUserByEmail_NotFound(t *testing.T) {
 		)
 
 	// When
-	err = r.FindUserByEmail(email)
+	err = r.FindUserByEmail(context.Background(), email)
 
 	// Then
 	require.EqualError(t, err, "resource not found: db not found")
@@ -127,10 +130,10 @@ func TestGetUserByEmail(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 
 	email := "mateo.ferrari97@gmail.com"
-	q := `SELECT user._id, user.firstname, user.lastname, login.email, login.password
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
 			FROM login
 			INNER JOIN user
 			ON user.id = login.user_id
@@ -151,7 +154,7 @@ func TestGetUserByEmail(t *testing.T) {
 		)
 
 	// When
-	resp, err := r.GetUserByEmail(email)
+	resp, err := r.GetUserByEmail(context.Background(), email)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -172,10 +175,10 @@ func TestGetUserByEmail_PreparingSelectUsersQueryError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 
 	email := "mateo.ferrari97@gmail.com"
-	q := `SELECT user._id, user.firstname, user.lastname, login.email, login.password
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
 			FROM login
 			INNER JOIN user
 			ON user.id = login.user_id
@@ -184,7 +187,7 @@ func TestGetUserByEmail_PreparingSelectUsersQueryError(t *testing.T) {
 	mock.ExpectPrepare(q).WillReturnError(errors.New("preparing query error"))
 
 	// When
-	_, err = r.GetUserByEmail(email)
+	_, err = r.GetUserByEmail(context.Background(), email)
 
 	// Then
 	require.EqualError(t, err, "preparing query error")
@@ -199,10 +202,10 @@ func TestGetUserByEmail_ExecutingSelectUsersQueryError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 
 	email := "mateo.ferrari97@gmail.com"
-	q := `SELECT user._id, user.firstname, user.lastname, login.email, login.password
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
 			FROM login
 			INNER JOIN user
 			ON user.id = login.user_id
@@ -214,7 +217,7 @@ func TestGetUserByEmail_ExecutingSelectUsersQueryError(t *testing.T) {
 		WillReturnError(errors.New("executing query error"))
 
 	// When
-	_, err = r.GetUserByEmail(email)
+	_, err = r.GetUserByEmail(context.Background(), email)
 
 	// Then
 	require.EqualError(t, err, "executing query error")
@@ -229,10 +232,10 @@ func TestGetUserByEmail_NotFound(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 
 	email := "mateo.ferrari97@gmail.com"
-	q := `SELECT user._id, user.firstname, user.lastname, login.email, login.password
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
 			FROM login
 			INNER JOIN user
 			ON user.id = login.user_id
@@ -244,7 +247,77 @@ func TestGetUserByEmail_NotFound(t *testing.T) {
 		WillReturnError(sql.ErrNoRows)
 
 	// When
-	_, err = r.GetUserByEmail(email)
+	_, err = r.GetUserByEmail(context.Background(), email)
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+}
+
+func TestGetUserByID(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	id := "88096ae1-129e-4ef8-8bdc-a8ace0753687"
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
+			FROM login
+			INNER JOIN user
+			ON user.id = login.user_id
+			WHERE user._id = ?`
+
+	mock.ExpectPrepare(q).WillReturnError(nil)
+	mock.ExpectQuery(q).
+		WithArgs(id).
+		WillReturnError(nil).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"_id", "firstname", "lastname", "email", "password"}).
+				AddRow(id, "mateo", "ferrari coronel", "mateo.ferrari97@gmail.com", "password"),
+		)
+
+	// When
+	resp, err := r.GetUserByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, id, resp.ID)
+	require.Equal(t, "mateo", resp.Firstname)
+	require.Equal(t, "ferrari coronel", resp.Lastname)
+	require.Equal(t, "mateo.ferrari97@gmail.com", resp.Email)
+}
+
+func TestGetUserByID_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	id := "88096ae1-129e-4ef8-8bdc-a8ace0753687"
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
+			FROM login
+			INNER JOIN user
+			ON user.id = login.user_id
+			WHERE user._id = ?`
+
+	mock.ExpectPrepare(q).WillReturnError(nil)
+	mock.ExpectQuery(q).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+
+	// When
+	_, err = r.GetUserByID(context.Background(), id)
 
 	// Then
 	require.EqualError(t, err, "resource not found: db not found")
@@ -259,7 +332,7 @@ func TestSaveUser(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -286,12 +359,84 @@ func TestSaveUser(t *testing.T) {
 	mock.ExpectCommit().WillReturnError(nil)
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.NoError(t, err)
 }
 
+func TestSaveUser_DuplicateEmailError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	user := NewUser{
+		ID:        "id",
+		Firstname: "mateo",
+		Lastname:  "ferrari coronel",
+		Email:     "mateo.ferrari97@gmail.com",
+		Password:  "123",
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec(`INSERT INTO user (_id, firstname, lastname) VALUES (?, ?, ?)`).
+		WithArgs(user.ID, user.Firstname, user.Lastname).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`INSERT INTO login (email, password, user_id) VALUES (?, ?, ?)`).
+		WithArgs(user.Email, user.Password, 1).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mateo.ferrari97@gmail.com' for key 'email'"})
+
+	mock.ExpectRollback()
+
+	// When
+	err = r.SaveUser(context.Background(), user)
+
+	// Then
+	require.EqualError(t, err, "resource already exists: user already exists")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveUser_DuplicateIDError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	user := NewUser{
+		ID:        "id",
+		Firstname: "mateo",
+		Lastname:  "ferrari coronel",
+		Email:     "mateo.ferrari97@gmail.com",
+		Password:  "123",
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec(`INSERT INTO user (_id, firstname, lastname) VALUES (?, ?, ?)`).
+		WithArgs(user.ID, user.Firstname, user.Lastname).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'id' for key '_id'"})
+
+	mock.ExpectRollback()
+
+	// When
+	err = r.SaveUser(context.Background(), user)
+
+	// Then
+	require.EqualError(t, err, "resource already exists: user already exists")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSaveUser_BeginTxError(t *testing.T) {
 	// Given
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -301,7 +446,7 @@ func TestSaveUser_BeginTxError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -313,7 +458,7 @@ func TestSaveUser_BeginTxError(t *testing.T) {
 	mock.ExpectBegin().WillReturnError(errors.New("begging tx error"))
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.EqualError(t, err, "beggining tx: begging tx error")
@@ -328,7 +473,7 @@ func TestSaveUser_InsertingUserIntoUserTableError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -348,7 +493,7 @@ func TestSaveUser_InsertingUserIntoUserTableError(t *testing.T) {
 	mock.ExpectRollback()
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.EqualError(t, err, "preparing query error")
@@ -363,7 +508,7 @@ func TestSaveUser_GettingLastIDError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -386,7 +531,7 @@ func TestSaveUser_GettingLastIDError(t *testing.T) {
 	mock.ExpectRollback()
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.EqualError(t, err, "getting last insert id: db error")
@@ -401,7 +546,7 @@ func TestSaveUser_InsertingUserIntoLoginTableError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -429,7 +574,7 @@ func TestSaveUser_InsertingUserIntoLoginTableError(t *testing.T) {
 	mock.ExpectRollback()
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.EqualError(t, err, "db error")
@@ -444,7 +589,7 @@ func TestSaveUser_CommittingError(t *testing.T) {
 
 	defer db.Close()
 
-	r := NewUserRepository(sqlx.NewDb(db, "mysql"))
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
 	user := NewUser{
 		ID:        "id",
 		Firstname: "mateo",
@@ -473,8 +618,135 @@ func TestSaveUser_CommittingError(t *testing.T) {
 	mock.ExpectRollback()
 
 	// When
-	err = r.SaveUser(user)
+	err = r.SaveUser(context.Background(), user)
 
 	// Then
 	require.EqualError(t, err, "db error")
 }
+
+func TestGetUsers(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	where := ` WHERE (login.email LIKE ? OR user.firstname LIKE ? OR user.lastname LIKE ?) AND user.status = ?`
+	count := `SELECT COUNT(1)
+			FROM login
+			INNER JOIN user
+			ON user.id = login.user_id` + where
+	q := `SELECT user._id, user.firstname, user.lastname, user.status, user.password_reset_required, user.created_at, user.updated_at, user.delete_after, login.email, login.password
+			FROM login
+			INNER JOIN user
+			ON user.id = login.user_id` + where + ` ORDER BY user.created_at DESC, user.id DESC LIMIT ? OFFSET ?`
+
+	mock.ExpectPrepare(count)
+	mock.ExpectQuery(count).
+		WithArgs(`%mateo\_f%`, `%mateo\_f%`, `%mateo\_f%`, "active").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(11))
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs(`%mateo\_f%`, `%mateo\_f%`, `%mateo\_f%`, "active", 10, 10).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"_id", "firstname", "lastname", "status", "email"}).
+				AddRow("id", "mateo", "ferrari coronel", "active", "mateo.ferrari97@gmail.com"),
+		)
+
+	// When
+	resp, total, err := r.GetUsers(context.Background(), UserQuery{Search: "mateo_f", Status: "active", Limit: 10, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 11, total)
+	require.Equal(t, []User{{ID: "id", Firstname: "mateo", Lastname: "ferrari coronel", Email: "mateo.ferrari97@gmail.com", Status: "active"}}, resp)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUser_NotFound(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM personal_access_token WHERE user_id = ?`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM user_session WHERE user_id = ?`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM user_role WHERE user_id = ?`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM organization_member WHERE user_id = ?`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM login WHERE user_id = (SELECT id FROM user WHERE _id = ?)`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM user WHERE _id = ?`).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// When
+	err = r.DeleteUser(context.Background(), "id")
+
+	// Then
+	require.EqualError(t, err, "resource not found: db not found")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUser_PreconditionFailed(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	previous := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := previous.Add(time.Hour)
+	u := User{ID: "id", Firstname: "mateo", Lastname: "ferrari", UpdatedAt: &updatedAt}
+
+	mock.ExpectExec(`UPDATE user
+			SET firstname = ?, lastname = ?, updated_at = ?
+			WHERE _id = ? AND updated_at = ?`).
+		WithArgs("mateo", "ferrari", updatedAt, "id", previous).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.UpdateUser(context.Background(), u, previous)
+
+	// Then
+	require.EqualError(t, err, "precondition failed: user has been modified")
+}
+
+func TestGetUsersScheduledForDeletion(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewUserRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+	q := `SELECT _id FROM user WHERE delete_after IS NOT NULL AND delete_after <= ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"_id"}).AddRow("a").AddRow("b"))
+
+	// When
+	resp, err := r.GetUsersScheduledForDeletion(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, []string{"a", "b"}, resp)
+}
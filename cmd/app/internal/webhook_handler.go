@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	postAdminWebhooks              = "/admin/webhooks"
+	getAdminWebhooks               = "/admin/webhooks"
+	deleteAdminWebhook             = "/admin/webhooks/{id}"
+	getAdminWebhookDeliveries      = "/admin/webhooks/deliveries"
+	postAdminWebhookDeliveryReplay = "/admin/webhooks/deliveries/{id}/replay"
+)
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=512"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=user.registered user.updated user.deleted"`
+}
+
+type CreateWebhookHandler func(ctx context.Context, req CreateWebhookRequest) (NewWebhook, error)
+
+func (h *Handler) RouteCreateWebhook(handler CreateWebhookHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		var req CreateWebhookRequest
+		if err := decodeAndValidate(r, &req); err != nil {
+			return err
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusCreated)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminWebhooks, []string{PermissionWebhooksWrite}, wrapH)
+}
+
+type ListWebhooksHandler func(ctx context.Context) ([]Webhook, error)
+
+func (h *Handler) RouteListWebhooks(handler ListWebhooksHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context())
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminWebhooks, []string{PermissionWebhooksRead}, wrapH)
+}
+
+type DeleteWebhookHandler func(ctx context.Context, id string) error
+
+func (h *Handler) RouteDeleteWebhook(handler DeleteWebhookHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		if err := handler(r.Context(), mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, nil, http.StatusNoContent)
+	}
+
+	h.WrapWithPermissions(http.MethodDelete, deleteAdminWebhook, []string{PermissionWebhooksWrite}, wrapH)
+}
+
+type ListWebhookDeliveriesRequest struct {
+	Status string `validate:"omitempty,oneof=pending delivered dead"`
+}
+
+type ListWebhookDeliveriesHandler func(ctx context.Context, req ListWebhookDeliveriesRequest) ([]WebhookDelivery, error)
+
+func (h *Handler) RouteListWebhookDeliveries(handler ListWebhookDeliveriesHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		req := ListWebhookDeliveriesRequest{Status: r.URL.Query().Get("status")}
+		if err := _v.Struct(req); err != nil {
+			return fmt.Errorf("validating request: %w: %v", internal.ErrUnprocessableEntity, err)
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusOK)
+	}
+
+	h.WrapWithPermissions(http.MethodGet, getAdminWebhookDeliveries, []string{PermissionWebhooksRead}, wrapH)
+}
+
+type ReplayWebhookDeliveryHandler func(ctx context.Context, id string) (WebhookDelivery, error)
+
+func (h *Handler) RouteReplayWebhookDelivery(handler ReplayWebhookDeliveryHandler) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		resp, err := handler(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			return err
+		}
+
+		return internal.RespondJSON(w, resp, http.StatusAccepted)
+	}
+
+	h.WrapWithPermissions(http.MethodPost, postAdminWebhookDeliveryReplay, []string{PermissionWebhooksWrite}, wrapH)
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_RouteCreateWebhook(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionWebhooksWrite)
+	h := NewHandler(w)
+
+	h.RouteCreateWebhook(func(_ context.Context, req CreateWebhookRequest) (NewWebhook, error) {
+		require.Equal(t, "https://crm.example.com/hooks", req.URL)
+		require.Equal(t, []string{WebhookEventUserRegistered}, req.Events)
+
+		return NewWebhook{Webhook: Webhook{ID: "id", URL: req.URL, Events: req.Events}, Secret: "whsec_secret"}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	b := []byte(`{"url": "https://crm.example.com/hooks", "events": ["user.registered"]}`)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/webhooks", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var r map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&r)
+
+	// Then
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "whsec_secret", r["secret"])
+}
+
+func TestHandler_RouteCreateWebhook_UnknownEventError(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionWebhooksWrite)
+	h := NewHandler(w)
+
+	h.RouteCreateWebhook(func(_ context.Context, req CreateWebhookRequest) (NewWebhook, error) {
+		return NewWebhook{}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	b := []byte(`{"url": "https://crm.example.com/hooks", "events": ["user.logged_in"]}`)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/webhooks", ts.URL), bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandler_RouteListWebhookDeliveries(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionWebhooksRead)
+	h := NewHandler(w)
+
+	h.RouteListWebhookDeliveries(func(_ context.Context, req ListWebhookDeliveriesRequest) ([]WebhookDelivery, error) {
+		require.Equal(t, WebhookDeliveryDead, req.Status)
+		return []WebhookDelivery{{ID: "delivery", Status: WebhookDeliveryDead}}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/webhooks/deliveries?status=dead", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandler_RouteReplayWebhookDelivery(t *testing.T) {
+	// Given
+	w := newAuthorizedServer(PermissionWebhooksWrite)
+	h := NewHandler(w)
+
+	h.RouteReplayWebhookDelivery(func(_ context.Context, id string) (WebhookDelivery, error) {
+		require.Equal(t, "delivery", id)
+		return WebhookDelivery{ID: id, Status: WebhookDeliveryPending}, nil
+	})
+
+	// When
+	ts := httptest.NewServer(w.Router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/webhooks/deliveries/delivery/replay", ts.URL), nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
@@ -0,0 +1,324 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type WebhookSQLRepository struct {
+	db *DB
+}
+
+func NewWebhookRepository(db *DB) WebhookRepository {
+	return &WebhookSQLRepository{
+		db: db,
+	}
+}
+
+type webhook struct {
+	ID        string    `db:"id"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    string    `db:"events"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (w webhook) toWebhook() Webhook {
+	return Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    strings.Fields(w.Events),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+type webhookDelivery struct {
+	ID            string         `db:"id"`
+	WebhookID     string         `db:"webhook_id"`
+	Event         string         `db:"event"`
+	Payload       string         `db:"payload"`
+	Status        string         `db:"status"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt time.Time      `db:"next_attempt_at"`
+	LastError     string         `db:"last_error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	DeliveredAt   sql.NullTime   `db:"delivered_at"`
+	URL           sql.NullString `db:"url"`
+	Secret        sql.NullString `db:"secret"`
+}
+
+func (d webhookDelivery) toWebhookDelivery() WebhookDelivery {
+	return WebhookDelivery{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID,
+		Event:         d.Event,
+		Payload:       []byte(d.Payload),
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError,
+		CreatedAt:     d.CreatedAt,
+		DeliveredAt:   timeFromNullTime(d.DeliveredAt),
+		URL:           d.URL.String,
+		Secret:        d.Secret.String,
+	}
+}
+
+func webhookDeliveryParams(d WebhookDelivery) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              d.ID,
+		"webhook_id":      d.WebhookID,
+		"event":           d.Event,
+		"payload":         string(d.Payload),
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"next_attempt_at": d.NextAttemptAt,
+		"last_error":      d.LastError,
+		"created_at":      d.CreatedAt,
+		"delivered_at":    nullTimeFromTime(d.DeliveredAt),
+	}
+}
+
+const insertWebhook = `INSERT INTO webhook (id, url, secret, events, created_at)
+					VALUES (:id, :url, :secret, :events, :created_at)`
+
+func (r *WebhookSQLRepository) SaveWebhook(ctx context.Context, w Webhook) (err error) {
+	ctx, end := r.db.start(ctx, "SaveWebhook")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, insertWebhook, map[string]interface{}{
+		"id":         w.ID,
+		"url":        w.URL,
+		"secret":     w.Secret,
+		"events":     strings.Join(w.Events, " "),
+		"created_at": w.CreatedAt,
+	})
+
+	return err
+}
+
+const getWebhooks = `SELECT id, url, secret, events, created_at FROM webhook ORDER BY created_at`
+
+func (r *WebhookSQLRepository) GetWebhooks(ctx context.Context) (_ []Webhook, err error) {
+	ctx, end := r.db.start(ctx, "GetWebhooks")
+	defer end(&err)
+
+	var webhooks []webhook
+	if err := r.db.SelectContext(ctx, &webhooks, getWebhooks); err != nil {
+		return nil, err
+	}
+
+	resp := make([]Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		resp = append(resp, w.toWebhook())
+	}
+
+	return resp, nil
+}
+
+const (
+	deleteWebhookDeliveries = `DELETE FROM webhook_delivery WHERE webhook_id = :id`
+	deleteWebhook           = `DELETE FROM webhook WHERE id = :id`
+)
+
+func (r *WebhookSQLRepository) DeleteWebhook(ctx context.Context, id string) (err error) {
+	ctx, end := r.db.start(ctx, "DeleteWebhook")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	queryParams := map[string]interface{}{"id": id}
+	if _, err = tx.NamedExecContext(ctx, deleteWebhookDeliveries, queryParams); err != nil {
+		return err
+	}
+
+	result, err := tx.NamedExecContext(ctx, deleteWebhook, queryParams)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		err = fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const insertWebhookDelivery = `INSERT INTO webhook_delivery (id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at)
+							VALUES (:id, :webhook_id, :event, :payload, :status, :attempts, :next_attempt_at, :last_error, :created_at, :delivered_at)`
+
+func (r *WebhookSQLRepository) SaveWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) (err error) {
+	ctx, end := r.db.start(ctx, "SaveWebhookDeliveries")
+	defer end(&err)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	for _, d := range deliveries {
+		if _, err = tx.NamedExecContext(ctx, insertWebhookDelivery, webhookDeliveryParams(d)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const (
+	getWebhookDeliveries = `SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at
+						FROM webhook_delivery`
+	getWebhookDeliveriesByStatus = getWebhookDeliveries + ` WHERE status = :status`
+	orderWebhookDeliveries       = ` ORDER BY created_at DESC LIMIT :limit`
+)
+
+func (r *WebhookSQLRepository) GetWebhookDeliveries(ctx context.Context, status string, limit int) (_ []WebhookDelivery, err error) {
+	ctx, end := r.db.start(ctx, "GetWebhookDeliveries")
+	defer end(&err)
+
+	query := getWebhookDeliveries
+	if status != "" {
+		query = getWebhookDeliveriesByStatus
+	}
+
+	return r.getWebhookDeliveries(ctx, query+orderWebhookDeliveries, map[string]interface{}{"status": status, "limit": limit})
+}
+
+const getWebhookDelivery = getWebhookDeliveries + ` WHERE id = :id`
+
+func (r *WebhookSQLRepository) GetWebhookDelivery(ctx context.Context, id string) (_ WebhookDelivery, err error) {
+	ctx, end := r.db.start(ctx, "GetWebhookDelivery")
+	defer end(&err)
+
+	stmt, err := r.db.PrepareNamedContext(ctx, getWebhookDelivery)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	defer stmt.Close()
+
+	var d webhookDelivery
+	err = stmt.GetContext(ctx, &d, map[string]interface{}{"id": id})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return WebhookDelivery{}, err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return WebhookDelivery{}, fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return d.toWebhookDelivery(), nil
+}
+
+const getDueWebhookDeliveries = `SELECT webhook_delivery.id, webhook_delivery.webhook_id, webhook_delivery.event, webhook_delivery.payload,
+							webhook_delivery.status, webhook_delivery.attempts, webhook_delivery.next_attempt_at, webhook_delivery.last_error,
+							webhook_delivery.created_at, webhook_delivery.delivered_at, webhook.url, webhook.secret
+							FROM webhook_delivery
+							INNER JOIN webhook
+							ON webhook.id = webhook_delivery.webhook_id
+							WHERE webhook_delivery.status = 'pending' AND webhook_delivery.next_attempt_at <= :now
+								AND (webhook_delivery.locked_until IS NULL OR webhook_delivery.locked_until < :now)
+							ORDER BY webhook_delivery.next_attempt_at
+							LIMIT :limit`
+
+func (r *WebhookSQLRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) (_ []WebhookDelivery, err error) {
+	ctx, end := r.db.start(ctx, "GetDueWebhookDeliveries")
+	defer end(&err)
+
+	return r.getWebhookDeliveries(ctx, getDueWebhookDeliveries, map[string]interface{}{"now": now, "limit": limit})
+}
+
+func (r *WebhookSQLRepository) getWebhookDeliveries(ctx context.Context, query string, queryParams map[string]interface{}) ([]WebhookDelivery, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	var deliveries []webhookDelivery
+	if err := stmt.SelectContext(ctx, &deliveries, queryParams); err != nil {
+		return nil, err
+	}
+
+	resp := make([]WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, d.toWebhookDelivery())
+	}
+
+	return resp, nil
+}
+
+// claimWebhookDelivery locks a due delivery until lockedUntil, so another instance polling at
+// the same time skips it instead of sending it twice. Updating the delivery releases it, and a
+// delivery another instance already attempted is no longer due, so it isn't claimed again.
+const claimWebhookDelivery = `UPDATE webhook_delivery
+							SET locked_until = :locked_until
+							WHERE id = :id AND status = 'pending' AND next_attempt_at <= :now
+								AND (locked_until IS NULL OR locked_until < :now)`
+
+func (r *WebhookSQLRepository) ClaimWebhookDelivery(ctx context.Context, id string, now time.Time, lockedUntil time.Time) (err error) {
+	ctx, end := r.db.start(ctx, "ClaimWebhookDelivery")
+	defer end(&err)
+
+	result, err := r.db.NamedExecContext(ctx, claimWebhookDelivery, map[string]interface{}{
+		"id":           id,
+		"now":          now,
+		"locked_until": lockedUntil,
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	}
+
+	return nil
+}
+
+const updateWebhookDelivery = `UPDATE webhook_delivery
+							SET status = :status, attempts = :attempts, next_attempt_at = :next_attempt_at,
+								last_error = :last_error, delivered_at = :delivered_at, locked_until = NULL
+							WHERE id = :id`
+
+func (r *WebhookSQLRepository) UpdateWebhookDelivery(ctx context.Context, d WebhookDelivery) (err error) {
+	ctx, end := r.db.start(ctx, "UpdateWebhookDelivery")
+	defer end(&err)
+
+	_, err = r.db.NamedExecContext(ctx, updateWebhookDelivery, webhookDeliveryParams(d))
+	return err
+}
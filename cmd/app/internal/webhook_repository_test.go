@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveWebhookDeliveries(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewWebhookRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+	deliveries := []WebhookDelivery{
+		{ID: "a", WebhookID: "crm", Event: WebhookEventUserDeleted, Payload: []byte(`{}`), Status: WebhookDeliveryPending, NextAttemptAt: now, CreatedAt: now},
+		{ID: "b", WebhookID: "billing", Event: WebhookEventUserDeleted, Payload: []byte(`{}`), Status: WebhookDeliveryPending, NextAttemptAt: now, CreatedAt: now},
+	}
+
+	q := `INSERT INTO webhook_delivery (id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at)
+							VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	mock.ExpectBegin()
+	for _, d := range deliveries {
+		mock.ExpectExec(q).
+			WithArgs(d.ID, d.WebhookID, d.Event, "{}", WebhookDeliveryPending, 0, now, "", now, nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	// When
+	err = r.SaveWebhookDeliveries(context.Background(), deliveries)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDueWebhookDeliveries(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewWebhookRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	q := `SELECT webhook_delivery.id, webhook_delivery.webhook_id, webhook_delivery.event, webhook_delivery.payload,
+			webhook_delivery.status, webhook_delivery.attempts, webhook_delivery.next_attempt_at, webhook_delivery.last_error,
+			webhook_delivery.created_at, webhook_delivery.delivered_at, webhook.url, webhook.secret
+			FROM webhook_delivery
+			INNER JOIN webhook
+			ON webhook.id = webhook_delivery.webhook_id
+			WHERE webhook_delivery.status = 'pending' AND webhook_delivery.next_attempt_at <= ?
+				AND (webhook_delivery.locked_until IS NULL OR webhook_delivery.locked_until < ?)
+			ORDER BY webhook_delivery.next_attempt_at
+			LIMIT ?`
+
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs(now, now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "payload", "status", "url", "secret"}).
+			AddRow("delivery", "crm", `{"event":"user.deleted"}`, WebhookDeliveryPending, "https://crm.example.com/hooks", "secret"))
+
+	// When
+	resp, err := r.GetDueWebhookDeliveries(context.Background(), now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Len(t, resp, 1)
+	require.Equal(t, "https://crm.example.com/hooks", resp[0].URL)
+	require.Equal(t, "secret", resp[0].Secret)
+	require.JSONEq(t, `{"event":"user.deleted"}`, string(resp[0].Payload))
+}
+
+func TestClaimWebhookDelivery_AlreadyClaimedError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewWebhookRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+	now := time.Now()
+
+	mock.ExpectExec(`UPDATE webhook_delivery
+			SET locked_until = ?
+			WHERE id = ? AND status = 'pending' AND next_attempt_at <= ?
+				AND (locked_until IS NULL OR locked_until < ?)`).
+		WithArgs(now.Add(time.Minute), "delivery", now, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// When
+	err = r.ClaimWebhookDelivery(context.Background(), "delivery", now, now.Add(time.Minute))
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWebhook_NotFoundError(t *testing.T) {
+	// Given
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("starting sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	r := NewWebhookRepository(&DB{DB: sqlx.NewDb(db, "mysql")})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM webhook_delivery WHERE webhook_id = ?`).
+		WithArgs("id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM webhook WHERE id = ?`).
+		WithArgs("id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// When
+	err = r.DeleteWebhook(context.Background(), "id")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrResourceNotFound))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
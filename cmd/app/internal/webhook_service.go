@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	PermissionWebhooksRead  = "webhooks:read"
+	PermissionWebhooksWrite = "webhooks:write"
+)
+
+// Webhook events cover the user lifecycle this service manages. It has no email verification and
+// doesn't let users change their email, so there are no events for those: they'll be added along
+// with the features. user.deleted is sent for admin deletions and for accounts purged after their
+// deletion grace period.
+const (
+	WebhookEventUserRegistered = "user.registered"
+	WebhookEventUserUpdated    = "user.updated"
+	WebhookEventUserDeleted    = "user.deleted"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	webhookSecretPrefix       = "whsec_"
+	webhookMaxAttempts        = 8
+	webhookInitialBackoff     = 30 * time.Second
+	webhookMaxBackoff         = time.Hour
+	webhookDeliveryBatch      = 100
+	webhookClaimDuration      = time.Minute
+	webhookDeliveriesLimit    = 100
+	webhookLastErrorMaxLength = 512
+)
+
+type WebhookRepository interface {
+	SaveWebhook(ctx context.Context, webhook Webhook) error
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	SaveWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	GetWebhookDeliveries(ctx context.Context, status string, limit int) ([]WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id string) (WebhookDelivery, error)
+	GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	ClaimWebhookDelivery(ctx context.Context, id string, now time.Time, lockedUntil time.Time) error
+	UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+}
+
+type WebhookClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type NewWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func (s *Service) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (NewWebhook, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return NewWebhook{}, fmt.Errorf("creating webhook: %v", err)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return NewWebhook{}, fmt.Errorf("generating webhook secret: %v", err)
+	}
+
+	webhook := Webhook{
+		ID:        id.String(),
+		URL:       req.URL,
+		Secret:    webhookSecretPrefix + secret,
+		Events:    req.Events,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.WebhookRepository.SaveWebhook(ctx, webhook); err != nil {
+		return NewWebhook{}, err
+	}
+
+	return NewWebhook{Webhook: webhook, Secret: webhook.Secret}, nil
+}
+
+func (s *Service) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	return s.WebhookRepository.GetWebhooks(ctx)
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id string) error {
+	return s.WebhookRepository.DeleteWebhook(ctx, id)
+}
+
+func (s *Service) ListWebhookDeliveries(ctx context.Context, req ListWebhookDeliveriesRequest) ([]WebhookDelivery, error) {
+	return s.WebhookRepository.GetWebhookDeliveries(ctx, req.Status, webhookDeliveriesLimit)
+}
+
+func (s *Service) ReplayWebhookDelivery(ctx context.Context, id string) (WebhookDelivery, error) {
+	delivery, err := s.WebhookRepository.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	if delivery.Status == WebhookDeliveryDelivered {
+		return WebhookDelivery{}, fmt.Errorf("%w: delivery has already succeeded", internal.ErrBadRequest)
+	}
+
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+
+	if err := s.WebhookRepository.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	return delivery, nil
+}
+
+func (s *Service) DeliverWebhooks(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := s.WebhookRepository.GetDueWebhookDeliveries(ctx, now, webhookDeliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	for _, d := range deliveries {
+		err := s.WebhookRepository.ClaimWebhookDelivery(ctx, d.ID, now, now.Add(webhookClaimDuration))
+		if errors.Is(err, internal.ErrResourceNotFound) {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("claiming webhook delivery %s: %v", d.ID, err)
+			continue
+		}
+
+		d.Attempts++
+
+		if err := s.deliverWebhook(ctx, d, now); err != nil {
+			d.LastError = err.Error()
+			if len(d.LastError) > webhookLastErrorMaxLength {
+				d.LastError = d.LastError[:webhookLastErrorMaxLength]
+			}
+
+			d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+			if d.Attempts >= webhookMaxAttempts {
+				d.Status = WebhookDeliveryDead
+			}
+		} else {
+			d.Status = WebhookDeliveryDelivered
+			d.LastError = ""
+			d.DeliveredAt = &now
+		}
+
+		// The claim expires on its own, so a delivery that can't be updated is retried later.
+		if err := s.WebhookRepository.UpdateWebhookDelivery(ctx, d); err != nil {
+			log.Printf("updating webhook delivery %s: %v", d.ID, err)
+			continue
+		}
+
+		if d.Status == WebhookDeliveryDelivered {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+func (s *Service) deliverWebhook(ctx context.Context, delivery WebhookDelivery, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, now, delivery.Payload))
+
+	resp, err := s.WebhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// notifyWebhooks queues event for the subscribed webhooks. It runs after the change it reports
+// has been saved, so a failure is logged instead of failing the request.
+func (s *Service) notifyWebhooks(ctx context.Context, event string, data interface{}) {
+	if err := s.queueWebhookDeliveries(ctx, event, data); err != nil {
+		log.Printf("queueing %s webhooks: %v", event, err)
+	}
+}
+
+func (s *Service) queueWebhookDeliveries(ctx context.Context, event string, data interface{}) error {
+	if s.WebhookRepository == nil {
+		return nil
+	}
+
+	webhooks, err := s.WebhookRepository.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	var subscribed []Webhook
+	for _, w := range webhooks {
+		if contains(w.Events, event) {
+			subscribed = append(subscribed, w)
+		}
+	}
+
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("creating webhook event: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	payload, err := json.Marshal(webhookPayload{ID: id.String(), Event: event, CreatedAt: now, Data: data})
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %v", err)
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(subscribed))
+	for _, w := range subscribed {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return fmt.Errorf("creating webhook delivery: %v", err)
+		}
+
+		deliveries = append(deliveries, WebhookDelivery{
+			ID:            id.String(),
+			WebhookID:     w.ID,
+			Event:         event,
+			Payload:       payload,
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	return s.WebhookRepository.SaveWebhookDeliveries(ctx, deliveries)
+}
+
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	_, _ = mac.Write(payload)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookInitialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+
+	return backoff
+}
@@ -0,0 +1,390 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRepository struct {
+	mock.Mock
+}
+
+func (r *webhookRepository) SaveWebhook(ctx context.Context, webhook Webhook) error {
+	return r.Called(webhook).Error(0)
+}
+
+func (r *webhookRepository) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	args := r.Called()
+	return args.Get(0).([]Webhook), args.Error(1)
+}
+
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	return r.Called(id).Error(0)
+}
+
+func (r *webhookRepository) SaveWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	return r.Called(deliveries).Error(0)
+}
+
+func (r *webhookRepository) GetWebhookDeliveries(ctx context.Context, status string, limit int) ([]WebhookDelivery, error) {
+	args := r.Called(status, limit)
+	return args.Get(0).([]WebhookDelivery), args.Error(1)
+}
+
+func (r *webhookRepository) GetWebhookDelivery(ctx context.Context, id string) (WebhookDelivery, error) {
+	args := r.Called(id)
+	return args.Get(0).(WebhookDelivery), args.Error(1)
+}
+
+func (r *webhookRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	args := r.Called(now, limit)
+	return args.Get(0).([]WebhookDelivery), args.Error(1)
+}
+
+func (r *webhookRepository) ClaimWebhookDelivery(ctx context.Context, id string, now time.Time, lockedUntil time.Time) error {
+	return r.Called(id, now, lockedUntil).Error(0)
+}
+
+func (r *webhookRepository) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	return r.Called(delivery).Error(0)
+}
+
+type webhookClient struct {
+	mock.Mock
+}
+
+func (c *webhookClient) Do(req *http.Request) (*http.Response, error) {
+	args := c.Called(req)
+	return args.Get(0).(*http.Response), args.Error(1)
+}
+
+func newWebhookResponse(statusCode int) *http.Response {
+	return &http.Response{StatusCode: statusCode, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestCreateWebhook(t *testing.T) {
+	// Given
+	req := CreateWebhookRequest{URL: "https://crm.example.com/hooks", Events: []string{WebhookEventUserRegistered}}
+
+	wr := &webhookRepository{}
+	wr.On("SaveWebhook", mock.AnythingOfType("Webhook")).Return(nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+
+	// When
+	resp, err := s.CreateWebhook(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	saved := wr.Calls[0].Arguments.Get(0).(Webhook)
+	require.True(t, strings.HasPrefix(resp.Secret, webhookSecretPrefix))
+	require.Equal(t, resp.Secret, saved.Secret)
+	require.Equal(t, req.URL, saved.URL)
+	require.Equal(t, req.Events, saved.Events)
+}
+
+func TestRegister_NotifyWebhooks(t *testing.T) {
+	// Given
+	u := RegisterRequest{Firstname: "mateo", Lastname: "ferrari", Email: "mateo.ferrari97@gmail.com", Password: "Password1!"}
+
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
+
+	wr := &webhookRepository{}
+	wr.On("GetWebhooks").Return([]Webhook{
+		{ID: "crm", Events: []string{WebhookEventUserRegistered, WebhookEventUserDeleted}},
+		{ID: "billing", Events: []string{WebhookEventUserDeleted}},
+	}, nil)
+	wr.On("SaveWebhookDeliveries", mock.AnythingOfType("[]internal.WebhookDelivery")).Return(nil)
+
+	s := NewService(r, nil, testConfig)
+	s.WebhookRepository = wr
+
+	// When
+	err := s.Register(context.Background(), Origin{}, u)
+
+	// Then
+	deliveries := wr.Calls[1].Arguments.Get(0).([]WebhookDelivery)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, "crm", deliveries[0].WebhookID)
+	require.Equal(t, WebhookDeliveryPending, deliveries[0].Status)
+
+	var payload struct {
+		Event string `json:"event"`
+		Data  User   `json:"data"`
+	}
+
+	_ = json.Unmarshal(deliveries[0].Payload, &payload)
+	require.Equal(t, WebhookEventUserRegistered, payload.Event)
+	require.Equal(t, u.Email, payload.Data.Email)
+}
+
+func TestDeleteUser_NotifyWebhooksError(t *testing.T) {
+	// Given
+	r := &repository{}
+	r.On("DeleteUser", "id").Return(nil)
+
+	or := &organizationRepository{}
+	or.On("GetUserOrganizations", "id").Return([]UserOrganization{}, nil)
+
+	wr := &webhookRepository{}
+	wr.On("GetWebhooks").Return([]Webhook{{ID: "billing", Events: []string{WebhookEventUserDeleted}}}, nil)
+	wr.On("SaveWebhookDeliveries", mock.AnythingOfType("[]internal.WebhookDelivery")).Return(errors.New("db error"))
+
+	s := NewService(r, nil, testConfig)
+	s.OrganizationRepository = or
+	s.WebhookRepository = wr
+
+	// When
+	err := s.DeleteUser(context.Background(), "id")
+
+	// Then
+	require.NoError(t, err)
+	wr.AssertExpectations(t)
+}
+
+func TestRegister_NotifyWebhooksError(t *testing.T) {
+	// Given
+	u := RegisterRequest{Firstname: "mateo", Lastname: "ferrari", Email: "mateo.ferrari97@gmail.com", Password: "Password1!"}
+
+	r := &repository{}
+	r.On("SaveUser", mock.AnythingOfType("NewUser")).Return(nil)
+
+	wr := &webhookRepository{}
+	wr.On("GetWebhooks").Return([]Webhook{}, errors.New("db error"))
+
+	s := NewService(r, nil, testConfig)
+	s.WebhookRepository = wr
+
+	// When
+	err := s.Register(context.Background(), Origin{}, u)
+
+	// Then
+	require.NoError(t, err)
+	r.AssertExpectations(t)
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	// Given
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	delivery := WebhookDelivery{ID: "delivery", Event: WebhookEventUserRegistered, Payload: []byte(`{}`), Status: WebhookDeliveryPending, URL: "https://crm.example.com/hooks", Secret: "secret"}
+
+	wr := &webhookRepository{}
+	wr.On("GetDueWebhookDeliveries", now, webhookDeliveryBatch).Return([]WebhookDelivery{delivery}, nil)
+	wr.On("ClaimWebhookDelivery", "delivery", now, now.Add(webhookClaimDuration)).Return(nil)
+	wr.On("UpdateWebhookDelivery", mock.AnythingOfType("WebhookDelivery")).Return(nil)
+
+	c := &webhookClient{}
+	c.On("Do", mock.AnythingOfType("*http.Request")).Return(newWebhookResponse(http.StatusNoContent), nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+	s.WebhookClient = c
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// When
+	resp, err := s.DeliverWebhooks(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	req := c.Calls[0].Arguments.Get(0).(*http.Request)
+	updated := wr.Calls[2].Arguments.Get(0).(WebhookDelivery)
+	require.Equal(t, 1, resp)
+	require.Equal(t, ctx, req.Context())
+	require.Equal(t, SignWebhookPayload("secret", now, []byte(`{}`)), req.Header.Get(WebhookSignatureHeader))
+	require.Equal(t, "delivery", req.Header.Get(WebhookDeliveryHeader))
+	require.Equal(t, WebhookDeliveryDelivered, updated.Status)
+	require.Equal(t, 1, updated.Attempts)
+	require.Equal(t, &now, updated.DeliveredAt)
+}
+
+func TestDeliverWebhooks_Failure(t *testing.T) {
+	tests := []struct {
+		name             string
+		attempts         int
+		expectedStatus   string
+		expectedAttempts int
+		expectedNext     time.Duration
+	}{
+		{
+			name:             "first failure",
+			attempts:         0,
+			expectedStatus:   WebhookDeliveryPending,
+			expectedAttempts: 1,
+			expectedNext:     webhookInitialBackoff,
+		},
+		{
+			name:             "third failure",
+			attempts:         2,
+			expectedStatus:   WebhookDeliveryPending,
+			expectedAttempts: 3,
+			expectedNext:     4 * webhookInitialBackoff,
+		},
+		{
+			name:             "last failure",
+			attempts:         webhookMaxAttempts - 1,
+			expectedStatus:   WebhookDeliveryDead,
+			expectedAttempts: webhookMaxAttempts,
+			expectedNext:     webhookMaxBackoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			delivery := WebhookDelivery{ID: "delivery", Status: WebhookDeliveryPending, Attempts: tt.attempts, URL: "https://crm.example.com/hooks"}
+
+			wr := &webhookRepository{}
+			wr.On("GetDueWebhookDeliveries", now, webhookDeliveryBatch).Return([]WebhookDelivery{delivery}, nil)
+			wr.On("ClaimWebhookDelivery", "delivery", now, now.Add(webhookClaimDuration)).Return(nil)
+			wr.On("UpdateWebhookDelivery", mock.AnythingOfType("WebhookDelivery")).Return(nil)
+
+			c := &webhookClient{}
+			c.On("Do", mock.AnythingOfType("*http.Request")).Return(newWebhookResponse(http.StatusInternalServerError), nil)
+
+			s := NewService(&repository{}, nil, testConfig)
+			s.WebhookRepository = wr
+			s.WebhookClient = c
+
+			// When
+			resp, err := s.DeliverWebhooks(context.Background(), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Then
+			updated := wr.Calls[2].Arguments.Get(0).(WebhookDelivery)
+			require.Equal(t, 0, resp)
+			require.Equal(t, tt.expectedStatus, updated.Status)
+			require.Equal(t, tt.expectedAttempts, updated.Attempts)
+			require.Equal(t, now.Add(tt.expectedNext), updated.NextAttemptAt)
+			require.Equal(t, "unexpected status code 500", updated.LastError)
+		})
+	}
+}
+
+func TestDeliverWebhooks_AlreadyClaimed(t *testing.T) {
+	// Given
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	delivery := WebhookDelivery{ID: "delivery", Status: WebhookDeliveryPending, URL: "https://crm.example.com/hooks"}
+
+	wr := &webhookRepository{}
+	wr.On("GetDueWebhookDeliveries", now, webhookDeliveryBatch).Return([]WebhookDelivery{delivery}, nil)
+	wr.On("ClaimWebhookDelivery", "delivery", now, now.Add(webhookClaimDuration)).
+		Return(fmt.Errorf("%w: db not found", internal.ErrResourceNotFound))
+
+	c := &webhookClient{}
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+	s.WebhookClient = c
+
+	// When
+	resp, err := s.DeliverWebhooks(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 0, resp)
+	c.AssertNotCalled(t, "Do", mock.Anything)
+	wr.AssertNotCalled(t, "UpdateWebhookDelivery", mock.Anything)
+}
+
+func TestDeliverWebhooks_UpdateErrorContinues(t *testing.T) {
+	// Given
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := WebhookDelivery{ID: "first", Status: WebhookDeliveryPending, URL: "https://crm.example.com/hooks"}
+	second := WebhookDelivery{ID: "second", Status: WebhookDeliveryPending, URL: "https://crm.example.com/hooks"}
+
+	wr := &webhookRepository{}
+	wr.On("GetDueWebhookDeliveries", now, webhookDeliveryBatch).Return([]WebhookDelivery{first, second}, nil)
+	wr.On("ClaimWebhookDelivery", mock.Anything, now, now.Add(webhookClaimDuration)).Return(nil)
+	wr.On("UpdateWebhookDelivery", mock.MatchedBy(func(d WebhookDelivery) bool { return d.ID == "first" })).Return(errors.New("db error"))
+	wr.On("UpdateWebhookDelivery", mock.MatchedBy(func(d WebhookDelivery) bool { return d.ID == "second" })).Return(nil)
+
+	c := &webhookClient{}
+	c.On("Do", mock.AnythingOfType("*http.Request")).Return(newWebhookResponse(http.StatusNoContent), nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+	s.WebhookClient = c
+
+	// When
+	resp, err := s.DeliverWebhooks(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, 1, resp)
+	c.AssertNumberOfCalls(t, "Do", 2)
+	wr.AssertNumberOfCalls(t, "UpdateWebhookDelivery", 2)
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	// Given
+	wr := &webhookRepository{}
+	wr.On("GetWebhookDelivery", "delivery").Return(WebhookDelivery{ID: "delivery", Status: WebhookDeliveryDead, Attempts: webhookMaxAttempts, LastError: "timeout"}, nil)
+	wr.On("UpdateWebhookDelivery", mock.AnythingOfType("WebhookDelivery")).Return(nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+
+	// When
+	resp, err := s.ReplayWebhookDelivery(context.Background(), "delivery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, WebhookDeliveryPending, resp.Status)
+	require.Equal(t, 0, resp.Attempts)
+	require.Empty(t, resp.LastError)
+}
+
+func TestReplayWebhookDelivery_AlreadyDeliveredError(t *testing.T) {
+	// Given
+	wr := &webhookRepository{}
+	wr.On("GetWebhookDelivery", "delivery").Return(WebhookDelivery{ID: "delivery", Status: WebhookDeliveryDelivered}, nil)
+
+	s := NewService(&repository{}, nil, testConfig)
+	s.WebhookRepository = wr
+
+	// When
+	_, err := s.ReplayWebhookDelivery(context.Background(), "delivery")
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrBadRequest))
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// Given
+	timestamp := time.Unix(1577836800, 0)
+
+	// When
+	resp := SignWebhookPayload("secret", timestamp, []byte(`{"event":"user.registered"}`))
+
+	// Then
+	require.Equal(t, "t=1577836800,v1=eafc087e8be5d709e920ebf237c04e84aedbdd2fe3da5135d98933b2e1dd7c6a", resp)
+}
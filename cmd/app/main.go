@@ -1,56 +1,359 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/mateoferrari97/auth/cmd/app/internal"
 	"github.com/mateoferrari97/auth/cmd/app/internal/client"
+	"github.com/mateoferrari97/auth/cmd/app/migrations"
 	"github.com/mateoferrari97/auth/cmd/server"
+	"github.com/mateoferrari97/auth/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
+	run := run
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify-audit":
+			run = verifyAudit
+		case "migrate":
+			run = migrate
+		}
+	}
+
 	if err := run(); err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 }
 
 func run() error {
-	server := server.NewServer()
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+
+	srv := server.NewServer(cfg.Server)
+	if err := srv.EnableTracing(cfg.Tracing); err != nil {
+		return err
+	}
 
-	repository, err := newUserRepository()
+	db, err := newDB(cfg.Database)
 	if err != nil {
 		return err
 	}
 
-	client := client.NewClient(http.DefaultClient)
-	service := internal.NewService(repository, client)
-	handler := internal.NewHandler(server)
+	if cfg.Database.AutoMigrate || sqlDriver(cfg.Database) == config.DatabaseDriverSQLite {
+		if err := migrateUp(db, cfg.Database); err != nil {
+			return err
+		}
+	}
+
+	srv.AddReadinessCheck("database", db.PingContext)
+
+	metrics := internal.NewMetrics(srv.Metrics)
+	srv.Metrics.MustRegister(internal.NewDBStatsCollector(db.DB))
+
+	httpClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 10 * time.Second}
+	client := client.NewClient(httpClient)
+	if cfg.Google.DiscoveryURL != "" {
+		srv.AddReadinessCheck("google", server.NewHTTPCheck(httpClient, cfg.Google.DiscoveryURL))
+	}
+	repositoryDB := internal.NewDB(db, cfg.Database.QueryTimeout)
+	repositoryDB.Metrics = metrics
+	service := internal.NewService(newUserRepository(cfg.Database, repositoryDB), client, cfg)
+	service.Metrics = metrics
+	service.HTTPClient = httpClient
+	service.DeviceCodeRepository = internal.NewDeviceCodeRepository(repositoryDB)
+	service.PersonalAccessTokenRepository = internal.NewPersonalAccessTokenRepository(repositoryDB)
+	service.RoleRepository = internal.NewRoleRepository(repositoryDB)
+	service.OrganizationRepository = internal.NewOrganizationRepository(repositoryDB)
+	service.AuditRepository = internal.NewAuditRepository(repositoryDB)
+	service.SessionRepository = internal.NewSessionRepository(repositoryDB)
+	service.WebhookRepository = internal.NewWebhookRepository(repositoryDB)
+	service.WebhookClient = httpClient
+	service.SignupInvitationRepository = internal.NewSignupInvitationRepository(repositoryDB)
+	trustedProxies, err := cfg.Server.TrustedProxyNetworks()
+	if err != nil {
+		return err
+	}
+
+	srv.Authorizer = server.NewClientCertificateAuthorizer(
+		cfg.Server.TLS.ClientPermissions,
+		internal.NewAuthorizer(service.AuthorizeWithRoles, trustedProxies),
+	)
+	handler := internal.NewHandler(srv)
+	handler.TrustedProxies = trustedProxies
 
 	handler.Ping()
-	handler.RouteMe(service.Authorize)
+	srv.RouteMetrics()
+	srv.RouteHealth()
+	handler.RouteMe(service.AuthorizeWithRoles)
+	handler.RouteUpdateMe(service.UpdateMe)
+	handler.RouteExportMe(service.ExportMe)
+	handler.RouteDeleteMe(service.DeleteMe)
+	handler.RouteCancelDeleteMe(service.CancelDeleteMe)
+	handler.RouteResetPassword(service.ResetPassword)
+	handler.RouteListSessions(service.ListSessions)
+	handler.RouteRevokeSession(service.RevokeSession)
+	handler.RouteRevokeOtherSessions(service.RevokeOtherSessions)
 	handler.RouteRegister(service.Register)
 	handler.RouteLoginWithGoogle(service.LoginWithGoogle)
 	handler.RouteLoginWithGoogleCallback(service.LoginWithGoogleCallback)
-	handler.RouteLogout()
+	handler.RouteLogout(service.Logout)
+	handler.RouteDeviceAuthorization(service.AuthorizeDevice)
+	handler.RouteToken(service.Token)
+	handler.RouteDevice()
+	handler.RouteVerifyDevice(service.VerifyDevice)
+	handler.RouteCreatePersonalAccessToken(service.CreatePersonalAccessToken)
+	handler.RouteListPersonalAccessTokens(service.ListPersonalAccessTokens)
+	handler.RouteGetPersonalAccessToken(service.GetPersonalAccessToken)
+	handler.RouteUpdatePersonalAccessToken(service.UpdatePersonalAccessToken)
+	handler.RouteDeletePersonalAccessToken(service.DeletePersonalAccessToken)
+	handler.RouteListRoles(service.ListRoles)
+	handler.RouteGetRole(service.GetRole)
+	handler.RouteCreateRole(service.CreateRole)
+	handler.RouteUpdateRolePermissions(service.UpdateRolePermissions)
+	handler.RouteDeleteRole(service.DeleteRole)
+	handler.RouteListPermissions(service.ListPermissions)
+	handler.RouteCreatePermission(service.CreatePermission)
+	handler.RouteListUserRoles(service.ListUserRoles)
+	handler.RouteAssignRole(service.AssignRole)
+	handler.RouteUnassignRole(service.UnassignRole)
+	handler.RouteListUsers(service.ListUsers)
+	handler.RouteGetUser(service.GetUser)
+	handler.RouteDisableUser(service.DisableUser)
+	handler.RouteEnableUser(service.EnableUser)
+	handler.RouteForcePasswordReset(service.ForcePasswordReset)
+	handler.RouteDeleteUser(service.DeleteUser)
+	handler.RouteImpersonateUser(service.ImpersonateUser)
+	handler.RouteStopImpersonation(service.StopImpersonation)
+	handler.RouteCreateSignupInvitation(service.CreateSignupInvitation)
+	handler.RouteListSignupInvitations(service.ListSignupInvitations)
+	handler.RouteRevokeSignupInvitation(service.RevokeSignupInvitation)
+	handler.RouteListAuditEvents(service.ListAuditEvents)
+	handler.RouteCreateWebhook(service.CreateWebhook)
+	handler.RouteListWebhooks(service.ListWebhooks)
+	handler.RouteDeleteWebhook(service.DeleteWebhook)
+	handler.RouteListWebhookDeliveries(service.ListWebhookDeliveries)
+	handler.RouteReplayWebhookDelivery(service.ReplayWebhookDelivery)
+	handler.RouteCreateOrganization(service.CreateOrganization)
+	handler.RouteListOrganizations(service.ListOrganizations)
+	handler.RouteListMembers(service.ListMembers)
+	handler.RouteUpdateMember(service.UpdateMember)
+	handler.RouteRemoveMember(service.RemoveMember)
+	handler.RouteInviteMember(service.InviteMember)
+	handler.RouteAcceptInvitation(service.AcceptInvitation)
+	handler.RouteSwitchOrganization(service.SwitchOrganization)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go purgeDeletedUsers(ctx, &wg, service, time.Hour)
+	go deliverWebhooks(ctx, &wg, service, 10*time.Second)
 
-	return server.Run(":8081")
+	// The background jobs use the database, so they have to stop before it's closed.
+	srv.OnShutdown(func() error {
+		cancel()
+		wg.Wait()
+
+		return db.Close()
+	})
+
+	return srv.Run()
 }
 
-func newUserRepository() (internal.Repository, error) {
-	dbSettings := fmt.Sprintf("%s:%s@tcp(db:3306)/%s",
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_NAME"),
-	)
+func verifyAudit() error {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+
+	db, err := newDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	// Verification reads the whole chain in one go, so it isn't bound by the per-query timeout.
+	repositoryDB := internal.NewDB(db, 0)
+	service := internal.NewService(internal.NewUserRepository(repositoryDB), nil, cfg)
+	service.AuditRepository = internal.NewAuditRepository(repositoryDB)
+
+	verification, err := service.VerifyAuditLog(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if !verification.Valid {
+		return fmt.Errorf("audit log is broken at seq %d: %s", verification.BrokenSeq, verification.Reason)
+	}
+
+	log.Printf("audit log is valid. %d events verified", verification.Verified)
+
+	return nil
+}
+
+// migrate runs "migrate up", "migrate down [steps]" or "migrate version".
+func migrate() error {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+
+	db, err := newDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
 
-	db, err := sqlx.Connect("mysql", dbSettings)
+	migrator, err := migrations.New(db, sqlDriver(cfg.Database))
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(os.Args) > 2 {
+		command = os.Args[2]
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		return migrateUp(db, cfg.Database)
+	case "down":
+		steps := 1
+		if len(os.Args) > 3 {
+			if steps, err = strconv.Atoi(os.Args[3]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", os.Args[3])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("reverted migration %04d_%s", m.Version, m.Name)
+		}
+
+		return err
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("schema version %d", version)
+
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or version", command)
+	}
+}
+
+func migrateUp(db *sqlx.DB, cfg config.Database) error {
+	migrator, err := migrations.New(db, sqlDriver(cfg))
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	return err
+}
+
+func purgeDeletedUsers(ctx context.Context, wg *sync.WaitGroup, service *internal.Service, interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := service.PurgeDeletedUsers(ctx, time.Now())
+		if err != nil {
+			log.Printf("purging deleted users: %v", err)
+		}
+
+		if purged > 0 {
+			log.Printf("purged %d deleted users", purged)
+		}
+	}
+}
+
+func deliverWebhooks(ctx context.Context, wg *sync.WaitGroup, service *internal.Service, interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		delivered, err := service.DeliverWebhooks(ctx, time.Now())
+		if err != nil {
+			log.Printf("delivering webhooks: %v", err)
+		}
+
+		if delivered > 0 {
+			log.Printf("delivered %d webhooks", delivered)
+		}
+	}
+}
+
+func newDB(cfg config.Database) (*sqlx.DB, error) {
+	db, err := sqlx.Connect(sqlDriver(cfg), cfg.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("instantiating db: %v", err)
 	}
 
-	return internal.NewUserRepository(db), nil
+	if sqlDriver(cfg) == config.DatabaseDriverSQLite {
+		// SQLite allows a single writer, and every connection to :memory: is a separate database.
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+// sqlDriver is the database/sql driver behind cfg. The memory driver only keeps users in
+// memory and needs SQLite for everything else.
+func sqlDriver(cfg config.Database) string {
+	if cfg.Driver == config.DatabaseDriverMemory {
+		return config.DatabaseDriverSQLite
+	}
+
+	return cfg.Driver
+}
+
+func newUserRepository(cfg config.Database, db *internal.DB) internal.Repository {
+	switch cfg.Driver {
+	case config.DatabaseDriverPostgres:
+		return internal.NewPostgresUserRepository(db)
+	case config.DatabaseDriverSQLite:
+		return internal.NewSQLiteUserRepository(db)
+	case config.DatabaseDriverMemory:
+		return internal.NewMemoryUserRepository(db)
+	default:
+		return internal.NewUserRepository(db)
+	}
 }
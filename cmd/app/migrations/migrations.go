@@ -0,0 +1,319 @@
+// Package migrations versions the database schema. Every driver has its own directory of
+// NNNN_name.up.sql and NNNN_name.down.sql files, embedded in the binary and applied in order.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed mysql postgres sqlite
+var files embed.FS
+
+const (
+	lockName = "auth_schema_migrations"
+	// lockKey is the postgres advisory lock id, any constant shared by every replica works.
+	lockKey = 7_411_233_581
+	// lockTimeout bounds how long a replica waits for another one to finish migrating.
+	lockTimeout = 5 * time.Minute
+)
+
+const createVersionTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    bigint    not null primary key,
+    applied_at timestamp not null
+)`
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	driver     string
+	migrations []Migration
+}
+
+// New returns a Migrator for the schema of driver, which is mysql, postgres or sqlite.
+func New(db *sqlx.DB, driver string) (*Migrator, error) {
+	migrations, err := load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		driver:     driver,
+		migrations: migrations,
+	}, nil
+}
+
+func load(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("unknown migrations driver %q", driver)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(name, "."+direction+".sql"), "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		b, err := fs.ReadFile(files, path.Join(driver, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %v", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every migration newer than the current version and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration.up, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, m.db.Rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), migration.Version, time.Now().UTC())
+				return err
+			}); err != nil {
+				return fmt.Errorf("applying migration %04d_%s: %v", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the steps most recent migrations and returns the reverted ones.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > current {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration.down, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, m.db.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("reverting migration %04d_%s: %v", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Version returns the latest applied migration, 0 when there is none.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, createVersionTable); err != nil {
+		return 0, fmt.Errorf("creating schema_migrations: %v", err)
+	}
+
+	return version(ctx, conn)
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int, error) {
+	var v sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("getting schema version: %v", err)
+	}
+
+	return int(v.Int64), nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// apply runs script and record in a transaction. MySQL commits implicitly after every DDL
+// statement, so a failed MySQL migration may be partially applied and has to be fixed by hand.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, record func(tx execer) error) (err error) {
+	if m.driver == "mysql" {
+		for _, statement := range splitStatements(script) {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+
+		return record(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beggining tx: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback() // nolint
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if err = record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements splits script on semicolons ending a line, since the MySQL driver runs one
+// statement per query.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if statement := strings.TrimSpace(current.String()); statement != ";" {
+				statements = append(statements, statement)
+			}
+
+			current.Reset()
+		}
+	}
+
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+
+	return statements
+}
+
+// locked runs fn on a single connection holding the migrations lock, so only one replica
+// migrates at a time. SQLite has no session locks; it's meant for a single process anyway.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	switch m.driver {
+	case "mysql":
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, int(lockTimeout.Seconds())).Scan(&acquired); err != nil {
+			return fmt.Errorf("acquiring migrations lock: %v", err)
+		}
+
+		if acquired.Int64 != 1 {
+			return errors.New("acquiring migrations lock: timed out")
+		}
+
+		defer func() {
+			if _, unlockErr := conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName); unlockErr != nil && err == nil {
+				err = fmt.Errorf("releasing migrations lock: %v", unlockErr)
+			}
+		}()
+	case "postgres":
+		lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+
+		if _, err := conn.ExecContext(lockCtx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+			return fmt.Errorf("acquiring migrations lock: %v", err)
+		}
+
+		defer func() {
+			if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); unlockErr != nil && err == nil {
+				err = fmt.Errorf("releasing migrations lock: %v", unlockErr)
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, createVersionTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %v", err)
+	}
+
+	return fn(conn)
+}
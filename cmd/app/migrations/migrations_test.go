@@ -0,0 +1,135 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestNew(t *testing.T) {
+	for _, driver := range []string{"mysql", "postgres", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			// When
+			m, err := New(nil, driver)
+
+			// Then
+			require.NoError(t, err)
+			require.NotEmpty(t, m.migrations)
+			require.Equal(t, 1, m.migrations[0].Version)
+			require.Equal(t, "init", m.migrations[0].Name)
+		})
+	}
+}
+
+func TestNew_UnknownDriverError(t *testing.T) {
+	// When
+	_, err := New(nil, "oracle")
+
+	// Then
+	require.EqualError(t, err, `unknown migrations driver "oracle"`)
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+
+	reapplied, err := m.Up(ctx)
+	require.NoError(t, err)
+
+	upVersion, err := m.Version(ctx)
+	require.NoError(t, err)
+
+	reverted, err := m.Down(ctx, len(m.migrations))
+	require.NoError(t, err)
+
+	downVersion, err := m.Version(ctx)
+	require.NoError(t, err)
+
+	var tables int
+	require.NoError(t, db.Get(&tables, `SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = 'login'`))
+
+	// Then
+	require.Len(t, applied, len(m.migrations))
+	require.Empty(t, reapplied)
+	require.Equal(t, len(m.migrations), upVersion)
+	require.Len(t, reverted, len(m.migrations))
+	require.Equal(t, 0, downVersion)
+	require.Equal(t, 0, tables)
+}
+
+func TestMigrator_UpFromBaselineSchema(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	baseline, err := files.ReadFile("sqlite/0001_init.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.MustExec(string(baseline))
+	db.MustExec(`INSERT INTO user (_id, firstname, lastname) VALUES ('1', 'mateo', 'ferrari')`)
+
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	applied, err := m.Up(ctx)
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, applied, len(m.migrations))
+
+	var status string
+	require.NoError(t, db.Get(&status, `SELECT status FROM user WHERE _id = '1'`))
+	require.Equal(t, "active", status)
+}
+
+func TestSplitStatements(t *testing.T) {
+	// Given
+	script := `CREATE TABLE a
+(
+    id int
+);
+
+CREATE TRIGGER b BEFORE DELETE ON a
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'no; really';
+DROP TABLE c`
+
+	// When
+	resp := splitStatements(script)
+
+	// Then
+	require.Equal(t, []string{
+		"CREATE TABLE a\n(\n    id int\n);",
+		"CREATE TRIGGER b BEFORE DELETE ON a\n    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'no; really';",
+		"DROP TABLE c",
+	}, resp)
+}
@@ -16,4 +16,4 @@ CREATE TABLE IF NOT EXISTS login
     user_id      bigint  not null,
     constraint login_user_id_fk
         foreign key (user_id) references user (id)
-);
\ No newline at end of file
+);
@@ -0,0 +1,6 @@
+DROP INDEX user__id_idx ON user;
+
+ALTER TABLE user
+    DROP COLUMN delete_after,
+    DROP COLUMN password_reset_required,
+    DROP COLUMN status;
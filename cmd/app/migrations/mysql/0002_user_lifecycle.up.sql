@@ -0,0 +1,6 @@
+ALTER TABLE user
+    ADD COLUMN status varchar(16) default 'active' not null AFTER lastname,
+    ADD COLUMN password_reset_required boolean default false not null AFTER status,
+    ADD COLUMN delete_after datetime(3) null AFTER password_reset_required;
+
+CREATE UNIQUE INDEX user__id_idx ON user (_id);
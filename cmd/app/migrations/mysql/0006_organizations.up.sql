@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS organization
+(
+    id         varchar(64)  primary key,
+    name       varchar(128) not null,
+    created_by varchar(128) not null,
+    created_at datetime(3)  not null
+);
+
+CREATE TABLE IF NOT EXISTS organization_member
+(
+    organization_id varchar(64)  not null,
+    user_id         varchar(128) not null,
+    role            varchar(16)  not null,
+    joined_at       datetime(3)  not null,
+    primary key (organization_id, user_id),
+    index organization_member_user_id_idx (user_id),
+    constraint organization_member_organization_id_fk
+        foreign key (organization_id) references organization (id)
+);
+
+CREATE TABLE IF NOT EXISTS organization_invitation
+(
+    id              varchar(64)  primary key,
+    organization_id varchar(64)  not null,
+    email           varchar(256) not null,
+    role            varchar(16)  not null,
+    token_hash      varchar(128) not null unique,
+    invited_by      varchar(128) not null,
+    expires_at      datetime(3)  not null,
+    accepted_at     datetime(3)  null,
+    created_at      datetime(3)  not null,
+    constraint organization_invitation_organization_id_fk
+        foreign key (organization_id) references organization (id)
+);
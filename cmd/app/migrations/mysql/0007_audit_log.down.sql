@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS audit_event_append_only_update;
+DROP TRIGGER IF EXISTS audit_event_append_only_delete;
+DROP TABLE IF EXISTS audit_event;
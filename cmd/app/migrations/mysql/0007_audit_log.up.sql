@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS audit_event
+(
+    seq           bigint auto_increment primary key,
+    id            varchar(64)  not null unique,
+    actor         varchar(256) not null,
+    action        varchar(64)  not null,
+    target        varchar(256) not null,
+    ip            varchar(64)  not null,
+    user_agent    varchar(512) not null,
+    outcome       varchar(16)  not null,
+    created_at    datetime(3)  not null,
+    previous_hash varchar(64)  not null,
+    hash          varchar(64)  not null,
+    index audit_event_actor_idx (actor),
+    index audit_event_target_idx (target),
+    index audit_event_created_at_idx (created_at)
+);
+
+DROP TRIGGER IF EXISTS audit_event_append_only_update;
+CREATE TRIGGER audit_event_append_only_update BEFORE UPDATE ON audit_event
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_event is append-only';
+
+DROP TRIGGER IF EXISTS audit_event_append_only_delete;
+CREATE TRIGGER audit_event_append_only_delete BEFORE DELETE ON audit_event
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_event is append-only';
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS webhook
+(
+    id         varchar(64)  primary key,
+    url        varchar(512) not null,
+    secret     varchar(128) not null,
+    events     varchar(256) not null,
+    created_at datetime(3)  not null
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery
+(
+    id              varchar(64)  primary key,
+    webhook_id      varchar(64)  not null,
+    event           varchar(64)  not null,
+    payload         text         not null,
+    status          varchar(16)  not null,
+    attempts        int          not null default 0,
+    next_attempt_at datetime(3)  not null,
+    last_error      varchar(512) not null default '',
+    created_at      datetime(3)  not null,
+    delivered_at    datetime(3)  null,
+    index webhook_delivery_status_next_attempt_at_idx (status, next_attempt_at),
+    constraint webhook_delivery_webhook_id_fk
+        foreign key (webhook_id) references webhook (id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS signup_invitation
+(
+    id         varchar(64)  primary key,
+    email      varchar(256) not null,
+    roles      varchar(512) not null,
+    token_hash varchar(128) not null unique,
+    expires_at datetime(3)  not null,
+    used_at    datetime(3)  null,
+    used_by    varchar(128) null,
+    created_at datetime(3)  not null
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS audit_chain_head
+(
+    id   int         primary key,
+    hash varchar(64) not null
+);
+
+INSERT INTO audit_chain_head (id, hash)
+SELECT 1, COALESCE((SELECT hash FROM audit_event ORDER BY seq DESC LIMIT 1), '');
@@ -0,0 +1 @@
+ALTER TABLE webhook_delivery ADD COLUMN locked_until datetime(3) null;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id           bigserial primary key,
+    _id          varchar(128) not null,
+    firstname    varchar(128) not null,
+    lastname     varchar(128) not null,
+    created_at   timestamptz(3) default CURRENT_TIMESTAMP(3) not null,
+    updated_at   timestamptz(3) default CURRENT_TIMESTAMP(3) not null
+);
+
+CREATE TABLE IF NOT EXISTS login
+(
+    id           bigserial primary key,
+    email        varchar(128) not null unique,
+    password     varchar(128) not null,
+    user_id      bigint not null,
+    constraint login_user_id_fk
+        foreign key (user_id) references users (id)
+);
@@ -0,0 +1,6 @@
+DROP INDEX users__id_idx;
+
+ALTER TABLE users
+    DROP COLUMN delete_after,
+    DROP COLUMN password_reset_required,
+    DROP COLUMN status;
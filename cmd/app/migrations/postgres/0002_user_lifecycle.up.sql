@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN status varchar(16) default 'active' not null,
+    ADD COLUMN password_reset_required boolean default false not null,
+    ADD COLUMN delete_after timestamptz(3) null;
+
+CREATE UNIQUE INDEX users__id_idx ON users (_id);
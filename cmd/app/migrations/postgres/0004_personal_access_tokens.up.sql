@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS personal_access_token
+(
+    id           varchar(128)  primary key,
+    user_id      varchar(128)  not null,
+    name         varchar(128)  not null,
+    token_prefix varchar(32)   not null,
+    token_hash   varchar(128)  not null unique,
+    scopes       varchar(1024) not null,
+    expires_at   timestamptz(3) null,
+    last_used_at timestamptz(3) null,
+    created_at   timestamptz(3) not null
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_token_user_id_idx ON personal_access_token (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS organization_invitation;
+DROP TABLE IF EXISTS organization_member;
+DROP TABLE IF EXISTS organization;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS organization
+(
+    id         varchar(64)  primary key,
+    name       varchar(128) not null,
+    created_by varchar(128) not null,
+    created_at timestamptz(3) not null
+);
+
+CREATE TABLE IF NOT EXISTS organization_member
+(
+    organization_id varchar(64)  not null,
+    user_id         varchar(128) not null,
+    role            varchar(16)  not null,
+    joined_at       timestamptz(3) not null,
+    primary key (organization_id, user_id),
+    constraint organization_member_organization_id_fk
+        foreign key (organization_id) references organization (id)
+);
+
+CREATE INDEX IF NOT EXISTS organization_member_user_id_idx ON organization_member (user_id);
+
+CREATE TABLE IF NOT EXISTS organization_invitation
+(
+    id              varchar(64)  primary key,
+    organization_id varchar(64)  not null,
+    email           varchar(256) not null,
+    role            varchar(16)  not null,
+    token_hash      varchar(128) not null unique,
+    invited_by      varchar(128) not null,
+    expires_at      timestamptz(3) not null,
+    accepted_at     timestamptz(3) null,
+    created_at      timestamptz(3) not null,
+    constraint organization_invitation_organization_id_fk
+        foreign key (organization_id) references organization (id)
+);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS audit_event
+(
+    seq           bigserial primary key,
+    id            varchar(64)  not null unique,
+    actor         varchar(256) not null,
+    action        varchar(64)  not null,
+    target        varchar(256) not null,
+    ip            varchar(64)  not null,
+    user_agent    varchar(512) not null,
+    outcome       varchar(16)  not null,
+    created_at    timestamptz(3) not null,
+    previous_hash varchar(64)  not null,
+    hash          varchar(64)  not null
+);
+
+CREATE INDEX IF NOT EXISTS audit_event_actor_idx ON audit_event (actor);
+CREATE INDEX IF NOT EXISTS audit_event_target_idx ON audit_event (target);
+CREATE INDEX IF NOT EXISTS audit_event_created_at_idx ON audit_event (created_at);
+
+CREATE OR REPLACE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_event_append_only ON audit_event;
+CREATE TRIGGER audit_event_append_only BEFORE UPDATE OR DELETE ON audit_event
+    FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_session
+(
+    id              varchar(64)  primary key,
+    user_id         varchar(128) not null,
+    user_agent      varchar(512) not null,
+    ip              varchar(64)  not null,
+    impersonator_id varchar(128) not null default '',
+    created_at      timestamptz(3) not null,
+    last_seen_at    timestamptz(3) not null,
+    expires_at      timestamptz(3) not null,
+    revoked_at      timestamptz(3) null
+);
+
+CREATE INDEX IF NOT EXISTS user_session_user_id_idx ON user_session (user_id);
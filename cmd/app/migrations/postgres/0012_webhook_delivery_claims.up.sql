@@ -0,0 +1 @@
+ALTER TABLE webhook_delivery ADD COLUMN locked_until timestamptz(3) null;
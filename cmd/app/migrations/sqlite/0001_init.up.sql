@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user
+(
+    id           integer primary key autoincrement,
+    _id          varchar(128) not null,
+    firstname    varchar(128) not null,
+    lastname     varchar(128) not null,
+    created_at   datetime default (strftime('%Y-%m-%d %H:%M:%f', 'now')) not null,
+    updated_at   datetime default (strftime('%Y-%m-%d %H:%M:%f', 'now')) not null
+);
+
+CREATE TABLE IF NOT EXISTS login
+(
+    id           integer primary key autoincrement,
+    email        varchar(128) not null unique,
+    password     varchar(128) not null,
+    user_id      bigint not null references user (id)
+);
@@ -0,0 +1,5 @@
+DROP INDEX user__id_idx;
+
+ALTER TABLE user DROP COLUMN delete_after;
+ALTER TABLE user DROP COLUMN password_reset_required;
+ALTER TABLE user DROP COLUMN status;
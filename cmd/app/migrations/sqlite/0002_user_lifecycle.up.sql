@@ -0,0 +1,5 @@
+ALTER TABLE user ADD COLUMN status varchar(16) default 'active' not null;
+ALTER TABLE user ADD COLUMN password_reset_required boolean default false not null;
+ALTER TABLE user ADD COLUMN delete_after datetime null;
+
+CREATE UNIQUE INDEX user__id_idx ON user (_id);
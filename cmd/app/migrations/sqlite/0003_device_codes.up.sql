@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS device_code
+(
+    device_code    varchar(128) primary key,
+    user_code      varchar(16)  not null unique,
+    client_id      varchar(128) not null,
+    scope          varchar(512) not null,
+    status         varchar(16)  not null,
+    user_id        varchar(128) not null default '',
+    poll_interval  int          not null,
+    expires_at     datetime     not null,
+    last_polled_at datetime     null
+);
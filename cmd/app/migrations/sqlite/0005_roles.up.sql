@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS role
+(
+    name         varchar(64)  primary key,
+    description  varchar(256) not null
+);
+
+CREATE TABLE IF NOT EXISTS permission
+(
+    name         varchar(64)  primary key,
+    description  varchar(256) not null
+);
+
+CREATE TABLE IF NOT EXISTS role_permission
+(
+    role_name       varchar(64) not null references role (name),
+    permission_name varchar(64) not null references permission (name),
+    primary key (role_name, permission_name)
+);
+
+CREATE TABLE IF NOT EXISTS user_role
+(
+    user_id      varchar(128) not null,
+    role_name    varchar(64)  not null references role (name),
+    primary key (user_id, role_name)
+);
+
+INSERT OR IGNORE INTO permission (name, description) VALUES
+    ('roles:read', 'List roles, permissions and role assignments'),
+    ('roles:write', 'Manage roles, permissions and role assignments'),
+    ('users:read', 'List and inspect user accounts'),
+    ('users:write', 'Disable, enable, reset and delete user accounts'),
+    ('users:impersonate', 'Act as another user with a short-lived token'),
+    ('audit:read', 'Query the audit log'),
+    ('webhooks:read', 'List webhook subscriptions and deliveries'),
+    ('webhooks:write', 'Manage webhook subscriptions and replay deliveries');
+
+INSERT OR IGNORE INTO role (name, description) VALUES ('admin', 'Full administrative access');
+
+INSERT OR IGNORE INTO role_permission (role_name, permission_name) VALUES
+    ('admin', 'roles:read'),
+    ('admin', 'roles:write'),
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('admin', 'users:impersonate'),
+    ('admin', 'audit:read'),
+    ('admin', 'webhooks:read'),
+    ('admin', 'webhooks:write');
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS organization
+(
+    id         varchar(64)  primary key,
+    name       varchar(128) not null,
+    created_by varchar(128) not null,
+    created_at datetime     not null
+);
+
+CREATE TABLE IF NOT EXISTS organization_member
+(
+    organization_id varchar(64)  not null references organization (id),
+    user_id         varchar(128) not null,
+    role            varchar(16)  not null,
+    joined_at       datetime     not null,
+    primary key (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS organization_member_user_id_idx ON organization_member (user_id);
+
+CREATE TABLE IF NOT EXISTS organization_invitation
+(
+    id              varchar(64)  primary key,
+    organization_id varchar(64)  not null references organization (id),
+    email           varchar(256) not null,
+    role            varchar(16)  not null,
+    token_hash      varchar(128) not null unique,
+    invited_by      varchar(128) not null,
+    expires_at      datetime     not null,
+    accepted_at     datetime     null,
+    created_at      datetime     not null
+);
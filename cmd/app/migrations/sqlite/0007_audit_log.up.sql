@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS audit_event
+(
+    seq           integer primary key autoincrement,
+    id            varchar(64)  not null unique,
+    actor         varchar(256) not null,
+    action        varchar(64)  not null,
+    target        varchar(256) not null,
+    ip            varchar(64)  not null,
+    user_agent    varchar(512) not null,
+    outcome       varchar(16)  not null,
+    created_at    datetime     not null,
+    previous_hash varchar(64)  not null,
+    hash          varchar(64)  not null
+);
+
+CREATE INDEX IF NOT EXISTS audit_event_actor_idx ON audit_event (actor);
+CREATE INDEX IF NOT EXISTS audit_event_target_idx ON audit_event (target);
+CREATE INDEX IF NOT EXISTS audit_event_created_at_idx ON audit_event (created_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_event_append_only_update BEFORE UPDATE ON audit_event
+BEGIN
+    SELECT RAISE(ABORT, 'audit_event is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_event_append_only_delete BEFORE DELETE ON audit_event
+BEGIN
+    SELECT RAISE(ABORT, 'audit_event is append-only');
+END;
@@ -7,7 +7,7 @@ import (
 	"github.com/mateoferrari97/auth/internal"
 )
 
-func handleError(w http.ResponseWriter, err error) {
+func handleError(w http.ResponseWriter, requestID string, err error) {
 	message := err.Error()
 
 	var e *internal.Error
@@ -22,13 +22,23 @@ func handleError(w http.ResponseWriter, err error) {
 		e = internal.NewError(message, http.StatusNotFound)
 	case internal.ErrInvalidToken:
 		e = internal.NewError(message, http.StatusForbidden)
+	case internal.ErrRevokedSession:
+		e = internal.NewError(message, http.StatusUnauthorized)
 	case internal.ErrAlteredTokenClaims:
 		e = internal.NewError(message, http.StatusForbidden)
+	case internal.ErrForbidden:
+		e = internal.NewError(message, http.StatusForbidden)
 	case internal.ErrResourceAlreadyExists:
 		e = internal.NewError(message, http.StatusConflict)
+	case internal.ErrPreconditionFailed:
+		e = internal.NewError(message, http.StatusPreconditionFailed)
+	case internal.ErrPreconditionRequired:
+		e = internal.NewError(message, http.StatusPreconditionRequired)
 	default:
 		e = internal.NewError(message, http.StatusInternalServerError)
 	}
 
+	e.RequestID = requestID
+
 	_ = internal.RespondJSON(w, e, e.StatusCode)
 }
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+const (
+	getHealthz = "/healthz"
+	getReadyz  = "/readyz"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusError       = "error"
+	healthStatusUnavailable = "unavailable"
+	healthStatusDraining    = "draining"
+)
+
+type HealthCheck func(ctx context.Context) error
+
+type readinessCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// AddReadinessCheck registers a dependency that must be healthy for /readyz to succeed.
+func (s *Server) AddReadinessCheck(name string, check HealthCheck) {
+	s.readinessChecks = append(s.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+func (s *Server) RouteHealth() {
+	s.Router.HandleFunc(getHealthz, func(w http.ResponseWriter, r *http.Request) {
+		_ = internal.RespondJSON(w, HealthReport{Status: healthStatusOK}, http.StatusOK)
+	}).Methods(http.MethodGet)
+
+	s.Router.HandleFunc(getReadyz, func(w http.ResponseWriter, r *http.Request) {
+		report := s.readiness(r.Context())
+
+		status := http.StatusOK
+		if report.Status != healthStatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		_ = internal.RespondJSON(w, report, status)
+	}).Methods(http.MethodGet)
+}
+
+func (s *Server) readiness(ctx context.Context) HealthReport {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return HealthReport{Status: healthStatusDraining}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ReadinessTimeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(s.readinessChecks))
+
+	var wg sync.WaitGroup
+	for i, c := range s.readinessChecks {
+		wg.Add(1)
+		go func(i int, c readinessCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c.check)
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	report := HealthReport{Status: healthStatusOK, Checks: make(map[string]CheckResult, len(results))}
+	for i, c := range s.readinessChecks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != healthStatusOK {
+			report.Status = healthStatusUnavailable
+		}
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, check HealthCheck) CheckResult {
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    healthStatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = healthStatusError
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// NewHTTPCheck succeeds when url answers a GET with a 2xx status.
+func NewHTTPCheck(client *http.Client, url string) HealthCheck {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RouteHealth_Healthz(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{})
+	s.AddReadinessCheck("database", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	s.RouteHealth()
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	// When
+	resp, err := http.Get(fmt.Sprintf("%s/healthz", ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_RouteHealth_Readyz(t *testing.T) {
+	tt := []struct {
+		name           string
+		check          HealthCheck
+		draining       bool
+		expectedStatus int
+		expectedReport HealthReport
+	}{
+		{
+			name:           "ready",
+			check:          func(ctx context.Context) error { return nil },
+			expectedStatus: http.StatusOK,
+			expectedReport: HealthReport{Status: "ok", Checks: map[string]CheckResult{"database": {Status: "ok"}}},
+		},
+		{
+			name:           "failing check",
+			check:          func(ctx context.Context) error { return errors.New("connection refused") },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: HealthReport{Status: "unavailable", Checks: map[string]CheckResult{"database": {Status: "error", Error: "connection refused"}}},
+		},
+		{
+			name: "check times out",
+			check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: HealthReport{Status: "unavailable", Checks: map[string]CheckResult{"database": {Status: "error", Error: "context deadline exceeded"}}},
+		},
+		{
+			name:           "draining",
+			check:          func(ctx context.Context) error { return nil },
+			draining:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: HealthReport{Status: "draining"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			s := NewServer(config.Server{ReadinessTimeout: 50 * time.Millisecond})
+			s.AddReadinessCheck("database", tc.check)
+			s.RouteHealth()
+			if tc.draining {
+				atomic.StoreInt32(&s.draining, 1)
+			}
+
+			ts := httptest.NewServer(s.Router)
+			defer ts.Close()
+
+			// When
+			resp, err := http.Get(fmt.Sprintf("%s/readyz", ts.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer resp.Body.Close()
+
+			var report HealthReport
+			_ = json.NewDecoder(resp.Body).Decode(&report)
+			for name, result := range report.Checks {
+				result.LatencyMS = 0
+				report.Checks[name] = result
+			}
+
+			// Then
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+			require.Equal(t, tc.expectedReport, report)
+		})
+	}
+}
+
+func TestNewHTTPCheck(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// When
+	okErr := NewHTTPCheck(ts.Client(), ts.URL+"/.well-known/openid-configuration")(context.Background())
+	notFoundErr := NewHTTPCheck(ts.Client(), ts.URL+"/missing")(context.Background())
+
+	// Then
+	require.NoError(t, okErr)
+	require.EqualError(t, notFoundErr, "unexpected status code 404")
+}
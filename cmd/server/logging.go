@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type requestInfoKey struct{}
+
+// requestInfo is shared by the middlewares of a single request so inner ones can annotate the log line.
+type requestInfo struct {
+	id     string
+	userID string
+}
+
+// IdentifiedPrincipal is implemented by principals that can be attributed in request logs.
+type IdentifiedPrincipal interface {
+	PrincipalID() string
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return ""
+	}
+
+	return info.id
+}
+
+// SetRequestUser attributes the request log line to userID. Services call it once they have
+// authenticated the caller, so routes outside WrapWithPermissions are attributed too.
+func SetRequestUser(ctx context.Context, userID string) {
+	info, ok := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return
+	}
+
+	info.userID = userID
+}
+
+func setRequestUser(ctx context.Context, principal Principal) {
+	if p, ok := principal.(IdentifiedPrincipal); ok {
+		SetRequestUser(ctx, p.PrincipalID())
+	}
+}
+
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID.MatchString(id) {
+		return id
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+
+	return id.String()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) logRequest(r *http.Request, pattern string, info *requestInfo, status int, latency time.Duration, err error) {
+	fields := map[string]interface{}{
+		"msg":        "request",
+		"request_id": info.id,
+		"method":     r.Method,
+		"route":      pattern,
+		"status":     status,
+		"latency_ms": float64(latency.Microseconds()) / 1000,
+	}
+
+	if info.userID != "" {
+		fields["user_id"] = info.userID
+	}
+
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+
+	level := "info"
+	if err != nil && status >= http.StatusInternalServerError {
+		level = "error"
+		fields["error"] = err.Error()
+		fields["error_chain"] = errorChain(err)
+	}
+
+	s.log(level, fields)
+}
+
+func (s *Server) log(level string, fields map[string]interface{}) {
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	fields["level"] = level
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		s.logger.Printf(`{"level":"error","msg":"encoding log line: %v"}`, err)
+		return
+	}
+
+	s.logger.Print(string(b))
+}
+
+func errorChain(err error) []string {
+	var chain []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, err.Error())
+	}
+
+	return chain
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const getMetrics = "/metrics"
+
+type httpMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func newHTTPMetrics(registerer prometheus.Registerer) *httpMetrics {
+	m := &httpMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route pattern, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+	}
+
+	registerer.MustRegister(m.duration)
+
+	return m
+}
+
+func (m *httpMetrics) observe(route string, method string, status int, latency time.Duration) {
+	m.duration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(latency.Seconds())
+}
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return registry
+}
+
+// RouteMetrics exposes everything registered in s.Metrics in the Prometheus text format.
+func (s *Server) RouteMetrics() {
+	s.Router.Handle(getMetrics, promhttp.HandlerFor(s.Metrics, promhttp.HandlerOpts{})).Methods(http.MethodGet)
+}
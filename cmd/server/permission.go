@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mateoferrari97/auth/internal"
+)
+
+type principalKey struct{}
+
+type Principal interface {
+	HasPermission(permission string) bool
+}
+
+type Authorizer func(r *http.Request) (Principal, error)
+
+func (s *Server) WrapWithPermissions(method string, pattern string, permissions []string, handler HandlerFunc) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) error {
+		if s.Authorizer == nil {
+			return errors.New("authorizer is not configured")
+		}
+
+		principal, err := s.Authorizer(r)
+		if err != nil {
+			return err
+		}
+
+		for _, permission := range permissions {
+			if !principal.HasPermission(permission) {
+				return fmt.Errorf("%w: %s is required", internal.ErrForbidden, permission)
+			}
+		}
+
+		setRequestUser(r.Context(), principal)
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+
+		return handler(w, r.WithContext(ctx))
+	}
+
+	s.Wrap(method, pattern, wrapH)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
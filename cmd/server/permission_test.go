@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type principal []string
+
+func (p principal) HasPermission(permission string) bool {
+	for _, v := range p {
+		if v == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestServer_WrapWithPermissions(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{})
+	s.Authorizer = func(r *http.Request) (Principal, error) {
+		return principal{"roles:read"}, nil
+	}
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	var got Principal
+	s.WrapWithPermissions(http.MethodGet, "/admin/roles", []string{"roles:read"}, func(w http.ResponseWriter, r *http.Request) error {
+		got, _ = PrincipalFromContext(r.Context())
+		return nil
+	})
+
+	// When
+	resp, err := http.Get(fmt.Sprintf("%s/admin/roles", ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, principal{"roles:read"}, got)
+}
+
+func TestServer_WrapWithPermissions_Errors(t *testing.T) {
+	tt := []struct {
+		name         string
+		authorizer   Authorizer
+		expectedCode int
+	}{
+		{
+			name:         "missing authorizer",
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name: "invalid token",
+			authorizer: func(r *http.Request) (Principal, error) {
+				return nil, fmt.Errorf("%w: some error", internal.ErrInvalidToken)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "missing permission",
+			authorizer: func(r *http.Request) (Principal, error) {
+				return principal{"roles:read"}, nil
+			},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			s := NewServer(config.Server{})
+			s.Authorizer = tc.authorizer
+
+			ts := httptest.NewServer(s.Router)
+			defer ts.Close()
+
+			s.WrapWithPermissions(http.MethodPost, "/admin/roles", []string{"roles:write"}, func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("handler must not be called")
+			})
+
+			// When
+			resp, err := http.Post(fmt.Sprintf("%s/admin/roles", ts.URL), "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Then
+			require.Equal(t, tc.expectedCode, resp.StatusCode)
+		})
+	}
+}
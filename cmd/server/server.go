@@ -1,55 +1,169 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-const defaultPort = "8081"
-
 type Server struct {
-	Router *mux.Router
-}
+	Router     *mux.Router
+	Authorizer Authorizer
+	Metrics    *prometheus.Registry
+
+	cfg           config.Server
+	logger        *log.Logger
+	httpMetrics   *httpMetrics
+	shutdownHooks []func() error
 
-func NewServer() *Server {
-	return &Server{Router: mux.NewRouter()}
+	readinessChecks []readinessCheck
+	draining        int32
 }
 
-func (s *Server) Run(port string) error {
-	port = configPort(port)
+func NewServer(cfg config.Server) *Server {
+	registry := newRegistry()
 
-	log.Printf("Listening on port %s", port)
+	return &Server{
+		Router:      mux.NewRouter(),
+		Metrics:     registry,
+		cfg:         cfg,
+		logger:      log.New(os.Stdout, "", 0),
+		httpMetrics: newHTTPMetrics(registry),
+	}
+}
 
-	return http.ListenAndServe(fmt.Sprintf(":%s", port), s.Router)
+// OnShutdown registers a hook that runs once in-flight requests have been drained.
+func (s *Server) OnShutdown(hook func() error) {
+	s.shutdownHooks = append(s.shutdownHooks, hook)
 }
 
-type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+func (s *Server) Run() error {
+	l, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %v", s.cfg.Address, err)
+	}
 
-func (s *Server) Wrap(method string, pattern string, handler HandlerFunc) {
-	wrapH := func(w http.ResponseWriter, r *http.Request) {
-		err := handler(w, r)
-		if err == nil {
-			return
+	if s.cfg.TLS.Enabled() {
+		l, err = s.tlsListener(l)
+		if err != nil {
+			return err
 		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
 
-		handleError(w, err)
+	return s.serve(l, stop)
+}
+
+func (s *Server) tlsListener(l net.Listener) (net.Listener, error) {
+	certificates, err := newCertificateReloader(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+	if err != nil {
+		l.Close() // nolint
+		return nil, err
 	}
 
-	s.Router.HandleFunc(pattern, wrapH).Methods(method)
+	tlsConfig, err := newTLSConfig(s.cfg.TLS, certificates)
+	if err != nil {
+		l.Close() // nolint
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go certificates.watch(done)
+	s.OnShutdown(func() error {
+		close(done)
+		return nil
+	})
+
+	return tls.NewListener(l, tlsConfig), nil
 }
 
-func configPort(port string) string {
-	if port == "" {
-		port = defaultPort
-		log.Printf("Defaulting to port %s", port)
+func (s *Server) serve(l net.Listener, stop <-chan os.Signal) error {
+	srv := &http.Server{
+		Handler:           s.Router,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
 	}
 
-	if string(port[0]) == ":" {
-		port = port[1:]
+	serveErr := make(chan error, 1)
+	go func() {
+		s.log("info", map[string]interface{}{"msg": "listening", "address": l.Addr().String()})
+		serveErr <- srv.Serve(l)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case sig := <-stop:
+		s.log("info", map[string]interface{}{"msg": "draining connections", "signal": sig.String()})
+
+		atomic.StoreInt32(&s.draining, 1)
+		time.Sleep(s.cfg.DrainDelay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+		defer cancel()
+
+		err = srv.Shutdown(ctx)
+		if err != nil {
+			srv.Close() // nolint
+			err = fmt.Errorf("shutting down server: %v", err)
+		}
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	for _, hook := range s.shutdownHooks {
+		if hookErr := hook(); hookErr != nil && err == nil {
+			err = fmt.Errorf("running shutdown hook: %v", hookErr)
+		}
+	}
+
+	return err
+}
+
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (s *Server) Wrap(method string, pattern string, handler HandlerFunc) {
+	wrapH := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{id: requestID(r)}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		r, span := startSpan(r, method, pattern)
+
+		w.Header().Set(RequestIDHeader, info.id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		err := handler(rec, r)
+		if err != nil {
+			handleError(rec, info.id, err)
+		}
+
+		endSpan(span, rec.status, err)
+
+		latency := time.Since(start)
+		s.httpMetrics.observe(pattern, method, rec.status, latency)
+		s.logRequest(r, pattern, info, rec.status, latency, err)
 	}
 
-	return port
+	s.Router.HandleFunc(pattern, wrapH).Methods(method)
 }
@@ -1,20 +1,28 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
 func TestServer_Wrap(t *testing.T) {
 	// Given
-	s := NewServer()
+	s := NewServer(config.Server{})
 
 	ts := httptest.NewServer(s.Router)
 	defer ts.Close()
@@ -64,16 +72,36 @@ func TestServer_Wrap_HandleError(t *testing.T) {
 			err:          fmt.Errorf("%w: %v", internal.ErrInvalidToken, "some error"),
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			name:         "revoked session",
+			err:          fmt.Errorf("%w: %v", internal.ErrRevokedSession, "some error"),
+			expectedCode: http.StatusUnauthorized,
+		},
 		{
 			name:         "altered token",
 			err:          fmt.Errorf("%w: %v", internal.ErrAlteredTokenClaims, "some error"),
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			name:         "forbidden",
+			err:          fmt.Errorf("%w: %v", internal.ErrForbidden, "some error"),
+			expectedCode: http.StatusForbidden,
+		},
 		{
 			name:         "resource already exists",
 			err:          fmt.Errorf("%w: %v", internal.ErrResourceAlreadyExists, "some error"),
 			expectedCode: http.StatusConflict,
 		},
+		{
+			name:         "precondition failed",
+			err:          fmt.Errorf("%w: %v", internal.ErrPreconditionFailed, "some error"),
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "precondition required",
+			err:          fmt.Errorf("%w: %v", internal.ErrPreconditionRequired, "some error"),
+			expectedCode: http.StatusPreconditionRequired,
+		},
 		{
 			name:         "internal server error",
 			err:          errors.New("internal server error"),
@@ -84,7 +112,7 @@ func TestServer_Wrap_HandleError(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			// Given
-			s := NewServer()
+			s := NewServer(config.Server{})
 
 			ts := httptest.NewServer(s.Router)
 			defer ts.Close()
@@ -111,3 +139,237 @@ func TestServer_Wrap_HandleError(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_Serve_DrainsInFlightRequests(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{ShutdownTimeout: 5 * time.Second})
+
+	var closed bool
+	s.OnShutdown(func() error {
+		closed = true
+		return nil
+	})
+
+	started := make(chan struct{})
+	s.Wrap(http.MethodGet, "/slow", func(w http.ResponseWriter, r *http.Request) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- s.serve(l, stop) }()
+
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/slow", l.Addr()))
+		if err != nil {
+			t.Error(err)
+		}
+
+		respCh <- resp
+	}()
+
+	// When
+	<-started
+	stop <- syscall.SIGTERM
+	err = <-done
+
+	// Then
+	require.NoError(t, err)
+	require.True(t, closed)
+	resp := <-respCh
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_Serve_ShutdownHookError(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{ShutdownTimeout: time.Second})
+
+	var calls int
+	s.OnShutdown(func() error {
+		calls++
+		return errors.New("closing db")
+	})
+
+	s.OnShutdown(func() error {
+		calls++
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	stop <- syscall.SIGINT
+
+	// When
+	err = s.serve(l, stop)
+
+	// Then
+	require.EqualError(t, err, "running shutdown hook: closing db")
+	require.Equal(t, 2, calls)
+}
+
+type testUser struct {
+	id string
+}
+
+func (u testUser) HasPermission(permission string) bool {
+	return true
+}
+
+func (u testUser) PrincipalID() string {
+	return u.id
+}
+
+func TestServer_Wrap_LogsRequest(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	s := NewServer(config.Server{})
+	s.logger = log.New(&buf, "", 0)
+	s.Authorizer = func(r *http.Request) (Principal, error) {
+		return testUser{id: "user-id"}, nil
+	}
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	s.WrapWithPermissions(http.MethodGet, "/users/{id}", []string{"users:read"}, func(w http.ResponseWriter, r *http.Request) error {
+		require.Equal(t, "request-id", RequestIDFromContext(r.Context()))
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/123", ts.URL), nil)
+	req.Header.Set(RequestIDHeader, "request-id")
+
+	// When
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	_ = json.Unmarshal(buf.Bytes(), &line)
+
+	// Then
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "request-id", resp.Header.Get(RequestIDHeader))
+	require.Equal(t, "info", line["level"])
+	require.Equal(t, "request-id", line["request_id"])
+	require.Equal(t, http.MethodGet, line["method"])
+	require.Equal(t, "/users/{id}", line["route"])
+	require.Equal(t, float64(http.StatusAccepted), line["status"])
+	require.Equal(t, "user-id", line["user_id"])
+	require.Contains(t, line, "latency_ms")
+}
+
+func TestServer_Wrap_LogsRequestUser(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	s := NewServer(config.Server{})
+	s.logger = log.New(&buf, "", 0)
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	s.Wrap(http.MethodGet, "/users/me", func(w http.ResponseWriter, r *http.Request) error {
+		SetRequestUser(r.Context(), "user-id")
+		return nil
+	})
+
+	// When
+	resp, err := http.Get(fmt.Sprintf("%s/users/me", ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	_ = json.Unmarshal(buf.Bytes(), &line)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "user-id", line["user_id"])
+}
+
+func TestServer_Wrap_LogsInternalErrors(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	s := NewServer(config.Server{})
+	s.logger = log.New(&buf, "", 0)
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	s.Wrap(http.MethodGet, "/users/me", func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("getting user: %w", errors.New("connection refused"))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/me", ts.URL), nil)
+	req.Header.Set(RequestIDHeader, "invalid request id")
+
+	// When
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body internal.Error
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+
+	var line map[string]interface{}
+	_ = json.Unmarshal(buf.Bytes(), &line)
+
+	// Then
+	requestID := resp.Header.Get(RequestIDHeader)
+	require.NotEmpty(t, requestID)
+	require.NotEqual(t, "invalid request id", requestID)
+	require.Equal(t, requestID, body.RequestID)
+	require.Equal(t, "error", line["level"])
+	require.Equal(t, requestID, line["request_id"])
+	require.Equal(t, float64(http.StatusInternalServerError), line["status"])
+	require.Equal(t, "getting user: connection refused", line["error"])
+	require.Equal(t, []interface{}{"getting user: connection refused", "connection refused"}, line["error_chain"])
+}
+
+func TestServer_RouteMetrics(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{})
+	s.logger = log.New(io.Discard, "", 0)
+	s.RouteMetrics()
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	s.Wrap(http.MethodGet, "/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("%w: db not found", internal.ErrResourceNotFound)
+	})
+
+	_, _ = http.Get(fmt.Sprintf("%s/users/123", ts.URL))
+
+	// When
+	resp, err := http.Get(fmt.Sprintf("%s/metrics", ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(b), `http_request_duration_seconds_count{method="GET",route="/users/{id}",status="404"} 1`)
+	require.Contains(t, string(b), "go_goroutines")
+}
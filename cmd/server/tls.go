@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
+)
+
+const certificateCheckInterval = 30 * time.Second
+
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certificateReloader) reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %v", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *certificateReloader) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("reading certificate: %v", err)
+		}
+
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+func (r *certificateReloader) changed() bool {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return !modTime.Equal(r.modTime)
+}
+
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// watch reloads the certificate on SIGHUP or when the files change on disk,
+// keeping the previous one if the new pair can't be loaded.
+func (r *certificateReloader) watch(done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		}
+
+		if err := r.reload(); err != nil {
+			log.Printf("reloading certificate: %v", err)
+			continue
+		}
+
+		log.Printf("reloaded certificate from %s", r.certFile)
+	}
+}
+
+func newTLSConfig(cfg config.TLS, certificates *certificateReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certificates.GetCertificate,
+	}
+
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	b, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client ca: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("client ca doesn't contain any certificate")
+	}
+
+	// Browsers and CLIs authenticate with tokens, so a client certificate is only verified when one
+	// is presented. NewClientCertificateAuthorizer decides what it grants.
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return tlsConfig, nil
+}
+
+// ClientCertificate returns the leaf of the verified client certificate chain, if any.
+func ClientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+type ServiceClient struct {
+	Name        string
+	Permissions []string
+}
+
+func (c ServiceClient) PrincipalID() string {
+	return c.Name
+}
+
+func (c ServiceClient) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewClientCertificateAuthorizer authenticates callers presenting a verified client certificate
+// by its common name, and falls back to next for callers without one. A certificate whose common
+// name has no configured permissions is rejected.
+func NewClientCertificateAuthorizer(clients map[string][]string, next Authorizer) Authorizer {
+	return func(r *http.Request) (Principal, error) {
+		cert, ok := ClientCertificate(r)
+		if !ok {
+			return next(r)
+		}
+
+		permissions, ok := clients[cert.Subject.CommonName]
+		if !ok {
+			return nil, fmt.Errorf("%w: client certificate %s isn't allowed", internal.ErrForbidden, cert.Subject.CommonName)
+		}
+
+		return ServiceClient{Name: cert.Subject.CommonName, Permissions: permissions}, nil
+	}
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mateoferrari97/auth/internal"
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCertificate{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c testCertificate) keyPEM(t *testing.T) []byte {
+	b, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
+}
+
+func (c testCertificate) write(t *testing.T, dir string, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	if err := os.WriteFile(certFile, c.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, c.keyPEM(t), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func (c testCertificate) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func TestServer_Serve_MutualTLS(t *testing.T) {
+	// Given
+	dir := tempDir(t)
+	ca := newTestCertificate(t, "ca", nil)
+	certFile, keyFile := newTestCertificate(t, "auth", &ca).write(t, dir, "server")
+	caFile, _ := ca.write(t, dir, "ca")
+	client := newTestCertificate(t, "billing", &ca)
+
+	cfg := config.TLS{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}
+	s := NewServer(config.Server{ShutdownTimeout: time.Second, TLS: cfg})
+	s.Authorizer = NewClientCertificateAuthorizer(map[string][]string{"billing": {"users:read"}}, func(r *http.Request) (Principal, error) {
+		return nil, fmt.Errorf("%w: authorization header is required", internal.ErrInvalidToken)
+	})
+	s.WrapWithPermissions(http.MethodGet, "/whoami", []string{"users:read"}, func(w http.ResponseWriter, r *http.Request) error {
+		principal, _ := PrincipalFromContext(r.Context())
+
+		_, err := w.Write([]byte(principal.(ServiceClient).Name))
+		return err
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = s.tlsListener(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- s.serve(l, stop) }()
+
+	defer func() {
+		stop <- os.Interrupt
+		<-done
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{client.tlsCertificate(t)},
+	}}}
+
+	// When
+	resp, err := httpClient.Get(fmt.Sprintf("https://%s/whoami", l.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+
+	// Then
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "billing", string(b))
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	anonymousResp, err := anonymous.Get(fmt.Sprintf("https://%s/whoami", l.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer anonymousResp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, anonymousResp.StatusCode)
+}
+
+func TestCertificateReloader_Reload(t *testing.T) {
+	// Given
+	dir := tempDir(t)
+	ca := newTestCertificate(t, "ca", nil)
+	certFile, keyFile := newTestCertificate(t, "old", &ca).write(t, dir, "server")
+
+	r, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestCertificate(t, "new", &ca).write(t, dir, "server")
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+
+	// When
+	changed := r.changed()
+	err = r.reload()
+
+	// Then
+	require.True(t, changed)
+	require.NoError(t, err)
+
+	cert, _ := r.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	require.Equal(t, "new", leaf.Subject.CommonName)
+	require.False(t, r.changed())
+}
+
+func TestCertificateReloader_Reload_KeepsCertificateOnError(t *testing.T) {
+	// Given
+	dir := tempDir(t)
+	ca := newTestCertificate(t, "ca", nil)
+	certFile, keyFile := newTestCertificate(t, "old", &ca).write(t, dir, "server")
+
+	r, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.WriteFile(certFile, []byte("broken"), 0600)
+
+	// When
+	err = r.reload()
+
+	// Then
+	require.Error(t, err)
+
+	cert, _ := r.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	require.Equal(t, "old", leaf.Subject.CommonName)
+}
+
+type testPrincipal struct{}
+
+func (testPrincipal) HasPermission(permission string) bool {
+	return false
+}
+
+func TestNewClientCertificateAuthorizer(t *testing.T) {
+	// Given
+	ca := newTestCertificate(t, "ca", nil)
+	client := newTestCertificate(t, "billing", &ca)
+	next := func(r *http.Request) (Principal, error) {
+		return testPrincipal{}, nil
+	}
+
+	authorizer := NewClientCertificateAuthorizer(map[string][]string{"billing": {"users:read"}}, next)
+
+	r, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client.cert, ca.cert}}}
+
+	// When
+	resp, err := authorizer(r)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, ServiceClient{Name: "billing", Permissions: []string{"users:read"}}, resp)
+	require.True(t, resp.HasPermission("users:read"))
+}
+
+func TestNewClientCertificateAuthorizer_FallsBack(t *testing.T) {
+	// Given
+	next := func(r *http.Request) (Principal, error) {
+		return testPrincipal{}, nil
+	}
+
+	authorizer := NewClientCertificateAuthorizer(map[string][]string{"billing": {"users:read"}}, next)
+
+	withoutCert, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	withoutCert.TLS = &tls.ConnectionState{}
+
+	// When
+	resp, err := authorizer(withoutCert)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, testPrincipal{}, resp)
+}
+
+func TestNewClientCertificateAuthorizer_UnknownCertificateError(t *testing.T) {
+	// Given
+	ca := newTestCertificate(t, "ca", nil)
+	gateway := newTestCertificate(t, "gateway", &ca)
+	next := func(r *http.Request) (Principal, error) {
+		return testPrincipal{}, nil
+	}
+
+	authorizer := NewClientCertificateAuthorizer(map[string][]string{"billing": {"users:read"}}, next)
+
+	r, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{gateway.cert, ca.cert}}}
+
+	// When
+	_, err := authorizer(r)
+
+	// Then
+	require.True(t, errors.Is(err, internal.ErrForbidden))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/mateoferrari97/auth/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/mateoferrari97/auth/cmd/server"
+
+// EnableTracing installs a global tracer provider exporting to the configured backend and
+// propagates W3C trace context. Without an exporter spans are created but never recorded.
+func (s *Server) EnableTracing(cfg config.Tracing) error {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if cfg.Exporter == config.TracingExporterNone {
+		return nil
+	}
+
+	exporter, err := newSpanExporter(cfg)
+	if err != nil {
+		return err
+	}
+
+	resource := sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	s.OnShutdown(func() error {
+		return provider.Shutdown(context.Background())
+	})
+
+	return nil
+}
+
+func newSpanExporter(cfg config.Tracing) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.TracingExporterStdout:
+		exporter, err := stdout.NewExporter(stdout.WithWriter(os.Stderr), stdout.WithoutMetricExport())
+		if err != nil {
+			return nil, fmt.Errorf("creating stdout exporter: %v", err)
+		}
+
+		return exporter, nil
+	case config.TracingExporterOTLP:
+		opts := []otlphttp.Option{otlphttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+
+		exporter, err := otlp.NewExporter(context.Background(), otlphttp.NewDriver(opts...))
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp exporter: %v", err)
+		}
+
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+}
+
+func startSpan(r *http.Request, method string, pattern string) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, method+" "+pattern,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", pattern, r)...),
+	)
+
+	return r.WithContext(ctx), span
+}
+
+func endSpan(span trace.Span, status int, err error) {
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	if err != nil && status >= http.StatusInternalServerError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
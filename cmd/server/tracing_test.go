@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateoferrari97/auth/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracerProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return exporter
+}
+
+func TestServer_Wrap_PropagatesTraceContext(t *testing.T) {
+	// Given
+	exporter := newTestTracerProvider(t)
+
+	var buf bytes.Buffer
+	s := NewServer(config.Server{})
+	s.logger = log.New(&buf, "", 0)
+
+	ts := httptest.NewServer(s.Router)
+	defer ts.Close()
+
+	var traceID trace.TraceID
+	s.Wrap(http.MethodGet, "/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		traceID = trace.SpanContextFromContext(r.Context()).TraceID()
+		return errors.New("connection refused")
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users/123", ts.URL), nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	// When
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	_ = json.Unmarshal(buf.Bytes(), &line)
+
+	// Then
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", traceID.String())
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", line["trace_id"])
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /users/{id}", spans[0].Name)
+	require.Equal(t, "b7ad6b7169203331", spans[0].Parent.SpanID().String())
+	require.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	require.Equal(t, codes.Error, spans[0].StatusCode)
+}
+
+func TestServer_EnableTracing_UnknownExporterError(t *testing.T) {
+	// Given
+	s := NewServer(config.Server{})
+
+	// When
+	err := s.EnableTracing(config.Tracing{Exporter: "jaeger"})
+
+	// Then
+	require.EqualError(t, err, `unknown tracing exporter "jaeger"`)
+}
@@ -0,0 +1,384 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+var signupModes = []string{"open", "invite_only", "disabled"}
+
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	Auth     Auth     `yaml:"auth"`
+	Google   Google   `yaml:"google"`
+	Tracing  Tracing  `yaml:"tracing"`
+}
+
+type Server struct {
+	Address string `yaml:"address"`
+	// PublicURL is the scheme and host users reach the server on, used to build links such as
+	// the device verification page.
+	PublicURL         string        `yaml:"public_url"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	DrainDelay        time.Duration `yaml:"drain_delay"`
+	ReadinessTimeout  time.Duration `yaml:"readiness_timeout"`
+	// TrustedProxies lists the addresses or CIDR ranges whose X-Forwarded-For header is believed
+	// when attributing a request to a client. Requests from anywhere else are attributed to their
+	// remote address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	TLS            TLS      `yaml:"tls"`
+}
+
+// TrustedProxyNetworks parses TrustedProxies, treating a bare address as a single host range.
+func (s Server) TrustedProxyNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(s.TrustedProxies))
+	for _, proxy := range s.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+type TLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientPermissions grants permissions to callers authenticated by the common name of their client certificate.
+	ClientPermissions map[string][]string `yaml:"client_permissions"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+const (
+	DatabaseDriverMySQL    = "mysql"
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+	// DatabaseDriverMemory keeps users in memory and everything else in an in-memory SQLite database.
+	DatabaseDriverMemory = "memory"
+)
+
+var databaseDrivers = []string{DatabaseDriverMySQL, DatabaseDriverPostgres, DatabaseDriverSQLite, DatabaseDriverMemory}
+
+type Database struct {
+	Driver string `yaml:"driver"`
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	// Name is the file path when Driver is sqlite, which ignores the rest of the connection fields.
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// SSLMode is only used by postgres.
+	SSLMode string `yaml:"ssl_mode"`
+	// AutoMigrate applies pending migrations at startup. SQLite and memory databases are
+	// always migrated.
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// QueryTimeout bounds every user repository call. Zero disables it.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+func (d Database) DSN() string {
+	switch d.Driver {
+	case DatabaseDriverSQLite:
+		return "file:" + d.Name + "?_pragma=foreign_keys(1)&_time_format=sqlite&_pragma=busy_timeout(5000)"
+	case DatabaseDriverMemory:
+		return "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite"
+	case DatabaseDriverPostgres:
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(d.User, d.Password),
+			Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+			Path:     d.Name,
+			RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+		}
+
+		return u.String()
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", d.User, d.Password, d.Host, d.Port, d.Name)
+	}
+}
+
+type Auth struct {
+	SigningKey string `yaml:"signing_key"`
+	BcryptCost int    `yaml:"bcrypt_cost"`
+	SignupMode string `yaml:"signup_mode"`
+}
+
+type Google struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+	// DiscoveryURL, when set, is checked by the readiness endpoint.
+	DiscoveryURL string `yaml:"discovery_url"`
+	// Timeout bounds the token exchange and the userinfo request of a login. Zero disables it.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+const (
+	TracingExporterNone   = ""
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Address:           ":8081",
+			PublicURL:         "http://localhost:8081",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   15 * time.Second,
+			ReadinessTimeout:  2 * time.Second,
+		},
+		Database: Database{
+			Driver:       DatabaseDriverMySQL,
+			Host:         "db",
+			Port:         3306,
+			SSLMode:      "disable",
+			QueryTimeout: 5 * time.Second,
+		},
+		Auth: Auth{
+			BcryptCost: bcrypt.DefaultCost,
+			SignupMode: "open",
+		},
+		Google: Google{
+			RedirectURL: "http://localhost:8081/login/google/callback",
+			Timeout:     10 * time.Second,
+		},
+		Tracing: Tracing{
+			ServiceName: "auth",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load reads the configuration from path, when given, and applies the environment on top of it.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %v", err)
+		}
+
+		if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parsing config file: %v", err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(lookup func(key string) (string, bool)) error {
+	stringVars := map[string]*string{
+		"SERVER_ADDRESS":       &c.Server.Address,
+		"PUBLIC_URL":           &c.Server.PublicURL,
+		"TLS_CERT_FILE":        &c.Server.TLS.CertFile,
+		"TLS_KEY_FILE":         &c.Server.TLS.KeyFile,
+		"TLS_CLIENT_CA_FILE":   &c.Server.TLS.ClientCAFile,
+		"DATABASE_DRIVER":      &c.Database.Driver,
+		"DATABASE_HOST":        &c.Database.Host,
+		"DATABASE_NAME":        &c.Database.Name,
+		"DATABASE_USER":        &c.Database.User,
+		"DATABASE_PASSWORD":    &c.Database.Password,
+		"PRIVATE_KEY":          &c.Auth.SigningKey,
+		"SIGNUP_MODE":          &c.Auth.SignupMode,
+		"GOOGLE_CLIENT_ID":     &c.Google.ClientID,
+		"GOOGLE_CLIENT_SECRET": &c.Google.ClientSecret,
+		"GOOGLE_REDIRECT_URL":  &c.Google.RedirectURL,
+		"TRACING_EXPORTER":     &c.Tracing.Exporter,
+		"TRACING_ENDPOINT":     &c.Tracing.Endpoint,
+	}
+
+	for key, field := range stringVars {
+		if value, ok := lookup(key); ok && value != "" {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"DATABASE_PORT": &c.Database.Port,
+		"BCRYPT_COST":   &c.Auth.BcryptCost,
+	}
+
+	for key, field := range intVars {
+		value, ok := lookup(key)
+		if !ok || value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", key, err)
+		}
+
+		*field = n
+	}
+
+	boolVars := map[string]*bool{
+		"DATABASE_AUTO_MIGRATE": &c.Database.AutoMigrate,
+	}
+
+	for key, field := range boolVars {
+		value, ok := lookup(key)
+		if !ok || value == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", key, err)
+		}
+
+		*field = b
+	}
+
+	return nil
+}
+
+func (c Config) Validate() error {
+	if c.Server.Address == "" {
+		return errors.New("server address is required")
+	}
+
+	u, err := url.Parse(c.Server.PublicURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid server public url %q", c.Server.PublicURL)
+	}
+
+	timeouts := []time.Duration{
+		c.Server.ReadTimeout,
+		c.Server.ReadHeaderTimeout,
+		c.Server.WriteTimeout,
+		c.Server.IdleTimeout,
+		c.Server.ShutdownTimeout,
+		c.Server.DrainDelay,
+		c.Server.ReadinessTimeout,
+	}
+
+	for _, timeout := range timeouts {
+		if timeout < 0 {
+			return errors.New("server timeouts can't be negative")
+		}
+	}
+
+	if _, err := c.Server.TrustedProxyNetworks(); err != nil {
+		return err
+	}
+
+	if c.Server.MaxHeaderBytes < 0 {
+		return errors.New("server max header bytes can't be negative")
+	}
+
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return errors.New("tls cert file and key file must be set together")
+	}
+
+	if c.Server.TLS.ClientCAFile != "" && !c.Server.TLS.Enabled() {
+		return errors.New("tls client ca file requires a cert file and key file")
+	}
+
+	if !contains(databaseDrivers, c.Database.Driver) {
+		return fmt.Errorf("invalid database driver %q", c.Database.Driver)
+	}
+
+	if c.Database.Driver == DatabaseDriverSQLite && c.Database.Name == "" {
+		return errors.New("database name is required for sqlite")
+	}
+
+	if c.Database.QueryTimeout < 0 {
+		return errors.New("database query timeout can't be negative")
+	}
+
+	if c.Auth.SigningKey == "" {
+		return errors.New("signing key is required")
+	}
+
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if !contains(signupModes, c.Auth.SignupMode) {
+		return fmt.Errorf("invalid signup mode %q", c.Auth.SignupMode)
+	}
+
+	if c.Google.Timeout < 0 {
+		return errors.New("google timeout can't be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if c.Tracing.Endpoint == "" {
+			return errors.New("tracing endpoint is required for the otlp exporter")
+		}
+	default:
+		return fmt.Errorf("invalid tracing exporter %q", c.Tracing.Exporter)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return errors.New("tracing sample ratio must be between 0 and 1")
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := os.CreateTemp("", "config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	return f.Name()
+}
+
+func TestLoad(t *testing.T) {
+	// Given
+	path := writeConfigFile(t, `
+server:
+  address: ":9090"
+  public_url: "https://auth.example.com"
+  shutdown_timeout: 30s
+database:
+  host: localhost
+  name: auth
+  query_timeout: 2s
+auth:
+  signing_key: secret
+  bcrypt_cost: 12
+  signup_mode: invite_only
+`)
+
+	// When
+	resp, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	require.Equal(t, ":9090", resp.Server.Address)
+	require.Equal(t, "https://auth.example.com", resp.Server.PublicURL)
+	require.Equal(t, 30*time.Second, resp.Server.ShutdownTimeout)
+	require.Equal(t, 5*time.Second, resp.Server.ReadHeaderTimeout)
+	require.Equal(t, "localhost", resp.Database.Host)
+	require.Equal(t, 3306, resp.Database.Port)
+	require.Equal(t, 2*time.Second, resp.Database.QueryTimeout)
+	require.Equal(t, 10*time.Second, resp.Google.Timeout)
+	require.Equal(t, "secret", resp.Auth.SigningKey)
+	require.Equal(t, 12, resp.Auth.BcryptCost)
+	require.Equal(t, "invite_only", resp.Auth.SignupMode)
+}
+
+func TestLoad_UnknownFieldError(t *testing.T) {
+	// Given
+	path := writeConfigFile(t, `
+auth:
+  signing_kye: secret
+`)
+
+	// When
+	_, err := Load(path)
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parsing config file")
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	// Given
+	cfg := Default()
+	env := map[string]string{
+		"PRIVATE_KEY":           "secret",
+		"DATABASE_HOST":         "localhost",
+		"DATABASE_PORT":         "3307",
+		"BCRYPT_COST":           "4",
+		"SIGNUP_MODE":           "",
+		"DATABASE_AUTO_MIGRATE": "true",
+	}
+
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	// When
+	err := cfg.applyEnv(lookup)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, "secret", cfg.Auth.SigningKey)
+	require.Equal(t, "localhost", cfg.Database.Host)
+	require.Equal(t, 3307, cfg.Database.Port)
+	require.Equal(t, bcrypt.MinCost, cfg.Auth.BcryptCost)
+	require.Equal(t, "open", cfg.Auth.SignupMode)
+	require.True(t, cfg.Database.AutoMigrate)
+}
+
+func TestConfig_ApplyEnv_InvalidNumberError(t *testing.T) {
+	// Given
+	cfg := Default()
+	lookup := func(key string) (string, bool) {
+		if key == "DATABASE_PORT" {
+			return "mysql", true
+		}
+
+		return "", false
+	}
+
+	// When
+	err := cfg.applyEnv(lookup)
+
+	// Then
+	require.EqualError(t, err, `parsing DATABASE_PORT: strconv.Atoi: parsing "mysql": invalid syntax`)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name        string
+		update      func(cfg *Config)
+		expectedErr string
+	}{
+		{
+			name:        "empty signing key",
+			update:      func(cfg *Config) { cfg.Auth.SigningKey = "" },
+			expectedErr: "signing key is required",
+		},
+		{
+			name:        "empty address",
+			update:      func(cfg *Config) { cfg.Server.Address = "" },
+			expectedErr: "server address is required",
+		},
+		{
+			name:        "public url without scheme",
+			update:      func(cfg *Config) { cfg.Server.PublicURL = "localhost:8081" },
+			expectedErr: `invalid server public url "localhost:8081"`,
+		},
+		{
+			name:        "empty public url",
+			update:      func(cfg *Config) { cfg.Server.PublicURL = "" },
+			expectedErr: `invalid server public url ""`,
+		},
+		{
+			name:        "negative timeout",
+			update:      func(cfg *Config) { cfg.Server.WriteTimeout = -time.Second },
+			expectedErr: "server timeouts can't be negative",
+		},
+		{
+			name:        "invalid trusted proxy",
+			update:      func(cfg *Config) { cfg.Server.TrustedProxies = []string{"10.0.0.0/33"} },
+			expectedErr: `invalid trusted proxy "10.0.0.0/33"`,
+		},
+		{
+			name:        "tls cert without key",
+			update:      func(cfg *Config) { cfg.Server.TLS.CertFile = "server.crt" },
+			expectedErr: "tls cert file and key file must be set together",
+		},
+		{
+			name:        "tls client ca without cert",
+			update:      func(cfg *Config) { cfg.Server.TLS.ClientCAFile = "ca.crt" },
+			expectedErr: "tls client ca file requires a cert file and key file",
+		},
+		{
+			name:        "unknown database driver",
+			update:      func(cfg *Config) { cfg.Database.Driver = "oracle" },
+			expectedErr: `invalid database driver "oracle"`,
+		},
+		{
+			name:        "sqlite without file",
+			update:      func(cfg *Config) { cfg.Database.Driver = DatabaseDriverSQLite },
+			expectedErr: "database name is required for sqlite",
+		},
+		{
+			name:        "negative query timeout",
+			update:      func(cfg *Config) { cfg.Database.QueryTimeout = -time.Second },
+			expectedErr: "database query timeout can't be negative",
+		},
+		{
+			name:        "negative google timeout",
+			update:      func(cfg *Config) { cfg.Google.Timeout = -time.Second },
+			expectedErr: "google timeout can't be negative",
+		},
+		{
+			name:        "otlp without endpoint",
+			update:      func(cfg *Config) { cfg.Tracing.Exporter = TracingExporterOTLP },
+			expectedErr: "tracing endpoint is required for the otlp exporter",
+		},
+		{
+			name:        "unknown tracing exporter",
+			update:      func(cfg *Config) { cfg.Tracing.Exporter = "jaeger" },
+			expectedErr: `invalid tracing exporter "jaeger"`,
+		},
+		{
+			name:        "bcrypt cost out of range",
+			update:      func(cfg *Config) { cfg.Auth.BcryptCost = 1 },
+			expectedErr: "bcrypt cost must be between 4 and 31",
+		},
+		{
+			name:        "unknown signup mode",
+			update:      func(cfg *Config) { cfg.Auth.SignupMode = "closed" },
+			expectedErr: `invalid signup mode "closed"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			cfg := Default()
+			cfg.Auth.SigningKey = "secret"
+			tc.update(&cfg)
+
+			// When
+			err := cfg.Validate()
+
+			// Then
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestServer_TrustedProxyNetworks(t *testing.T) {
+	// Given
+	s := Server{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "::1"}}
+
+	// When
+	resp, err := s.TrustedProxyNetworks()
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, resp, 3)
+	require.Equal(t, "10.0.0.0/8", resp[0].String())
+	require.Equal(t, "192.168.1.1/32", resp[1].String())
+	require.Equal(t, "::1/128", resp[2].String())
+}
+
+func TestDatabase_DSN(t *testing.T) {
+	// Given
+	db := Database{Host: "db", Port: 3306, Name: "auth", User: "user", Password: "password"}
+
+	// When
+	resp := db.DSN()
+
+	// Then
+	require.Equal(t, "user:password@tcp(db:3306)/auth?parseTime=true", resp)
+}
+
+func TestDatabase_DSN_Postgres(t *testing.T) {
+	// Given
+	db := Database{Driver: DatabaseDriverPostgres, Host: "db", Port: 5432, Name: "auth", User: "user", Password: "p@ss", SSLMode: "disable"}
+
+	// When
+	resp := db.DSN()
+
+	// Then
+	require.Equal(t, "postgres://user:p%40ss@db:5432/auth?sslmode=disable", resp)
+}
@@ -11,13 +11,18 @@ var (
 	ErrWeakPassword          = errors.New("weak password")
 	ErrResourceAlreadyExists = errors.New("resource already exists")
 	ErrInvalidToken          = errors.New("can't access to the resource. invalid token")
+	ErrRevokedSession        = errors.New("can't access to the resource. revoked session")
 	ErrAlteredTokenClaims    = errors.New("can't access to the resource. claims don't match from original token")
+	ErrForbidden             = errors.New("can't access to the resource. insufficient permissions")
 	ErrResourceNotFound      = errors.New("resource not found")
+	ErrPreconditionFailed    = errors.New("precondition failed")
+	ErrPreconditionRequired  = errors.New("precondition required")
 )
 
 type Error struct {
 	StatusCode int    `json:"status_code"`
 	Message    string `json:"message"`
+	RequestID  string `json:"request_id,omitempty"`
 }
 
 func NewError(message string, statusCode int) *Error {